@@ -0,0 +1,77 @@
+import csv
+import os
+import threading
+
+base_dir = os.path.dirname(os.path.abspath(__file__))
+outcomes_path = os.path.join(base_dir, "../task_outcomes.csv")
+
+FIELDS = [
+    "task_id", "task_type_id", "urgency", "payload_size",
+    "priority", "estimated_time", "success", "worker",
+    "predicted_priority", "predicted_time", "predicted_worker",
+]
+
+_lock = threading.Lock()
+
+# (task_type_id, worker) -> [runs, successes, total seconds of the successful runs]
+_worker_stats = None
+
+def _to_float(value, default=0.0):
+    try:
+        return float(value)
+    except (TypeError, ValueError):
+        return default
+
+def record_outcome(report) -> None:
+    """Append an actual outcome so generate_and_train.py can learn from it."""
+    metadata = dict(report.metadata)
+    row = {
+        "task_id": report.task_id,
+        "task_type_id": _to_float(metadata.get("TaskType")),
+        "urgency": _to_float(metadata.get("Urgency")),
+        "payload_size": _to_float(metadata.get("PayloadSize")),
+        "priority": int(_to_float(metadata.get("Priority"), report.predicted_priority)),
+        "estimated_time": round(report.actual_time, 4),
+        "success": report.success,
+        "worker": report.worker,
+        "predicted_priority": report.predicted_priority,
+        "predicted_time": round(report.predicted_time, 4),
+        "predicted_worker": report.predicted_worker,
+    }
+
+    with _lock:
+        new_file = not os.path.exists(outcomes_path)
+        with open(outcomes_path, "a", newline="") as f:
+            writer = csv.DictWriter(f, fieldnames=FIELDS)
+            if new_file:
+                writer.writeheader()
+            writer.writerow(row)
+        if _worker_stats is not None:
+            _count(_worker_stats, row)
+
+def _count(stats, row) -> None:
+    if not row["worker"]:
+        return
+    key = (_to_float(row["task_type_id"]), row["worker"])
+    runs = stats.setdefault(key, [0, 0, 0.0])
+    runs[0] += 1
+    if str(row["success"]) == "True":
+        runs[1] += 1
+        runs[2] += _to_float(row["estimated_time"])
+
+def worker_stats(task_type) -> dict:
+    """Runs, successes and mean successful seconds per worker for a task type."""
+    global _worker_stats
+    task_type_id = _to_float(task_type)
+    with _lock:
+        if _worker_stats is None:
+            _worker_stats = {}
+            if os.path.exists(outcomes_path):
+                with open(outcomes_path, newline="") as f:
+                    for row in csv.DictReader(f):
+                        _count(_worker_stats, row)
+        return {
+            worker: (runs, successes, total / successes if successes else 0.0)
+            for (type_id, worker), (runs, successes, total) in _worker_stats.items()
+            if type_id == task_type_id
+        }
@@ -1,3 +1,4 @@
+import hashlib
 import joblib
 import numpy as np
 import os
@@ -9,6 +10,16 @@ time_model_path = os.path.join(base_dir, "../models/time_rf.pkl")
 priority_model = joblib.load(model_path)
 time_model = joblib.load(time_model_path)
 
+def _model_version() -> str:
+    digest = hashlib.sha256()
+    for path in (model_path, time_model_path):
+        with open(path, "rb") as f:
+            digest.update(f.read())
+    return digest.hexdigest()[:12]
+
+# changes whenever the models are retrained
+MODEL_VERSION = _model_version()
+
 def predict_priority_and_time(metadata: dict) -> tuple[int, float]:
 
     task_type = float(metadata.get("TaskType", 0))
@@ -19,4 +30,29 @@ def predict_priority_and_time(metadata: dict) -> tuple[int, float]:
     
     priority = int(priority_model.predict(X)[0])
     estimated_time = round(float(time_model.predict(X)[0]), 2)
-    return priority, estimated_time
\ No newline at end of file
+    return priority, estimated_time
+
+
+FEATURES = ["TaskType", "Urgency", "PayloadSize"]
+
+def predict_confidence(metadata: dict) -> tuple[float, float, dict]:
+    """Confidence of the priority and time predictions, plus per-feature attributions."""
+
+    task_type = float(metadata.get("TaskType", 0))
+    urgency = float(metadata.get("Urgency", 0))
+    size = float(metadata.get("PayloadSize", 0))
+
+    X = np.array([[task_type, urgency, size]])
+
+    # share of trees voting for the winning priority class
+    priority_confidence = float(np.max(priority_model.predict_proba(X)[0]))
+
+    # spread between trees, mapped to (0, 1]: identical trees -> 1.0
+    tree_times = np.array([tree.predict(X)[0] for tree in time_model.estimators_])
+    time_confidence = float(1.0 / (1.0 + np.std(tree_times)))
+
+    attributions = {
+        name: round(float(weight), 4)
+        for name, weight in zip(FEATURES, priority_model.feature_importances_)
+    }
+    return priority_confidence, time_confidence, attributions
@@ -24,7 +24,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\rpredict.proto\x12\x07predict\"\x8b\x01\n\x0ePredictRequest\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x37\n\x08metadata\x18\x02 \x03(\x0b\x32%.predict.PredictRequest.MetadataEntry\x1a/\n\rMetadataEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"W\n\x0fPredictResponse\x12\x10\n\x08priority\x18\x01 \x01(\x05\x12\x16\n\x0e\x65stimated_time\x18\x02 \x01(\x02\x12\x1a\n\x12recommended_worker\x18\x03 \x01(\t2K\n\x0b\x41IPredictor\x12<\n\x07Predict\x12\x17.predict.PredictRequest\x1a\x18.predict.PredictResponseB:Z8github.com/JamesDante/idtask-scheduler/internal/aiclientb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\rpredict.proto\x12\x07predict\"\x8b\x01\n\x0ePredictRequest\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x37\n\x08metadata\x18\x02 \x03(\x0b\x32%.predict.PredictRequest.MetadataEntry\x1a/\n\rMetadataEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\xcc\x02\n\x0fPredictResponse\x12\x10\n\x08priority\x18\x01 \x01(\x05\x12\x16\n\x0e\x65stimated_time\x18\x02 \x01(\x02\x12\x1a\n\x12recommended_worker\x18\x03 \x01(\t\x12\x15\n\rmodel_version\x18\x04 \x01(\t\x12\x1b\n\x13priority_confidence\x18\x05 \x01(\x02\x12\x17\n\x0ftime_confidence\x18\x06 \x01(\x02\x12\x19\n\x11worker_confidence\x18\x07 \x01(\x02\x12O\n\x14\x66\x65\x61ture_attributions\x18\x08 \x03(\x0b\x32\x31.predict.PredictResponse.FeatureAttributionsEntry\x1a:\n\x18\x46\x65\x61tureAttributionsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x02:\x02\x38\x01\"@\n\x13PredictBatchRequest\x12)\n\x08requests\x18\x01 \x03(\x0b\x32\x17.predict.PredictRequest\"C\n\x14PredictBatchResponse\x12+\n\tresponses\x18\x01 \x03(\x0b\x32\x18.predict.PredictResponse\"\x8d\x02\n\rOutcomeReport\x12\x0f\n\x07task_id\x18\x01 \x01(\t\x12\x36\n\x08metadata\x18\x02 \x03(\x0b\x32$.predict.OutcomeReport.MetadataEntry\x12\x0e\n\x06worker\x18\x03 \x01(\t\x12\x0f\n\x07success\x18\x04 \x01(\x08\x12\x13\n\x0b\x61\x63tual_time\x18\x05 \x01(\x02\x12\x1a\n\x12predicted_priority\x18\x06 \x01(\x05\x12\x16\n\x0epredicted_time\x18\x07 \x01(\x02\x12\x18\n\x10predicted_worker\x18\x08 \x01(\t\x1a/\n\rMetadataEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\x1e\n\nOutcomeAck\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x01 \x01(\x08\x32\xd6\x01\n\x0b\x41IPredictor\x12<\n\x07Predict\x12\x17.predict.PredictRequest\x1a\x18.predict.PredictResponse\x12<\n\rReportOutcome\x12\x16.predict.OutcomeReport\x1a\x13.predict.OutcomeAck\x12K\n\x0cPredictBatch\x12\x1c.predict.PredictBatchRequest\x1a\x1d.predict.PredictBatchResponseB:Z8github.com/JamesDante/idtask-scheduler/internal/aiclientb\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -34,12 +34,28 @@ if not _descriptor._USE_C_DESCRIPTORS:
   _globals['DESCRIPTOR']._serialized_options = b'Z8github.com/JamesDante/idtask-scheduler/internal/aiclient'
   _globals['_PREDICTREQUEST_METADATAENTRY']._loaded_options = None
   _globals['_PREDICTREQUEST_METADATAENTRY']._serialized_options = b'8\001'
+  _globals['_PREDICTRESPONSE_FEATUREATTRIBUTIONSENTRY']._loaded_options = None
+  _globals['_PREDICTRESPONSE_FEATUREATTRIBUTIONSENTRY']._serialized_options = b'8\001'
+  _globals['_OUTCOMEREPORT_METADATAENTRY']._loaded_options = None
+  _globals['_OUTCOMEREPORT_METADATAENTRY']._serialized_options = b'8\001'
   _globals['_PREDICTREQUEST']._serialized_start=27
   _globals['_PREDICTREQUEST']._serialized_end=166
   _globals['_PREDICTREQUEST_METADATAENTRY']._serialized_start=119
   _globals['_PREDICTREQUEST_METADATAENTRY']._serialized_end=166
-  _globals['_PREDICTRESPONSE']._serialized_start=168
-  _globals['_PREDICTRESPONSE']._serialized_end=255
-  _globals['_AIPREDICTOR']._serialized_start=257
-  _globals['_AIPREDICTOR']._serialized_end=332
+  _globals['_PREDICTRESPONSE']._serialized_start=169
+  _globals['_PREDICTRESPONSE']._serialized_end=501
+  _globals['_PREDICTRESPONSE_FEATUREATTRIBUTIONSENTRY']._serialized_start=443
+  _globals['_PREDICTRESPONSE_FEATUREATTRIBUTIONSENTRY']._serialized_end=501
+  _globals['_PREDICTBATCHREQUEST']._serialized_start=503
+  _globals['_PREDICTBATCHREQUEST']._serialized_end=567
+  _globals['_PREDICTBATCHRESPONSE']._serialized_start=569
+  _globals['_PREDICTBATCHRESPONSE']._serialized_end=636
+  _globals['_OUTCOMEREPORT']._serialized_start=639
+  _globals['_OUTCOMEREPORT']._serialized_end=908
+  _globals['_OUTCOMEREPORT_METADATAENTRY']._serialized_start=861
+  _globals['_OUTCOMEREPORT_METADATAENTRY']._serialized_end=908
+  _globals['_OUTCOMEACK']._serialized_start=910
+  _globals['_OUTCOMEACK']._serialized_end=940
+  _globals['_AIPREDICTOR']._serialized_start=943
+  _globals['_AIPREDICTOR']._serialized_end=1157
 # @@protoc_insertion_point(module_scope)
@@ -39,6 +39,16 @@ class AIPredictorStub(object):
                 request_serializer=predict__pb2.PredictRequest.SerializeToString,
                 response_deserializer=predict__pb2.PredictResponse.FromString,
                 _registered_method=True)
+        self.ReportOutcome = channel.unary_unary(
+                '/predict.AIPredictor/ReportOutcome',
+                request_serializer=predict__pb2.OutcomeReport.SerializeToString,
+                response_deserializer=predict__pb2.OutcomeAck.FromString,
+                _registered_method=True)
+        self.PredictBatch = channel.unary_unary(
+                '/predict.AIPredictor/PredictBatch',
+                request_serializer=predict__pb2.PredictBatchRequest.SerializeToString,
+                response_deserializer=predict__pb2.PredictBatchResponse.FromString,
+                _registered_method=True)
 
 
 class AIPredictorServicer(object):
@@ -50,6 +60,18 @@ class AIPredictorServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def ReportOutcome(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PredictBatch(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_AIPredictorServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -58,6 +80,16 @@ def add_AIPredictorServicer_to_server(servicer, server):
                     request_deserializer=predict__pb2.PredictRequest.FromString,
                     response_serializer=predict__pb2.PredictResponse.SerializeToString,
             ),
+            'ReportOutcome': grpc.unary_unary_rpc_method_handler(
+                    servicer.ReportOutcome,
+                    request_deserializer=predict__pb2.OutcomeReport.FromString,
+                    response_serializer=predict__pb2.OutcomeAck.SerializeToString,
+            ),
+            'PredictBatch': grpc.unary_unary_rpc_method_handler(
+                    servicer.PredictBatch,
+                    request_deserializer=predict__pb2.PredictBatchRequest.FromString,
+                    response_serializer=predict__pb2.PredictBatchResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'predict.AIPredictor', rpc_method_handlers)
@@ -95,3 +127,57 @@ class AIPredictor(object):
             timeout,
             metadata,
             _registered_method=True)
+
+    @staticmethod
+    def ReportOutcome(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(
+            request,
+            target,
+            '/predict.AIPredictor/ReportOutcome',
+            predict__pb2.OutcomeReport.SerializeToString,
+            predict__pb2.OutcomeAck.FromString,
+            options,
+            channel_credentials,
+            insecure,
+            call_credentials,
+            compression,
+            wait_for_ready,
+            timeout,
+            metadata,
+            _registered_method=True)
+
+    @staticmethod
+    def PredictBatch(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(
+            request,
+            target,
+            '/predict.AIPredictor/PredictBatch',
+            predict__pb2.PredictBatchRequest.SerializeToString,
+            predict__pb2.PredictBatchResponse.FromString,
+            options,
+            channel_credentials,
+            insecure,
+            call_credentials,
+            compression,
+            wait_for_ready,
+            timeout,
+            metadata,
+            _registered_method=True)
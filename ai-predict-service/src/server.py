@@ -1,14 +1,15 @@
 import grpc
 from concurrent import futures
 import time
-import random
+import json
 
 from proto import predict_pb2
 from proto import predict_pb2_grpc
 from etcd3 import Client
 
 from config import load_config
-from predictor import predict_priority_and_time
+from predictor import predict_priority_and_time, predict_confidence, MODEL_VERSION
+from outcomes import record_outcome, worker_stats
 
 cfg = load_config()
 
@@ -36,42 +37,79 @@ class AIPredictorServicer(predict_pb2_grpc.AIPredictorServicer):
                 context.set_details("No workers available")
                 return predict_pb2.PredictResponse()
 
-            metadata = dict(request.metadata)
-            priority, estimated_time = predict_priority_and_time(metadata)
-
-            #recommended_worker = random.choice(list(workers.values()))
-            #print(f"Recommended worker {recommended_worker}")
-
-            recommended_worker = None
-            for addr, meta in workers.items():
-                if str(priority) in addr or "worker" in addr:
-                    recommended_worker = addr
-                    break
-            
-            if not recommended_worker:
-                recommended_worker = random.choice(list(workers.values()))
-            
-            if not recommended_worker:
+            response = self.predict_one(request, workers)
+            if response is None:
                 context.set_code(grpc.StatusCode.NOT_FOUND)
                 context.set_details("No suitable worker found")
                 return predict_pb2.PredictResponse()
 
-            print(f"🎯 Predicted priority: {priority}, estimated_time: {estimated_time}")
-            print(f"✅ Recommended worker: {recommended_worker}")
-
-            priority = random.randint(1, 10)
-            estimated_time = round(random.uniform(1.0, 5.0), 2)
-
-            return predict_pb2.PredictResponse(
-                priority=priority,
-                estimated_time=estimated_time,
-                recommended_worker=recommended_worker
-            )
+            return response
         except Exception as e:
             context.set_code(grpc.StatusCode.INTERNAL)
             context.set_details(f"Prediction failed: {str(e)}")
             return predict_pb2.PredictResponse()
 
+    def PredictBatch(self, request, context):
+        try:
+            # one etcd read for the whole batch
+            workers = self.get_workers()
+
+            if not workers:
+                context.set_code(grpc.StatusCode.NOT_FOUND)
+                context.set_details("No workers available")
+                return predict_pb2.PredictBatchResponse()
+
+            responses = []
+            for req in request.requests:
+                response = None
+                if req.task_id:
+                    response = self.predict_one(req, workers)
+                responses.append(response or predict_pb2.PredictResponse())
+
+            print(f"📦 Predicted batch of {len(responses)} tasks")
+            return predict_pb2.PredictBatchResponse(responses=responses)
+        except Exception as e:
+            context.set_code(grpc.StatusCode.INTERNAL)
+            context.set_details(f"Batch prediction failed: {str(e)}")
+            return predict_pb2.PredictBatchResponse()
+
+    def predict_one(self, request, workers):
+        metadata = dict(request.metadata)
+        priority, estimated_time = predict_priority_and_time(metadata)
+        priority_confidence, time_confidence, attributions = predict_confidence(metadata)
+
+        recommended_worker, worker_confidence = recommend_worker(metadata, workers)
+
+        print(f"🎯 Predicted priority: {priority}, estimated_time: {estimated_time}")
+        if recommended_worker:
+            print(f"✅ Recommended worker: {recommended_worker} (confidence {worker_confidence:.2f})")
+
+        return predict_pb2.PredictResponse(
+            priority=priority,
+            estimated_time=estimated_time,
+            recommended_worker=recommended_worker,
+            model_version=MODEL_VERSION,
+            priority_confidence=priority_confidence,
+            time_confidence=time_confidence,
+            worker_confidence=worker_confidence,
+            feature_attributions=attributions
+        )
+
+    def ReportOutcome(self, request, context):
+        if not request.task_id:
+            context.set_code(grpc.StatusCode.INVALID_ARGUMENT)
+            context.set_details("Task ID is required")
+            return predict_pb2.OutcomeAck(accepted=False)
+
+        try:
+            record_outcome(request)
+            print(f"📝 Outcome for {request.task_id}: actual={request.actual_time:.2f}s predicted={request.predicted_time:.2f}s success={request.success}")
+            return predict_pb2.OutcomeAck(accepted=True)
+        except Exception as e:
+            context.set_code(grpc.StatusCode.INTERNAL)
+            context.set_details(f"Recording outcome failed: {str(e)}")
+            return predict_pb2.OutcomeAck(accepted=False)
+
     def get_workers(self, prefix='/workers/'):
         end = prefix[:-1] + chr(ord(prefix[-1]) + 1)
         response = self.etcd_client.range(prefix, end)
@@ -91,6 +129,35 @@ class AIPredictorServicer(predict_pb2_grpc.AIPredictorServicer):
             print(f"Failed to fetch workers from etcd: {e}")
         return workers
 
+# runs of a task type on a worker before its record is fully trusted
+TRUSTED_RUNS = 10
+
+def recommend_worker(metadata, workers):
+    """The healthy worker that ran the task type fastest, by reported outcomes.
+
+    Returns the worker's ID and a confidence, the worker's success rate
+    scaled down while it has fewer than TRUSTED_RUNS runs, or no worker and
+    0.0 when no healthy worker has a record for the type, so the scheduler
+    chooses by itself.
+    """
+    healthy = set()
+    for key, value in workers.items():
+        try:
+            status = json.loads(value)
+        except ValueError:
+            continue
+        if status.get("status") == "ok":
+            healthy.add(status.get("id") or key.rsplit("/", 1)[-1])
+
+    best, best_time, confidence = "", 0.0, 0.0
+    for worker, (runs, successes, mean_time) in worker_stats(metadata.get("TaskType")).items():
+        if worker not in healthy or not successes:
+            continue
+        if not best or mean_time < best_time:
+            best, best_time = worker, mean_time
+            confidence = successes / runs * min(1.0, runs / TRUSTED_RUNS)
+    return best, confidence
+
 def serve():
     global cfg 
     cfg = load_config()
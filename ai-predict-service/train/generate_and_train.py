@@ -19,6 +19,13 @@ df["estimated_time"] = 0.5 + df["payload_size"] / 2000 + (10 - df["urgency"]) *
 
 df.to_csv("task_data_synthetic.csv", index=False)
 
+# real outcomes reported by the workers through ReportOutcome
+if os.path.exists("task_outcomes.csv"):
+    outcomes = pd.read_csv("task_outcomes.csv")
+    outcomes = outcomes[outcomes["success"].astype(str) == "True"]
+    df = pd.concat([df, outcomes[df.columns]], ignore_index=True)
+    print(f"📥 Added {len(outcomes)} real outcomes to the training set")
+
 X = df[["task_type_id", "urgency", "payload_size"]]
 y_priority = df["priority"]
 y_time = df["estimated_time"]
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/pending"
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// queueRetryAfter is suggested when a queue is full, it drains at the
+	// pace of the workers so there is no exact time to give.
+	queueRetryAfter = 5 * time.Second
+
+	// depthRefresh bounds how stale the queue depth used for backpressure
+	// may be, so every submission does not have to sum the queues.
+	depthRefresh = time.Second
+)
+
+// quota caps one tenant's submissions, zero fields are unlimited.
+type quota struct {
+	// Queued is the most unfinished tasks the tenant may have, whether
+	// delayed, queued or running.
+	Queued int64
+	// Rate is the most submissions per minute.
+	Rate int64
+	// Payload is the largest payload of one task, in bytes.
+	Payload int
+}
+
+// parseQuotas reads "tenant:queued=1000,rate=600,payload=65536" entries
+// separated by ";". The tenant "*" applies to every tenant not listed.
+func parseQuotas(spec string) (map[string]quota, error) {
+	out := make(map[string]quota)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, opts, ok := strings.Cut(entry, ":")
+		tenant = strings.TrimSpace(tenant)
+		if !ok || tenant == "" {
+			return nil, fmt.Errorf("invalid quota %q, want tenant:option=value,...", entry)
+		}
+
+		var q quota
+		for _, opt := range strings.Split(opts, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			var err error
+			switch k {
+			case "queued":
+				q.Queued, err = strconv.ParseInt(v, 10, 64)
+			case "rate":
+				q.Rate, err = strconv.ParseInt(v, 10, 64)
+			case "payload":
+				q.Payload, err = strconv.Atoi(v)
+			default:
+				err = fmt.Errorf("unknown option %q", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid quota for %s: %w", tenant, err)
+			}
+		}
+		out[tenant] = q
+	}
+	return out, nil
+}
+
+// rejection is why a submission was refused.
+type rejection struct {
+	status     int
+	reason     string
+	message    string
+	retryAfter time.Duration
+}
+
+// rejectionCodes are the error codes of each rejection reason.
+var rejectionCodes = map[string]string{
+	"payload":      codePayloadTooLarge,
+	"queued":       codeQuotaExceeded,
+	"rate":         codeRateLimited,
+	"backpressure": codeOverloaded,
+}
+
+func (rej *rejection) apiError() *apiError {
+	e := newError(rej.status, rejectionCodes[rej.reason], rej.message)
+	e.retryAfter = rej.retryAfter
+	return e
+}
+
+// admission applies tenant quotas and global backpressure to submissions.
+type admission struct {
+	quotas map[string]quota
+
+	mu      sync.Mutex
+	depth   int64
+	depthAt time.Time
+}
+
+func newAdmission() *admission {
+	quotas, err := parseQuotas(configs.Config.TenantQuotas)
+	if err != nil {
+		log.Printf("⚠️ Ignoring TENANT_QUOTAS: %v", err)
+	}
+	return &admission{quotas: quotas}
+}
+
+// check decides whether t may be queued, t's tenant already defaulted.
+// The rate quota is counted last, so refused submissions do not use it up.
+func (a *admission) check(t *models.Task) *rejection {
+	if rej := a.backpressure(t); rej != nil {
+		return a.reject(t, rej)
+	}
+
+	q, ok := a.quotas[t.Tenant]
+	if !ok {
+		q, ok = a.quotas["*"]
+	}
+	if !ok {
+		return nil
+	}
+
+	if q.Payload > 0 && len(t.Payload) > q.Payload {
+		return a.reject(t, &rejection{
+			status:  http.StatusRequestEntityTooLarge,
+			reason:  "payload",
+			message: fmt.Sprintf("Payload exceeds %d bytes", q.Payload),
+		})
+	}
+
+	if q.Queued > 0 {
+		queued, err := pending.Count(ctx, rdb, t.Tenant)
+		if err != nil {
+			log.Printf("⚠️ Failed to count queued tasks of %s: %v", t.Tenant, err)
+		} else if queued >= q.Queued {
+			return a.reject(t, &rejection{
+				status:     http.StatusTooManyRequests,
+				reason:     "queued",
+				message:    fmt.Sprintf("Tenant %s has %d unfinished tasks, the quota is %d", t.Tenant, queued, q.Queued),
+				retryAfter: queueRetryAfter,
+			})
+		}
+	}
+
+	if q.Rate > 0 {
+		if retry, ok := takeRate(t.Tenant, q.Rate); !ok {
+			return a.reject(t, &rejection{
+				status:     http.StatusTooManyRequests,
+				reason:     "rate",
+				message:    fmt.Sprintf("Tenant %s is limited to %d submissions per minute", t.Tenant, q.Rate),
+				retryAfter: retry,
+			})
+		}
+	}
+	return nil
+}
+
+func (a *admission) reject(t *models.Task, rej *rejection) *rejection {
+	monitor.ApiAdmissionRejected().WithLabelValues(t.Tenant, rej.reason).Inc()
+	return rej
+}
+
+// backpressure refuses submissions while the queues are deeper than
+// BACKPRESSURE_QUEUE_DEPTH: all of them in reject mode, those below
+// BACKPRESSURE_MIN_PRIORITY in shed mode.
+func (a *admission) backpressure(t *models.Task) *rejection {
+	limit := configs.Config.BackpressureQueueDepth
+	if limit <= 0 {
+		return nil
+	}
+	if configs.Config.BackpressureMode != "reject" && t.Priority.Int64 >= int64(configs.Config.BackpressureMinPriority) {
+		return nil
+	}
+
+	depth, err := a.queueDepth()
+	if err != nil {
+		log.Printf("⚠️ Failed to measure queue depth: %v", err)
+		return nil
+	}
+	if depth < int64(limit) {
+		return nil
+	}
+	return &rejection{
+		status:     http.StatusTooManyRequests,
+		reason:     "backpressure",
+		message:    fmt.Sprintf("Scheduler is overloaded with %d tasks queued", depth),
+		retryAfter: queueRetryAfter,
+	}
+}
+
+// queueDepth sums the task queues of every shard and tenant, at most
+// depthRefresh old.
+func (a *admission) queueDepth() (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if time.Since(a.depthAt) < depthRefresh {
+		return a.depth, nil
+	}
+
+	var queues []string
+	for n := 0; n < shard.Count(); n++ {
+		queues = append(queues, shard.TaskQueue(n))
+		tenants, err := rdb.SMembers(ctx, shard.Tenants(n)).Result()
+		if err != nil {
+			return 0, err
+		}
+		for _, tenant := range tenants {
+			queues = append(queues, shard.TenantQueue(n, tenant))
+		}
+	}
+
+	depth, err := sumLen(queues)
+	if err != nil {
+		return 0, err
+	}
+	a.depth, a.depthAt = depth, time.Now()
+	monitor.ApiQueueDepth().Set(float64(depth))
+	return depth, nil
+}
+
+func sumLen(queues []string) (int64, error) {
+	pipe := rdb.Pipeline()
+	lens := make([]*redis.IntCmd, len(queues))
+	for i, q := range queues {
+		lens[i] = pipe.LLen(ctx, q)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, l := range lens {
+		total += l.Val()
+	}
+	return total, nil
+}
+
+// takeRate counts a submission in the tenant's current one minute window
+// and reports whether it is within limit, or else how long until the
+// window resets.
+func takeRate(tenant string, limit int64) (time.Duration, bool) {
+	now := time.Now()
+	window := now.Truncate(time.Minute)
+	key := fmt.Sprintf("quota:rate:%s:%d", tenant, window.Unix())
+
+	pipe := rdb.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("⚠️ Failed to count submissions of %s: %v", tenant, err)
+		return 0, true
+	}
+
+	if count.Val() > limit {
+		return window.Add(time.Minute).Sub(now), false
+	}
+	return 0, true
+}
+
+// setRetryAfter tells the client when to try again, in whole seconds.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	secs := int((d + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+}
@@ -0,0 +1,363 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/auth"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/cancel"
+	"github.com/JamesDante/idtask-scheduler/internal/drain"
+	"github.com/JamesDante/idtask-scheduler/internal/etcdclient"
+	"github.com/JamesDante/idtask-scheduler/internal/jsonpath"
+	"github.com/JamesDante/idtask-scheduler/internal/pending"
+	"github.com/JamesDante/idtask-scheduler/internal/redisclient"
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	rdb   *redis.Client
+	ctx   = context.Background()
+	admit *admission
+)
+
+// NewHandler builds the HTTP routes. Storage, Redis and etcd must already be initialized.
+func NewHandler() http.Handler {
+	rdb = redisclient.GetClient()
+	admit = newAdmission()
+	authn = newAuthenticator()
+	policy = newPolicy()
+
+	mux := http.NewServeMux()
+	registerV1(mux)
+	registerLegacy(mux)
+	return mux
+}
+
+// submitTask validates, admits, stores and queues a task for the request's
+// principal. delayed tasks wait in delayed-tasks until their scheduled_at.
+func submitTask(r *http.Request, t *models.Task, delayed bool) *apiError {
+	auditTask(r, t)
+	if errs := validateTask(t, delayed); errs != nil {
+		return invalid(errs)
+	}
+	if e := permit(r, auth.PermTaskSubmit, t.Tenant); e != nil {
+		return e
+	}
+
+	if e := validatePayload(t); e != nil {
+		return e
+	}
+
+	if rej := admit.check(t); rej != nil {
+		return rej.apiError()
+	}
+
+	t.ID = uuid.New().String()
+	t.CreatedBy = principal(r).Subject
+	auditTask(r, t)
+	createdAt := time.Now()
+	t.CreatedAt = &createdAt
+	if t.ExpireAt == nil {
+		expireAt := createdAt.AddDate(0, 0, 1)
+		t.ExpireAt = &expireAt
+	}
+
+	if _, err := storage.CreateTask(t); err != nil {
+		log.Printf("Failed to insert task: %v", err)
+		return newError(http.StatusInternalServerError, codeInternal, "Failed to store task")
+	}
+
+	if e := enqueue(t, delayed); e != nil {
+		return e
+	}
+
+	monitor.ApiRequestsTotal().Inc()
+	return nil
+}
+
+// enqueue counts a stored task against its tenant's quota and queues it,
+// on delayed-tasks until its scheduled_at or straight on its shard.
+func enqueue(t *models.Task, delayed bool) *apiError {
+	if err := pending.Admit(ctx, rdb, t); err != nil {
+		log.Printf("⚠️ Failed to count task %s against its tenant's quota: %v", t.ID, err)
+	}
+
+	queued := *t
+	if err := blob.Offload(ctx, &queued, configs.Config.PayloadOffloadBytes); err != nil {
+		log.Printf("Failed to offload payload: %v", err)
+		return newError(http.StatusServiceUnavailable, codeUnavailable, "Failed to store payload")
+	}
+	taskBytes, err := json.Marshal(&queued)
+	if err != nil {
+		log.Printf("Failed to marshal task: %v", err)
+		return newError(http.StatusInternalServerError, codeInternal, "Failed to marshal task")
+	}
+	if t.Tenant != models.DefaultTenant {
+		rdb.SAdd(ctx, shard.Tenants(shard.Of(t)), t.Tenant)
+	}
+
+	if delayed {
+		err = rdb.ZAdd(ctx, "delayed-tasks", &redis.Z{
+			Score:  float64(t.ScheduledAt.Unix()),
+			Member: taskBytes,
+		}).Err()
+	} else {
+		err = rdb.RPush(ctx, shard.QueueOf(t), taskBytes).Err()
+	}
+	if err != nil {
+		log.Printf("Failed to enqueue task %s: %v", t.ID, err)
+		blob.Release(ctx, &queued)
+		pending.Release(ctx, rdb, t)
+		return newError(http.StatusServiceUnavailable, codeUnavailable, "Failed to enqueue task")
+	}
+	return nil
+}
+
+// listTasks returns a page of tasks and how many there are in total, of
+// one tenant or of all the principal may list.
+func listTasks(r *http.Request, req *models.APIListRequest) ([]models.Task, int, *apiError) {
+	errs := validatePage(&req.Page, &req.PageSize)
+	if req.Tenant != "" && !models.ValidTenant(req.Tenant) {
+		errs = append(errs, models.FieldError{Field: "tenant", Message: "is not a valid tenant"})
+	}
+	for _, f := range req.PayloadFilters {
+		if _, err := jsonpath.Compile(f); err != nil {
+			errs = append(errs, models.FieldError{Field: "payload_filter", Message: err.Error()})
+		}
+	}
+	if errs != nil {
+		return nil, 0, invalid(errs)
+	}
+
+	scope := req.Tenant
+	if scope == "" {
+		scope = auth.AnyTenant
+	}
+	if e := permit(r, auth.PermTaskList, scope); e != nil {
+		return nil, 0, e
+	}
+
+	tasks, err := storage.GetTasks(req)
+	if err != nil {
+		log.Printf("Failed to fetch tasks: %v", err)
+		return nil, 0, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch tasks")
+	}
+	return tasks, storage.GetTasksCount(req), nil
+}
+
+// getTask returns one task the principal may list.
+func getTask(r *http.Request, id string) (*models.Task, *apiError) {
+	t, err := storage.GetTask(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No task with that id")
+	}
+	if err != nil {
+		log.Printf("Failed to fetch task %s: %v", id, err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch task")
+	}
+	if e := permit(r, auth.PermTaskList, models.TenantOf(t)); e != nil {
+		return nil, e
+	}
+	return t, nil
+}
+
+// cancelTask cancels a task that has not finished. Wherever the task is
+// queued, the scheduler or worker that picks it up next drops it.
+func cancelTask(r *http.Request, id string) (*models.Task, *apiError) {
+	auditTarget(r, id, nil)
+	t, err := storage.GetTask(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No task with that id")
+	}
+	if err != nil {
+		log.Printf("Failed to fetch task %s: %v", id, err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch task")
+	}
+	if e := permit(r, auth.PermTaskCancel, models.TenantOf(t)); e != nil {
+		return nil, e
+	}
+
+	t, err = storage.CancelTask(id)
+	if errors.Is(err, storage.ErrTaskFinished) {
+		return nil, newError(http.StatusConflict, codeTaskFinished, "Task is already "+t.Status)
+	}
+	if err != nil {
+		log.Printf("Failed to cancel task %s: %v", id, err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to cancel task")
+	}
+	if err := cancel.Mark(ctx, rdb, id); err != nil {
+		log.Printf("⚠️ Failed to flag task %s as cancelled: %v", id, err)
+	}
+	pending.Release(ctx, rdb, t)
+
+	log.Printf("🛑 Task %s cancelled by %s", id, principal(r).Subject)
+	return t, nil
+}
+
+// requeueTask puts a failed task from the dead-letter state back on its
+// shard's queue, with one more retry counted against it.
+func requeueTask(r *http.Request, id string) (*models.Task, *apiError) {
+	auditTarget(r, id, nil)
+	t, err := storage.GetTask(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No task with that id")
+	}
+	if err != nil {
+		log.Printf("Failed to fetch task %s: %v", id, err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch task")
+	}
+	if e := permit(r, auth.PermDLQRequeue, models.TenantOf(t)); e != nil {
+		return nil, e
+	}
+
+	t, err = storage.RequeueTask(id)
+	if errors.Is(err, storage.ErrTaskNotFailed) {
+		return nil, newError(http.StatusConflict, codeTaskNotFailed, "Task is "+t.Status+", only failed tasks are requeued")
+	}
+	if err != nil {
+		log.Printf("Failed to requeue task %s: %v", id, err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to requeue task")
+	}
+	// The failed run marked the task executed; clear it so the worker runs it again.
+	rdb.Del(ctx, "task-executed:"+id)
+	if e := enqueue(t, false); e != nil {
+		return nil, e
+	}
+
+	log.Printf("♻️ Task %s requeued by %s", id, principal(r).Subject)
+	return t, nil
+}
+
+// auditTask records the submitted task as the target of the audited call.
+func auditTask(r *http.Request, t *models.Task) {
+	params := map[string]any{"type": t.Type, "tenant": t.Tenant, "key": t.Key}
+	if t.Priority.Valid {
+		params["priority"] = t.Priority.Int64
+	}
+	if t.ScheduledAt != nil {
+		params["scheduled_at"] = t.ScheduledAt
+	}
+	auditTarget(r, t.ID, params)
+}
+
+func workerStatuses() ([]models.WorkerStatus, *apiError) {
+	kvMap, err := etcdclient.Get("/workers")
+	if err != nil {
+		return nil, newError(http.StatusServiceUnavailable, codeUnavailable, "Failed to get status from etcd")
+	}
+
+	statuses := make([]models.WorkerStatus, 0, len(kvMap))
+	for _, val := range kvMap {
+		var s models.WorkerStatus
+		if err := json.Unmarshal([]byte(val), &s); err == nil {
+			statuses = append(statuses, s)
+		} else {
+			log.Printf("❌ Failed to parse worker status: %v\nRaw: %s", err, val)
+		}
+	}
+	return statuses, nil
+}
+
+// drainWorker marks a worker as draining, or clears the mark. The mark
+// shares the worker's lease, and the worker's status is rewritten at once
+// so schedulers stop, or resume, sending it tasks before its next
+// heartbeat.
+func drainWorker(r *http.Request, id string, draining bool) (*models.WorkerStatus, *apiError) {
+	auditTarget(r, id, nil)
+	cli := etcdclient.GetClient()
+	resp, err := cli.Get(ctx, "/workers/"+id)
+	if err != nil {
+		return nil, newError(http.StatusServiceUnavailable, codeUnavailable, "Failed to get status from etcd")
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No worker with that id")
+	}
+	kv := resp.Kvs[0]
+	var ws models.WorkerStatus
+	if err := json.Unmarshal(kv.Value, &ws); err != nil {
+		log.Printf("❌ Failed to parse worker status: %v\nRaw: %s", err, kv.Value)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to parse worker status")
+	}
+
+	if draining {
+		_, err = cli.Put(ctx, drain.Key(id), principal(r).Subject, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+		ws.Status = drain.Status
+	} else {
+		_, err = cli.Delete(ctx, drain.Key(id))
+		ws.Status = "ok"
+	}
+	if err != nil {
+		log.Printf("Failed to mark worker %s: %v", id, err)
+		return nil, newError(http.StatusServiceUnavailable, codeUnavailable, "Failed to mark worker in etcd")
+	}
+	data, _ := json.Marshal(ws)
+	if _, err := cli.Put(ctx, "/workers/"+id, string(data), clientv3.WithLease(clientv3.LeaseID(kv.Lease))); err != nil {
+		log.Printf("⚠️ Failed to publish status of worker %s: %v", id, err)
+	}
+
+	if draining {
+		log.Printf("🚧 Worker %s drained by %s", id, principal(r).Subject)
+	} else {
+		log.Printf("🚦 Worker %s undrained by %s", id, principal(r).Subject)
+	}
+	return &ws, nil
+}
+
+func schedulerStatuses() ([]models.SchedulerStatus, *apiError) {
+	kvMap, err := etcdclient.Get("scheduler/status")
+	if err != nil {
+		return nil, newError(http.StatusServiceUnavailable, codeUnavailable, "Failed to get status from etcd")
+	}
+
+	statuses := make([]models.SchedulerStatus, 0, len(kvMap))
+	for _, val := range kvMap {
+		var s models.SchedulerStatus
+		if err := json.Unmarshal([]byte(val), &s); err == nil {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses, nil
+}
+
+// middleware
+func withCORS(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := allowedOrigin(r); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// writeJSON answers a deprecated route in its APIResponse shape, see
+// writeError for errors.
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.APIResponse{
+		Status: http.StatusText(statusCode),
+		Data:   data,
+	})
+}
@@ -0,0 +1,60 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// TestAuditListTotal pages through a filtered audit log, the total counts
+// every match and not just the page.
+func TestAuditListTotal(t *testing.T) {
+	for _, action := range []string{"test.total", "test.other", "test.total", "test.total"} {
+		cluster.Store.CreateAuditEvent(&models.AuditEvent{Principal: "alice", Action: action, Outcome: "success"})
+	}
+
+	status, raw := call("POST", cluster.APIURL+"/audit/list", []byte(`{"action":"test.total","page":2,"page_size":2}`), true)
+	if status != http.StatusOK {
+		t.Fatalf("POST /audit/list: %d %s", status, raw)
+	}
+	var listed struct {
+		Data struct {
+			ListData []models.AuditEvent `json:"list_data"`
+			Total    int                 `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if listed.Data.Total != 3 || len(listed.Data.ListData) != 1 {
+		t.Errorf("got %d events of %d, want 1 of 3", len(listed.Data.ListData), listed.Data.Total)
+	}
+}
+
+// TestAuditListPages checks the legacy and the /v1 audit routes agree on
+// totals and reject the same out of range pages.
+func TestAuditListPages(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		cluster.Store.CreateAuditEvent(&models.AuditEvent{Principal: "alice", Action: "test.pages", Outcome: "success"})
+	}
+
+	status, raw := call("GET", cluster.APIURL+"/v1/audit?action=test.pages&page_size=2", nil, true)
+	var env models.APIEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || env.Total == nil || *env.Total != 3 {
+		t.Errorf("GET /v1/audit: %d, total %v", status, env.Total)
+	}
+
+	for _, body := range []string{`{"page_size":1000}`, `{"page":-1}`} {
+		if status, _ := call("POST", cluster.APIURL+"/audit/list", []byte(body), true); status != http.StatusBadRequest {
+			t.Errorf("POST /audit/list %s: %d, want 400", body, status)
+		}
+	}
+	if status, _ := call("GET", cluster.APIURL+"/v1/audit?page_size=1000", nil, true); status != http.StatusBadRequest {
+		t.Errorf("GET /v1/audit?page_size=1000: %d, want 400", status)
+	}
+}
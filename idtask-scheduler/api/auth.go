@@ -0,0 +1,250 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/auth"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	// authn checks every request, nil while API_AUTH is empty.
+	authn auth.Authenticator
+	// policy decides what authenticated principals may do.
+	policy *auth.Policy
+)
+
+func newPolicy() *auth.Policy {
+	p, err := auth.NewPolicy(configs.Config.RBACRoles, configs.Config.RBACBindings, configs.Config.RBACDefaultRoles)
+	if err != nil {
+		log.Fatalf("RBAC policy error: %v", err)
+	}
+	return p
+}
+
+// newAuthenticator builds the API_AUTH methods in the order listed.
+func newAuthenticator() auth.Authenticator {
+	var chain auth.Chain
+	for _, method := range strings.Split(configs.Config.APIAuth, ",") {
+		switch strings.TrimSpace(method) {
+		case "":
+		case "apikey":
+			chain = append(chain, auth.APIKeys{})
+		case "jwt":
+			j, err := auth.NewJWT(configs.Config.JWTJWKSFile, configs.Config.JWTIssuer, configs.Config.JWTAudience, configs.Config.JWTRolesClaim)
+			if err != nil {
+				log.Fatalf("JWT auth error: %v", err)
+			}
+			chain = append(chain, j)
+		default:
+			log.Fatalf("Unknown API_AUTH method %q", method)
+		}
+	}
+	if len(chain) == 0 {
+		log.Println("⚠️ API authentication is off, set API_AUTH to enable it")
+		return nil
+	}
+
+	if key := configs.Config.APIBootstrapKey; key != "" {
+		if err := auth.EnsureKey(uuid.New().String(), "bootstrap", "bootstrap", key, []string{"admin"}); err != nil {
+			log.Fatalf("Failed to store bootstrap API key: %v", err)
+		}
+	}
+	log.Printf("🔐 API authentication enabled: %s", configs.Config.APIAuth)
+	return chain
+}
+
+// withAuth resolves the request's principal before h runs, answering 401
+// when the credentials are missing or wrong.
+func withAuth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, e := authenticate(r)
+		if e != nil {
+			writeError(w, r, e)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// authenticate returns r with its principal attached, Anonymous while
+// authentication is off.
+func authenticate(r *http.Request) (*http.Request, *apiError) {
+	if authn == nil {
+		return r.WithContext(auth.WithPrincipal(r.Context(), auth.Anonymous)), nil
+	}
+
+	p, err := authn.Authenticate(r)
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		monitor.ApiAuthFailures().WithLabelValues("missing").Inc()
+		e := newError(http.StatusUnauthorized, codeUnauthenticated, "Authentication required")
+		e.challenge = "Bearer"
+		return r, e
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		monitor.ApiAuthFailures().WithLabelValues("invalid").Inc()
+		e := newError(http.StatusUnauthorized, codeUnauthenticated, "Invalid credentials")
+		e.challenge = `Bearer error="invalid_token"`
+		return r, e
+	case err != nil:
+		monitor.ApiAuthFailures().WithLabelValues("error").Inc()
+		log.Printf("❌ Authentication failed: %v", err)
+		return r, newError(http.StatusInternalServerError, codeInternal, "Authentication failed")
+	}
+	return r.WithContext(auth.WithPrincipal(r.Context(), p)), nil
+}
+
+// withPermission lets only principals allowed perm across every tenant
+// run h, for operations that are not about one tenant's tasks. It goes
+// inside withAuth.
+func withPermission(perm auth.Permission, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorize(w, r, perm, auth.AnyTenant) {
+			h(w, r)
+		}
+	}
+}
+
+// authorize reports whether the request's principal may perform perm on
+// tenant's tasks, answering 403 if not.
+func authorize(w http.ResponseWriter, r *http.Request, perm auth.Permission, tenant string) bool {
+	if e := permit(r, perm, tenant); e != nil {
+		writeError(w, r, e)
+		return false
+	}
+	return true
+}
+
+// permit is authorize for callers that write the error themselves.
+func permit(r *http.Request, perm auth.Permission, tenant string) *apiError {
+	p := principal(r)
+	if policy.Allowed(p, perm, tenant) {
+		return nil
+	}
+	monitor.ApiAccessDenied().WithLabelValues(string(perm)).Inc()
+	log.Printf("🚫 %s denied %s on tenant %s", p.Subject, perm, tenant)
+	return newError(http.StatusForbidden, codeForbidden, "Missing permission "+string(perm)+" on tenant "+tenant)
+}
+
+// principal is who the request acts as, see withAuth.
+func principal(r *http.Request) *auth.Principal {
+	return auth.FromContext(r.Context())
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin for the request's
+// origin, empty if CORS_ALLOWED_ORIGINS does not list it.
+func allowedOrigin(r *http.Request) string {
+	origin := r.Header.Get("Origin")
+	for _, o := range strings.Split(configs.Config.CORSAllowedOrigins, ",") {
+		o = strings.TrimSpace(o)
+		if o == "*" {
+			return "*"
+		}
+		if o != "" && o == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// createKey issues a new API key. The key is only in the returned value,
+// storage keeps its hash.
+func createKey(r *http.Request, req models.APIKeyRequest) (*models.APIKey, *apiError) {
+	if req.Subject == "" && req.Name != "" {
+		req.Subject = "key:" + req.Name
+	}
+	params := map[string]any{"name": req.Name, "subject": req.Subject, "roles": req.Roles}
+	auditTarget(r, "", params)
+
+	var errs []models.FieldError
+	if req.Name == "" {
+		errs = append(errs, models.FieldError{Field: "name", Message: "is required"})
+	}
+	for _, role := range req.Roles {
+		if !policy.ValidGrant(auth.ParseGrant(role)) {
+			errs = append(errs, models.FieldError{Field: "roles", Message: "unknown role " + role})
+		}
+	}
+	if errs != nil {
+		return nil, invalid(errs)
+	}
+
+	k, err := newKey(r)
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to generate key")
+	}
+	k.Name, k.Subject, k.Roles = req.Name, req.Subject, req.Roles
+	auditTarget(r, k.ID, params)
+	if err := storage.CreateAPIKey(k); err != nil {
+		log.Printf("Failed to store API key: %v", err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to store key")
+	}
+
+	log.Printf("🔑 API key %s (%s) created by %s", k.ID, k.Name, k.CreatedBy)
+	return k, nil
+}
+
+// rotateKey revokes a key and issues a new one with the same name,
+// subject and roles.
+func rotateKey(r *http.Request, id string) (*models.APIKey, *apiError) {
+	k, err := newKey(r)
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to generate key")
+	}
+	auditTarget(r, id, map[string]string{"new_id": k.ID})
+	err = storage.RotateAPIKey(id, k)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No active key with that id")
+	}
+	if err != nil {
+		log.Printf("Failed to rotate API key: %v", err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to rotate key")
+	}
+
+	log.Printf("🔑 API key %s rotated to %s by %s", id, k.ID, k.CreatedBy)
+	return k, nil
+}
+
+func revokeKey(r *http.Request, id string) *apiError {
+	auditTarget(r, id, nil)
+	err := storage.RevokeAPIKey(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return newError(http.StatusNotFound, codeNotFound, "No active key with that id")
+	}
+	if err != nil {
+		log.Printf("Failed to revoke API key: %v", err)
+		return newError(http.StatusInternalServerError, codeInternal, "Failed to revoke key")
+	}
+
+	log.Printf("🔑 API key %s revoked by %s", id, principal(r).Subject)
+	return nil
+}
+
+func listKeys() ([]models.APIKey, *apiError) {
+	keys, err := storage.ListAPIKeys()
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch keys")
+	}
+	return keys, nil
+}
+
+// newKey generates a key issued by the request's principal.
+func newKey(r *http.Request) (*models.APIKey, error) {
+	key, hash, err := auth.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	return &models.APIKey{
+		ID:        uuid.New().String(),
+		Hash:      hash,
+		Key:       key,
+		CreatedBy: principal(r).Subject,
+	}, nil
+}
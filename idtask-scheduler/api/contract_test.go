@@ -0,0 +1,371 @@
+package api_test
+
+// The contract tests check that openapi.json and the API agree. They compare
+// the documented operations with the routes the API registers and the
+// documented schemas with the models types. They then call every operation
+// on an embedded cluster, checking the statuses and envelopes against the
+// document, and run task flows through the client package.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/api"
+	"github.com/JamesDante/idtask-scheduler/client"
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/embedded"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+const (
+	bootstrapKey = "idt_contract"
+	// offloadBytes is the payload size above which the cluster moves
+	// payloads to its blob store.
+	offloadBytes = 4096
+)
+
+// cluster is shared by every test, only one may run per process.
+var cluster *embedded.Cluster
+
+func TestMain(m *testing.M) {
+	configs.InitConfig()
+	var err error
+	cluster, err = embedded.Start(embedded.Options{
+		APIAuth:             "apikey",
+		APIBootstrapKey:     bootstrapKey,
+		PayloadOffloadBytes: offloadBytes,
+		TenantQuotas:        "quota-test:queued=1",
+		GRPCAddr:            "127.0.0.1:0",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := m.Run()
+	cluster.Close()
+	os.Exit(code)
+}
+
+// schemaTypes binds the document's schemas to the types they describe.
+var schemaTypes = map[string]reflect.Type{
+	"Task":              reflect.TypeOf(models.Task{}),
+	"TaskPrediction":    reflect.TypeOf(models.TaskPrediction{}),
+	"SchedulerStatus":   reflect.TypeOf(models.SchedulerStatus{}),
+	"WorkerStatus":      reflect.TypeOf(models.WorkerStatus{}),
+	"APIKey":            reflect.TypeOf(models.APIKey{}),
+	"APIKeyRequest":     reflect.TypeOf(models.APIKeyRequest{}),
+	"AuditEvent":        reflect.TypeOf(models.AuditEvent{}),
+	"TaskSchema":        reflect.TypeOf(models.TaskSchema{}),
+	"TaskSchemaRequest": reflect.TypeOf(models.TaskSchemaRequest{}),
+	"APIError":          reflect.TypeOf(models.APIError{}),
+	"FieldError":        reflect.TypeOf(models.FieldError{}),
+}
+
+type spec struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string            `json:"operationId"`
+	Security    []json.RawMessage `json:"security"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Example json.RawMessage `json:"example"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+// public reports whether the operation overrides the global security
+// with none.
+func (op operation) public() bool {
+	return op.Security != nil && len(op.Security) == 0
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	var doc spec
+	if err := json.Unmarshal(api.OpenAPI, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return &doc
+}
+
+func newClient() *client.Client {
+	return client.New(cluster.APIURL, client.WithAPIKey(bootstrapKey))
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// TestOpenAPIRoutes compares the documented operations with the registered ones.
+func TestOpenAPIRoutes(t *testing.T) {
+	var documented []string
+	for path, ops := range loadSpec(t).Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	diff(t, "route", documented, api.Routes())
+}
+
+// TestOpenAPISchemas compares each schema's properties with its type's JSON fields.
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadSpec(t)
+	for name, typ := range schemaTypes {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is not documented", name)
+			continue
+		}
+		documented := make([]string, 0, len(schema.Properties))
+		for prop := range schema.Properties {
+			documented = append(documented, prop)
+		}
+		diff(t, "field of "+name, documented, jsonFields(typ))
+	}
+}
+
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+			continue
+		case name == "":
+			name = f.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// pathParams fills in path parameters with values that do not exist.
+var pathParams = strings.NewReplacer("{id}", "no-such-id", "{type}", "no-such-type")
+
+// TestOpenAPIOperations calls every operation with its example body and
+// path parameters that do not exist, with and without credentials.
+func TestOpenAPIOperations(t *testing.T) {
+	for path, ops := range loadSpec(t).Paths {
+		for method, op := range ops {
+			method = strings.ToUpper(method)
+			url := cluster.APIURL + pathParams.Replace(path)
+			var body []byte
+			if op.RequestBody != nil {
+				body = op.RequestBody.Content["application/json"].Example
+			}
+
+			status, raw := call(method, url, body, true)
+			checkResponse(t, op, status, raw)
+			if !op.public() {
+				status, raw = call(method, url, body, false)
+				if status != http.StatusUnauthorized {
+					t.Errorf("%s without credentials answered %d, not 401", op.OperationID, status)
+				}
+				checkResponse(t, op, status, raw)
+			}
+		}
+	}
+}
+
+func call(method, url string, body []byte, authenticated bool) (int, []byte) {
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	if authenticated {
+		req.Header.Set("X-API-Key", bootstrapKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, []byte(err.Error())
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, raw
+}
+
+// checkResponse checks the status is documented and the body is the
+// envelope that status calls for.
+func checkResponse(t *testing.T, op operation, status int, raw []byte) {
+	t.Helper()
+	if _, ok := op.Responses[strconv.Itoa(status)]; !ok {
+		t.Errorf("%s answered undocumented %d: %s", op.OperationID, status, raw)
+		return
+	}
+	if op.OperationID == "getOpenAPI" {
+		return
+	}
+
+	var env models.APIEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Errorf("%s answered %d without an envelope: %s", op.OperationID, status, raw)
+		return
+	}
+	switch {
+	case status < 400 && env.Data == nil:
+		t.Errorf("%s answered %d without data: %s", op.OperationID, status, raw)
+	case status >= 400 && (env.Error == nil || env.Error.Code == ""):
+		t.Errorf("%s answered %d without an error code: %s", op.OperationID, status, raw)
+	}
+}
+
+// TestClientFlow runs a task and key flow through the client package.
+func TestClientFlow(t *testing.T) {
+	ctx := testContext(t)
+	c := newClient()
+
+	done, err := c.SubmitAndWait(ctx, &models.Task{Type: "contract", Payload: json.RawMessage(`"run"`)}, 200*time.Millisecond)
+	if err != nil {
+		t.Errorf("SubmitAndWait: %v", err)
+	} else if done.Status != "Completed" {
+		t.Errorf("SubmitAndWait: task ended %q", done.Status)
+	}
+
+	later := time.Now().Add(time.Hour)
+	delayed, err := c.SubmitTask(ctx, &models.Task{Type: "contract", ScheduledAt: &later})
+	if err != nil {
+		t.Errorf("SubmitTask: %v", err)
+	} else if cancelled, err := c.CancelTask(ctx, delayed.ID); err != nil || cancelled.Status != "Cancelled" {
+		t.Errorf("CancelTask: %v", err)
+	} else if _, err := c.CancelTask(ctx, delayed.ID); client.ErrorCode(err) != "task_finished" {
+		t.Errorf("CancelTask twice: %v", err)
+	}
+
+	if _, total, err := c.ListTasks(ctx, client.ListOptions{PageSize: 1}); err != nil || total < 2 {
+		t.Errorf("ListTasks: total %d: %v", total, err)
+	}
+	if _, err := c.SubmitTask(ctx, &models.Task{}); client.ErrorCode(err) != "validation_failed" {
+		t.Errorf("SubmitTask without a type: %v", err)
+	}
+
+	key, err := c.CreateKey(ctx, models.APIKeyRequest{Name: "contract", Roles: models.Roles{"viewer"}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if _, err := client.New(cluster.APIURL, client.WithAPIKey(key.Key)).SubmitTask(ctx, &models.Task{Type: "contract"}); client.ErrorCode(err) != "forbidden" {
+		t.Errorf("SubmitTask as a viewer: %v", err)
+	}
+	rotated, err := c.RotateKey(ctx, key.ID)
+	if err != nil {
+		t.Errorf("RotateKey: %v", err)
+	} else if err := c.RevokeKey(ctx, rotated.ID); err != nil {
+		t.Errorf("RevokeKey: %v", err)
+	}
+	if events, err := c.AuditEvents(ctx, models.AuditQuery{Action: "key.rotate", Target: key.ID}); err != nil || len(events) != 1 {
+		t.Errorf("AuditEvents: %d events: %v", len(events), err)
+	}
+	if _, err := c.Workers(ctx); err != nil {
+		t.Errorf("Workers: %v", err)
+	}
+	if _, err := c.Schedulers(ctx); err != nil {
+		t.Errorf("Schedulers: %v", err)
+	}
+}
+
+// TestSchemaFlow registers a payload schema and submits tasks against it.
+func TestSchemaFlow(t *testing.T) {
+	ctx := testContext(t)
+	c := newClient()
+
+	schema := json.RawMessage(`{"type":"object","required":["image"],"properties":{"image":{"type":"string"},"width":{"type":"integer","minimum":1}}}`)
+	for range 2 {
+		if _, err := c.RegisterSchema(ctx, models.TaskSchemaRequest{Type: "contract-schema", Schema: schema}); err != nil {
+			t.Fatalf("RegisterSchema: %v", err)
+		}
+	}
+	if ts, err := c.GetSchema(ctx, "contract-schema", 0); err != nil || ts.Version != 2 {
+		t.Errorf("GetSchema: %v", err)
+	}
+	if versions, err := c.SchemaVersions(ctx, "contract-schema"); err != nil || len(versions) != 2 {
+		t.Errorf("SchemaVersions: %d versions: %v", len(versions), err)
+	}
+	if _, err := c.RegisterSchema(ctx, models.TaskSchemaRequest{Type: "contract-schema", Schema: json.RawMessage(`{"type":"picture"}`)}); client.ErrorCode(err) != "validation_failed" {
+		t.Errorf("RegisterSchema with an unknown type: %v", err)
+	}
+
+	_, err := c.SubmitTask(ctx, &models.Task{Type: "contract-schema", Payload: json.RawMessage(`{"width":0}`)})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "validation_failed" || len(apiErr.Details) != 2 ||
+		apiErr.Details[0].Field != "payload.image" || apiErr.Details[1].Field != "payload.width" {
+		t.Errorf("SubmitTask with an invalid payload: %v", err)
+	}
+	if _, err := c.SubmitTask(ctx, &models.Task{Type: "contract-schema", Payload: json.RawMessage(`{"image":"a.png","width":64}`)}); err != nil {
+		t.Errorf("SubmitTask with a valid payload: %v", err)
+	}
+
+	filtered, total, err := c.ListTasks(ctx, client.ListOptions{PayloadFilters: []string{`$.image == "a.png"`, `$.width ? (@ > 10)`}})
+	if err != nil || total != 1 || len(filtered) != 1 || string(filtered[0].Payload) != `{"image":"a.png","width":64}` {
+		t.Errorf("ListTasks by payload: total %d: %v", total, err)
+	}
+	if _, _, err := c.ListTasks(ctx, client.ListOptions{PayloadFilters: []string{`$.image ==`}}); client.ErrorCode(err) != "validation_failed" {
+		t.Errorf("ListTasks by a malformed payload filter: %v", err)
+	}
+}
+
+// TestPayloadOffload submits a payload too large for the queues and expects
+// it back whole, with its blob deleted once the task completed.
+func TestPayloadOffload(t *testing.T) {
+	ctx := testContext(t)
+
+	payload := json.RawMessage(`{"data":"` + strings.Repeat("x", 2*offloadBytes) + `"}`)
+	done, err := newClient().SubmitAndWait(ctx, &models.Task{Type: "contract", Payload: payload}, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("SubmitAndWait with a large payload: %v", err)
+	}
+	if done.Status != "Completed" {
+		t.Errorf("large payload task ended %q", done.Status)
+	}
+	if string(done.Payload) != string(payload) || done.PayloadRef != "" {
+		t.Error("large payload task does not return its full payload")
+	}
+	if n := cluster.Blobs.Len(); n != 0 {
+		t.Errorf("%d offloaded payloads left after their tasks finished", n)
+	}
+}
+
+// diff reports what is documented but not in the code and the reverse.
+func diff(t *testing.T, what string, documented, actual []string) {
+	t.Helper()
+	has := func(list []string, s string) bool {
+		for _, v := range list {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	var problems []string
+	for _, d := range documented {
+		if !has(actual, d) {
+			problems = append(problems, what+" "+strconv.Quote(d)+" is documented but does not exist")
+		}
+	}
+	for _, a := range actual {
+		if !has(documented, a) {
+			problems = append(problems, what+" "+strconv.Quote(a)+" is not documented")
+		}
+	}
+	sort.Strings(problems)
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// Error codes clients can match on, next to the HTTP status.
+const (
+	codeInvalidJSON      = "invalid_json"
+	codeValidation       = "validation_failed"
+	codeUnauthenticated  = "unauthenticated"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeTaskFinished     = "task_finished"
+	codeTaskNotFailed    = "task_not_failed"
+	codePayloadTooLarge  = "payload_too_large"
+	codeQuotaExceeded    = "quota_exceeded"
+	codeRateLimited      = "rate_limited"
+	codeOverloaded       = "overloaded"
+	codeInternal         = "internal"
+	codeUnavailable      = "unavailable"
+)
+
+// apiError is a failed request, written by writeError in the envelope of
+// the API version the request came in on.
+type apiError struct {
+	status     int
+	code       string
+	message    string
+	details    []models.FieldError
+	retryAfter time.Duration
+	// challenge is the WWW-Authenticate of a 401.
+	challenge string
+}
+
+func newError(status int, code, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+// invalid is a 400 listing the fields that failed validation.
+func invalid(details []models.FieldError) *apiError {
+	e := newError(http.StatusBadRequest, codeValidation, "Request validation failed")
+	e.details = details
+	return e
+}
+
+// writeError answers with e, as {"error": {...}} under /v1 and in the
+// APIResponse shape on the deprecated routes.
+func writeError(w http.ResponseWriter, r *http.Request, e *apiError) {
+	setRetryAfter(w, e.retryAfter)
+	if e.challenge != "" {
+		w.Header().Set("WWW-Authenticate", e.challenge)
+	}
+	if !isV1(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.status)
+		json.NewEncoder(w).Encode(models.APIResponse{
+			Status:  http.StatusText(e.status),
+			Error:   e.message,
+			Code:    e.code,
+			Details: e.details,
+		})
+		return
+	}
+	writeEnvelope(w, e.status, models.APIEnvelope{Error: &models.APIError{
+		Code:    e.code,
+		Message: e.message,
+		Details: e.details,
+	}})
+}
+
+// writeData answers a /v1 request with data. total is only set for lists.
+func writeData(w http.ResponseWriter, status int, data interface{}, total *int) {
+	writeEnvelope(w, status, models.APIEnvelope{Data: data, Total: total})
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, env models.APIEnvelope) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(env)
+}
+
+func isV1(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/v1/")
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/api/taskpb"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	maxBatchSize = 100
+	// watchInterval is how often WatchTask looks for a status change.
+	watchInterval = 500 * time.Millisecond
+)
+
+type requestKey struct{}
+
+// NewGRPCServer serves TaskService over the same storage, queues, checks
+// and audit log as the HTTP routes. NewHandler must run first, it sets up
+// what both share.
+func NewGRPCServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcAuthUnary),
+		grpc.StreamInterceptor(grpcAuthStream),
+	)
+	taskpb.RegisterTaskServiceServer(s, &taskServer{})
+	return s
+}
+
+// grpcRequest stands in an *http.Request for a gRPC call, with the call's
+// metadata as headers, so authenticators and the functions behind the
+// HTTP handlers serve it unchanged.
+func grpcRequest(ctx context.Context, fullMethod string) *http.Request {
+	r := (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: fullMethod},
+		Header: make(http.Header),
+	}).WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	return r
+}
+
+// authenticateCall is withAuth for gRPC. The handlers find the request
+// in the returned context.
+func authenticateCall(ctx context.Context, fullMethod string) (context.Context, error) {
+	r, e := authenticate(grpcRequest(ctx, fullMethod))
+	if e != nil {
+		return nil, grpcError(e)
+	}
+	return context.WithValue(r.Context(), requestKey{}, r), nil
+}
+
+func grpcAuthUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticateCall(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcAuthStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticateCall(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
+
+func requestOf(ctx context.Context) *http.Request {
+	return ctx.Value(requestKey{}).(*http.Request)
+}
+
+// grpcCodes maps the HTTP statuses of apiError to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+}
+
+// grpcError converts e to a status with the /v1 error code as ErrorInfo
+// reason, the invalid fields as BadRequest and Retry-After as RetryInfo.
+func grpcError(e *apiError) error {
+	code, ok := grpcCodes[e.status]
+	if !ok {
+		code = codes.Internal
+	}
+	st := status.New(code, e.message)
+
+	withDetails := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: e.code, Domain: "idtask"}}
+	if len(e.details) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, d := range e.details {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: d.Field, Description: d.Message})
+		}
+		withDetails = append(withDetails, br)
+	}
+	if e.retryAfter > 0 {
+		withDetails = append(withDetails, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.retryAfter)})
+	}
+	if detailed, err := st.WithDetails(withDetails...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+type taskServer struct {
+	taskpb.UnimplementedTaskServiceServer
+}
+
+func (s *taskServer) Submit(ctx context.Context, req *taskpb.SubmitRequest) (*taskpb.Task, error) {
+	t := taskFromPB(req.GetTask())
+	if e := audited(requestOf(ctx), "task.submit", func(r *http.Request) *apiError {
+		return submitTask(r, t, t.ScheduledAt != nil)
+	}); e != nil {
+		return nil, grpcError(e)
+	}
+	return taskToPB(t), nil
+}
+
+func (s *taskServer) BatchSubmit(ctx context.Context, req *taskpb.BatchSubmitRequest) (*taskpb.BatchSubmitResponse, error) {
+	if len(req.GetTasks()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d tasks per batch", maxBatchSize)
+	}
+
+	resp := &taskpb.BatchSubmitResponse{Results: make([]*taskpb.SubmitResult, 0, len(req.GetTasks()))}
+	for _, pt := range req.GetTasks() {
+		t := taskFromPB(pt)
+		e := audited(requestOf(ctx), "task.submit", func(r *http.Request) *apiError {
+			return submitTask(r, t, t.ScheduledAt != nil)
+		})
+		if e != nil {
+			resp.Results = append(resp.Results, &taskpb.SubmitResult{Result: &taskpb.SubmitResult_Error{Error: errorToPB(e)}})
+			continue
+		}
+		resp.Results = append(resp.Results, &taskpb.SubmitResult{Result: &taskpb.SubmitResult_Task{Task: taskToPB(t)}})
+	}
+	return resp, nil
+}
+
+func (s *taskServer) Get(ctx context.Context, req *taskpb.GetRequest) (*taskpb.Task, error) {
+	t, e := getTask(requestOf(ctx), req.GetId())
+	if e != nil {
+		return nil, grpcError(e)
+	}
+	return taskToPB(t), nil
+}
+
+func (s *taskServer) Cancel(ctx context.Context, req *taskpb.CancelRequest) (*taskpb.Task, error) {
+	var t *models.Task
+	if e := audited(requestOf(ctx), "task.cancel", func(r *http.Request) (e *apiError) {
+		t, e = cancelTask(r, req.GetId())
+		return e
+	}); e != nil {
+		return nil, grpcError(e)
+	}
+	return taskToPB(t), nil
+}
+
+func (s *taskServer) List(ctx context.Context, req *taskpb.ListRequest) (*taskpb.ListResponse, error) {
+	lr := models.APIListRequest{
+		Page:           int(req.GetPage()),
+		PageSize:       int(req.GetPageSize()),
+		Tenant:         req.GetTenant(),
+		PayloadFilters: req.GetPayloadFilters(),
+	}
+	tasks, total, e := listTasks(requestOf(ctx), &lr)
+	if e != nil {
+		return nil, grpcError(e)
+	}
+
+	resp := &taskpb.ListResponse{Tasks: make([]*taskpb.Task, 0, len(tasks)), Total: int32(total)}
+	for i := range tasks {
+		resp.Tasks = append(resp.Tasks, taskToPB(&tasks[i]))
+	}
+	return resp, nil
+}
+
+func (s *taskServer) WatchTask(req *taskpb.WatchTaskRequest, stream taskpb.TaskService_WatchTaskServer) error {
+	ctx := stream.Context()
+	t, e := getTask(requestOf(ctx), req.GetId())
+	if e != nil {
+		return grpcError(e)
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	var sent *models.Task
+	for {
+		if sent == nil || t.Status != sent.Status {
+			if err := stream.Send(taskToPB(t)); err != nil {
+				return err
+			}
+			sent = t
+		}
+		if models.TaskFinished(t.Status) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+		next, err := storage.GetTask(req.GetId())
+		if errors.Is(err, storage.ErrNotFound) {
+			return status.Error(codes.NotFound, "Task no longer exists")
+		}
+		if err == nil {
+			t = next
+		}
+	}
+}
+
+func taskFromPB(p *taskpb.Task) *models.Task {
+	t := &models.Task{
+		Type:            p.GetType(),
+		Payload:         payloadFromPB(p.GetPayload()),
+		Tenant:          p.GetTenant(),
+		Key:             p.GetKey(),
+		ExpireAt:        timeFromPB(p.GetExpireAt()),
+		ScheduledAt:     timeFromPB(p.GetScheduledAt()),
+		RequiredLabels:  p.GetRequiredLabels(),
+		PreferredLabels: p.GetPreferredLabels(),
+	}
+	if p.Priority != nil {
+		t.Priority.Int64, t.Priority.Valid = p.GetPriority(), true
+	}
+	if p.MaxRetry != nil {
+		t.MaxRetry.Int64, t.MaxRetry.Valid = p.GetMaxRetry(), true
+	}
+	return t
+}
+
+func taskToPB(t *models.Task) *taskpb.Task {
+	p := &taskpb.Task{
+		Id:              t.ID,
+		Type:            t.Type,
+		Payload:         string(t.Payload),
+		Status:          t.Status,
+		Tenant:          models.TenantOf(t),
+		Key:             t.Key,
+		Retries:         t.Retries.Int64,
+		CreatedAt:       timeToPB(t.CreatedAt),
+		ExpireAt:        timeToPB(t.ExpireAt),
+		ScheduledAt:     timeToPB(t.ScheduledAt),
+		ExecutedBy:      t.ExecutedBy.String,
+		ExecutedAt:      timeToPB(t.ExecutedAt),
+		RequiredLabels:  t.RequiredLabels,
+		PreferredLabels: t.PreferredLabels,
+		CreatedBy:       t.CreatedBy,
+	}
+	if t.Priority.Valid {
+		p.Priority = &t.Priority.Int64
+	}
+	if t.MaxRetry.Valid {
+		p.MaxRetry = &t.MaxRetry.Int64
+	}
+	return p
+}
+
+// payloadFromPB takes the payload's JSON text, no payload if empty.
+func payloadFromPB(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+func errorToPB(e *apiError) *taskpb.Error {
+	p := &taskpb.Error{Code: e.code, Message: e.message}
+	for _, d := range e.details {
+		p.Details = append(p.Details, &taskpb.FieldError{Field: d.Field, Message: d.Message})
+	}
+	return p
+}
+
+func timeFromPB(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func timeToPB(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
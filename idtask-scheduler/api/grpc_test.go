@@ -0,0 +1,267 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/api/taskpb"
+	"github.com/JamesDante/idtask-scheduler/client"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTaskService connects to the cluster's TaskService.
+func newTaskService(t *testing.T) taskpb.TaskServiceClient {
+	t.Helper()
+	conn, err := grpc.NewClient(cluster.GRPCAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return taskpb.NewTaskServiceClient(conn)
+}
+
+func withKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+}
+
+// grpcFailure is what a gRPC error carries of the /v1 error envelope.
+type grpcFailure struct {
+	code   codes.Code
+	reason string
+	fields []string
+}
+
+func failureOf(err error) grpcFailure {
+	st := status.Convert(err)
+	f := grpcFailure{code: st.Code()}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			f.reason = d.Reason
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				f.fields = append(f.fields, v.Field)
+			}
+		}
+	}
+	return f
+}
+
+func httpFailure(err error) (int, string, []string) {
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		return 0, "", nil
+	}
+	var fields []string
+	for _, d := range apiErr.Details {
+		fields = append(fields, d.Field)
+	}
+	return apiErr.StatusCode, apiErr.Code, fields
+}
+
+// TestGRPCMatchesHTTP makes the same failing calls over gRPC and HTTP,
+// both must refuse them for the same reason.
+func TestGRPCMatchesHTTP(t *testing.T) {
+	ctx := testContext(t)
+	svc := newTaskService(t)
+	c := newClient()
+
+	viewer, err := c.CreateKey(ctx, models.APIKeyRequest{Name: "grpc-viewer", Roles: models.Roles{"viewer"}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	finished, err := c.SubmitTask(ctx, &models.Task{Type: "contract", ScheduledAt: &later})
+	if err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+	if _, err := c.CancelTask(ctx, finished.ID); err != nil {
+		t.Fatalf("CancelTask: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		grpcCall func(context.Context) error
+		httpCall func(*client.Client) error
+		key      string
+		status   int
+		code     codes.Code
+	}{
+		{
+			"submit without a type",
+			func(ctx context.Context) error {
+				_, err := svc.Submit(ctx, &taskpb.SubmitRequest{Task: &taskpb.Task{Tenant: "Not A Tenant"}})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.SubmitTask(ctx, &models.Task{Tenant: "Not A Tenant"})
+				return err
+			},
+			bootstrapKey, http.StatusBadRequest, codes.InvalidArgument,
+		},
+		{
+			"submit as a viewer",
+			func(ctx context.Context) error {
+				_, err := svc.Submit(ctx, &taskpb.SubmitRequest{Task: &taskpb.Task{Type: "contract"}})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.SubmitTask(ctx, &models.Task{Type: "contract"})
+				return err
+			},
+			viewer.Key, http.StatusForbidden, codes.PermissionDenied,
+		},
+		{
+			"submit without credentials",
+			func(ctx context.Context) error {
+				_, err := svc.Submit(ctx, &taskpb.SubmitRequest{Task: &taskpb.Task{Type: "contract"}})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.SubmitTask(ctx, &models.Task{Type: "contract"})
+				return err
+			},
+			"", http.StatusUnauthorized, codes.Unauthenticated,
+		},
+		{
+			"get an unknown task",
+			func(ctx context.Context) error {
+				_, err := svc.Get(ctx, &taskpb.GetRequest{Id: "no-such-task"})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.GetTask(ctx, "no-such-task")
+				return err
+			},
+			bootstrapKey, http.StatusNotFound, codes.NotFound,
+		},
+		{
+			"cancel an unknown task",
+			func(ctx context.Context) error {
+				_, err := svc.Cancel(ctx, &taskpb.CancelRequest{Id: "no-such-task"})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.CancelTask(ctx, "no-such-task")
+				return err
+			},
+			bootstrapKey, http.StatusNotFound, codes.NotFound,
+		},
+		{
+			"cancel a finished task",
+			func(ctx context.Context) error {
+				_, err := svc.Cancel(ctx, &taskpb.CancelRequest{Id: finished.ID})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.CancelTask(ctx, finished.ID)
+				return err
+			},
+			bootstrapKey, http.StatusConflict, codes.FailedPrecondition,
+		},
+		{
+			"cancel as a viewer",
+			func(ctx context.Context) error {
+				_, err := svc.Cancel(ctx, &taskpb.CancelRequest{Id: finished.ID})
+				return err
+			},
+			func(c *client.Client) error {
+				_, err := c.CancelTask(ctx, finished.ID)
+				return err
+			},
+			viewer.Key, http.StatusForbidden, codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callCtx := ctx
+			var opts []client.Option
+			if tt.key != "" {
+				callCtx = withKey(ctx, tt.key)
+				opts = append(opts, client.WithAPIKey(tt.key))
+			}
+			httpStatus, httpCode, httpFields := httpFailure(tt.httpCall(client.New(cluster.APIURL, opts...)))
+			got := failureOf(tt.grpcCall(callCtx))
+
+			if httpStatus != tt.status {
+				t.Errorf("HTTP status %d, want %d", httpStatus, tt.status)
+			}
+			if got.code != tt.code {
+				t.Errorf("gRPC code %v, want %v", got.code, tt.code)
+			}
+			if got.reason != httpCode || fmt.Sprint(got.fields) != fmt.Sprint(httpFields) {
+				t.Errorf("gRPC refused with %q %v, HTTP with %q %v", got.reason, got.fields, httpCode, httpFields)
+			}
+		})
+	}
+}
+
+// TestGRPCTaskFlow submits, fetches and cancels tasks over gRPC and reads
+// them back over HTTP, which must see the same tasks.
+func TestGRPCTaskFlow(t *testing.T) {
+	ctx := testContext(t)
+	svc := newTaskService(t)
+	c := newClient()
+	authed := withKey(ctx, bootstrapKey)
+
+	submitted, err := svc.Submit(authed, &taskpb.SubmitRequest{Task: &taskpb.Task{Type: "contract", Payload: `{"from":"grpc"}`, Key: "k-1"}})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if submitted.GetId() == "" || submitted.GetTenant() != models.DefaultTenant || submitted.GetCreatedBy() != "bootstrap" {
+		t.Errorf("submitted task %+v", submitted)
+	}
+	done, err := c.WaitForTask(ctx, submitted.GetId(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForTask: %v", err)
+	}
+	got, err := svc.Get(authed, &taskpb.GetRequest{Id: submitted.GetId()})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.GetStatus() != done.Status || got.GetPayload() != string(done.Payload) || got.GetKey() != done.Key ||
+		got.GetExecutedBy() != done.ExecutedBy.String || got.GetCreatedBy() != done.CreatedBy {
+		t.Errorf("gRPC Get %+v, HTTP GET %+v", got, done)
+	}
+
+	delayed, err := svc.Submit(authed, &taskpb.SubmitRequest{Task: &taskpb.Task{
+		Type:        "contract",
+		ScheduledAt: timestamppb.New(time.Now().Add(time.Hour)),
+	}})
+	if err != nil {
+		t.Fatalf("Submit delayed: %v", err)
+	}
+	cancelled, err := svc.Cancel(authed, &taskpb.CancelRequest{Id: delayed.GetId()})
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.GetStatus() != "Cancelled" {
+		t.Errorf("cancelled task is %q", cancelled.GetStatus())
+	}
+	if task, err := c.GetTask(ctx, delayed.GetId()); err != nil || task.Status != "Cancelled" {
+		t.Errorf("HTTP GET of the cancelled task: %+v, %v", task, err)
+	}
+
+	events, err := c.AuditEvents(ctx, models.AuditQuery{Target: delayed.GetId()})
+	if err != nil {
+		t.Fatalf("AuditEvents: %v", err)
+	}
+	actions := make([]string, 0, len(events))
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	if fmt.Sprint(actions) != "[task.cancel task.submit]" {
+		t.Errorf("audited gRPC calls %v", actions)
+	}
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/JamesDante/idtask-scheduler/internal/auth"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// registerLegacy keeps the routes from before /v1, all POST, with their
+// old response shapes. They are deprecated aliases of the /v1 routes.
+func registerLegacy(mux *http.ServeMux) {
+	mux.HandleFunc("/tasks", withCORS(deprecated("/v1/tasks", withAuth(withAudit("task.submit", handleTaskSubmit)))))
+	mux.HandleFunc("/tasks/list", withCORS(deprecated("/v1/tasks", withAuth(handleTaskList))))
+	mux.HandleFunc("/delayedtasks", withCORS(deprecated("/v1/tasks", withAuth(withAudit("task.submit_delayed", handleDelayedTaskSubmit)))))
+	mux.HandleFunc("/scheduler/status", withCORS(deprecated("/v1/schedulers", withAuth(withPermission(auth.PermStatusView, getSchedulerStatus)))))
+	mux.HandleFunc("/worker/status", withCORS(deprecated("/v1/workers", withAuth(withPermission(auth.PermStatusView, getWorkerStatus)))))
+	mux.HandleFunc("/tasks/requeue", withCORS(deprecated("/v1/tasks/{id}/requeue", withAuth(withAudit("dlq.requeue", handleTaskRequeue)))))
+	mux.HandleFunc("/worker/drain", withCORS(deprecated("/v1/workers/{id}/drain", withAuth(withAudit("worker.drain", withPermission(auth.PermWorkerDrain, handleWorkerDrain))))))
+	mux.HandleFunc("/worker/undrain", withCORS(deprecated("/v1/workers/{id}/drain", withAuth(withAudit("worker.undrain", withPermission(auth.PermWorkerDrain, handleWorkerUndrain))))))
+	mux.HandleFunc("/keys", withCORS(deprecated("/v1/keys", withAuth(withAudit("key.create", withPermission(auth.PermKeyManage, handleKeyCreate))))))
+	mux.HandleFunc("/keys/list", withCORS(deprecated("/v1/keys", withAuth(withPermission(auth.PermKeyManage, handleKeyList)))))
+	mux.HandleFunc("/keys/rotate", withCORS(deprecated("/v1/keys/{id}/rotate", withAuth(withAudit("key.rotate", withPermission(auth.PermKeyManage, handleKeyRotate))))))
+	mux.HandleFunc("/keys/revoke", withCORS(deprecated("/v1/keys/{id}", withAuth(withAudit("key.revoke", withPermission(auth.PermKeyManage, handleKeyRevoke))))))
+	mux.HandleFunc("/audit/list", withCORS(deprecated("/v1/audit", withAuth(withPermission(auth.PermAuditView, handleAuditList)))))
+}
+
+// deprecated marks the response as coming from a deprecated route, with
+// a link to the one replacing it, and only lets POST through.
+func deprecated(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, r, newError(http.StatusMethodNotAllowed, codeMethodNotAllowed, "Only POST allowed"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// keyRequest is the body of the deprecated key routes, which take the id
+// of the key to rotate or revoke in it.
+type keyRequest struct {
+	ID string `json:"id"`
+	models.APIKeyRequest
+}
+
+// decode reads the request body into v, answering 400 if it is not JSON.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, r, newError(http.StatusBadRequest, codeInvalidJSON, "Invalid JSON: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func handleTaskList(w http.ResponseWriter, r *http.Request) {
+	var req models.APIListRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	tasks, total, e := listTasks(r, &req)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.APIListResponse{
+		Status:   "OK",
+		ListData: tasks,
+		Total:    total,
+	})
+}
+
+func handleTaskSubmit(w http.ResponseWriter, r *http.Request) {
+	var t models.Task
+	if !decode(w, r, &t) {
+		return
+	}
+	if e := submitTask(r, &t, false); e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func handleDelayedTaskSubmit(w http.ResponseWriter, r *http.Request) {
+	var t models.Task
+	if !decode(w, r, &t) {
+		return
+	}
+	if e := submitTask(r, &t, true); e != nil {
+		writeError(w, r, e)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"delayed task accepted"}`))
+}
+
+func getWorkerStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, e := workerStatuses()
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func getSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, e := schedulerStatuses()
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// idRequest names the task or worker an operator route acts on.
+type idRequest struct {
+	ID string `json:"id"`
+}
+
+// decodeID reads an idRequest, answering 400 without an id.
+func decodeID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req idRequest
+	if !decode(w, r, &req) {
+		return "", false
+	}
+	if req.ID == "" {
+		writeError(w, r, invalid([]models.FieldError{{Field: "id", Message: "is required"}}))
+		return "", false
+	}
+	return req.ID, true
+}
+
+func handleTaskRequeue(w http.ResponseWriter, r *http.Request) {
+	id, ok := decodeID(w, r)
+	if !ok {
+		return
+	}
+	t, e := requeueTask(r, id)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func handleWorkerDrain(w http.ResponseWriter, r *http.Request) {
+	id, ok := decodeID(w, r)
+	if !ok {
+		return
+	}
+	ws, e := drainWorker(r, id, true)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, ws)
+}
+
+func handleWorkerUndrain(w http.ResponseWriter, r *http.Request) {
+	id, ok := decodeID(w, r)
+	if !ok {
+		return
+	}
+	ws, e := drainWorker(r, id, false)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, ws)
+}
+
+func handleKeyCreate(w http.ResponseWriter, r *http.Request) {
+	var req keyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	k, e := createKey(r, req.APIKeyRequest)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, k)
+}
+
+func handleKeyList(w http.ResponseWriter, r *http.Request) {
+	keys, e := listKeys()
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func handleKeyRotate(w http.ResponseWriter, r *http.Request) {
+	var req keyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		writeError(w, r, invalid([]models.FieldError{{Field: "id", Message: "is required"}}))
+		return
+	}
+	k, e := rotateKey(r, req.ID)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, k)
+}
+
+func handleKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	var req keyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		writeError(w, r, invalid([]models.FieldError{{Field: "id", Message: "is required"}}))
+		return
+	}
+	if e := revokeKey(r, req.ID); e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": req.ID})
+}
+
+func handleAuditList(w http.ResponseWriter, r *http.Request) {
+	var q models.AuditQuery
+	if !decode(w, r, &q) {
+		return
+	}
+	events, total, e := listAudit(&q)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.APIListResponse{
+		Status:   "OK",
+		ListData: events,
+		Total:    total,
+	})
+}
@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/client"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// TestRequeueFailedTask fails a completed task by hand and requeues it,
+// the worker must run it again. Requeuing a task that has not failed is a
+// conflict.
+func TestRequeueFailedTask(t *testing.T) {
+	ctx := testContext(t)
+	c := newClient()
+
+	done, err := c.SubmitAndWait(ctx, &models.Task{Type: "contract"}, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("SubmitAndWait: %v", err)
+	}
+	if _, err := c.RequeueTask(ctx, done.ID); client.ErrorCode(err) != "task_not_failed" {
+		t.Errorf("RequeueTask of a completed task: %v", err)
+	}
+
+	cluster.Store.UpdateTasks(done.ID, "Failed")
+	requeued, err := c.RequeueTask(ctx, done.ID)
+	if err != nil {
+		t.Fatalf("RequeueTask: %v", err)
+	}
+	if requeued.Status != "Pending" || requeued.Retries.Int64 != done.Retries.Int64+1 {
+		t.Errorf("requeued task is %q with %d retries", requeued.Status, requeued.Retries.Int64)
+	}
+	again, err := c.WaitForTask(ctx, done.ID, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForTask: %v", err)
+	}
+	if again.Status != "Completed" {
+		t.Errorf("requeued task ended %q", again.Status)
+	}
+}
+
+// TestDrainWorker drains the cluster's only worker, a task submitted then
+// must wait until the worker is undrained.
+func TestDrainWorker(t *testing.T) {
+	ctx := testContext(t)
+	c := newClient()
+	id := cluster.Workers[0].ID
+
+	ws, err := c.DrainWorker(ctx, id)
+	if err != nil {
+		t.Fatalf("DrainWorker: %v", err)
+	}
+	if ws.Status != "draining" {
+		t.Errorf("drained worker is %q", ws.Status)
+	}
+	undrained := false
+	t.Cleanup(func() {
+		if !undrained {
+			c.UndrainWorker(ctx, id)
+		}
+	})
+
+	submitted, err := c.SubmitTask(ctx, &models.Task{Type: "contract"})
+	if err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+	time.Sleep(time.Second)
+	if task, err := c.GetTask(ctx, submitted.ID); err != nil || task.Status == "Completed" {
+		t.Errorf("task sent to a draining worker: %+v, %v", task, err)
+	}
+
+	if _, err := c.UndrainWorker(ctx, id); err != nil {
+		t.Fatalf("UndrainWorker: %v", err)
+	}
+	undrained = true
+	done, err := c.WaitForTask(ctx, submitted.ID, 200*time.Millisecond)
+	if err != nil || done.Status != "Completed" {
+		t.Errorf("task after undraining: %+v, %v", done, err)
+	}
+
+	if _, err := c.DrainWorker(ctx, "no-such-worker"); client.ErrorCode(err) != "not_found" {
+		t.Errorf("DrainWorker of an unknown worker: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JamesDante/idtask-scheduler/internal/schema"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/storage"
+)
+
+// compiled caches compiled schemas by "type@version". A version never
+// changes once registered, so entries never go stale.
+var compiled sync.Map
+
+func compiledSchema(ts *models.TaskSchema) (*schema.Schema, error) {
+	key := ts.Type + "@" + strconv.Itoa(ts.Version)
+	if s, ok := compiled.Load(key); ok {
+		return s.(*schema.Schema), nil
+	}
+	s, err := schema.Compile(ts.Schema)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store(key, s)
+	return s, nil
+}
+
+// validatePayload checks the payload against the latest schema of the
+// task's type, if one is registered, reporting violations under
+// "payload". A missing payload is checked as null.
+func validatePayload(t *models.Task) *apiError {
+	ts, err := storage.GetTaskSchema(t.Type, 0)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed to fetch schema of task type %s: %v", t.Type, err)
+		return newError(http.StatusInternalServerError, codeInternal, "Failed to fetch the task type's schema")
+	}
+	s, err := compiledSchema(ts)
+	if err != nil {
+		log.Printf("Stored schema %s v%d does not compile: %v", ts.Type, ts.Version, err)
+		return newError(http.StatusInternalServerError, codeInternal, "The task type's schema is invalid")
+	}
+
+	doc := t.Payload
+	if len(doc) == 0 {
+		doc = json.RawMessage("null")
+	}
+	violations := s.Validate(doc, "payload")
+	if len(violations) == 0 {
+		return nil
+	}
+	errs := make([]models.FieldError, len(violations))
+	for i, v := range violations {
+		errs[i] = models.FieldError{Field: v.Path, Message: v.Message}
+	}
+	e := invalid(errs)
+	e.message = "Payload does not match schema version " + strconv.Itoa(ts.Version) + " of task type " + t.Type
+	return e
+}
+
+// createSchema registers req.Schema as the next version of its type's
+// schema, after checking it compiles.
+func createSchema(r *http.Request, req models.TaskSchemaRequest) (*models.TaskSchema, *apiError) {
+	auditTarget(r, req.Type, nil)
+
+	var errs []models.FieldError
+	if strings.TrimSpace(req.Type) == "" {
+		errs = append(errs, models.FieldError{Field: "type", Message: "is required"})
+	}
+	if len(req.Schema) == 0 {
+		errs = append(errs, models.FieldError{Field: "schema", Message: "is required"})
+	} else if _, err := schema.Compile(req.Schema); err != nil {
+		errs = append(errs, models.FieldError{Field: "schema", Message: err.Error()})
+	}
+	if errs != nil {
+		return nil, invalid(errs)
+	}
+
+	ts := &models.TaskSchema{Type: req.Type, Schema: req.Schema, CreatedBy: principal(r).Subject}
+	if err := storage.CreateTaskSchema(ts); err != nil {
+		log.Printf("Failed to store schema of task type %s: %v", req.Type, err)
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to store schema")
+	}
+	auditTarget(r, ts.Type, map[string]any{"version": ts.Version})
+
+	log.Printf("📐 Schema v%d of task type %s registered by %s", ts.Version, ts.Type, ts.CreatedBy)
+	return ts, nil
+}
+
+// getSchema returns a version of a type's schema, the latest for 0.
+func getSchema(taskType string, version int) (*models.TaskSchema, *apiError) {
+	ts, err := storage.GetTaskSchema(taskType, version)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No such schema")
+	}
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch schema")
+	}
+	return ts, nil
+}
+
+// listSchemas returns the latest schema of every type, or every version
+// of one type's.
+func listSchemas(taskType string) ([]models.TaskSchema, *apiError) {
+	schemas, err := storage.ListTaskSchemas(taskType)
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, codeInternal, "Failed to fetch schemas")
+	}
+	if taskType != "" && len(schemas) == 0 {
+		return nil, newError(http.StatusNotFound, codeNotFound, "No schema for that task type")
+	}
+	return schemas, nil
+}
@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/client"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// TestSubmitIgnoresPayloadRef submits a task pointing at another task's
+// offloaded payload. The worker must run it with its own payload and leave
+// the other payload alone.
+func TestSubmitIgnoresPayloadRef(t *testing.T) {
+	ctx := testContext(t)
+
+	victim := []byte(`{"secret":"victim"}`)
+	if err := cluster.Blobs.Put(ctx, "victim-task", victim); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cluster.Blobs.Delete(context.Background(), "victim-task") })
+
+	done, err := newClient().SubmitAndWait(ctx, &models.Task{
+		Type:       "contract",
+		Payload:    json.RawMessage(`{"n":1}`),
+		PayloadRef: "victim-task",
+	}, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("SubmitAndWait: %v", err)
+	}
+	if done.Status != "Completed" || string(done.Payload) != `{"n":1}` {
+		t.Errorf("task ended %q with payload %s", done.Status, done.Payload)
+	}
+	if data, err := cluster.Blobs.Get(ctx, "victim-task"); err != nil || string(data) != string(victim) {
+		t.Errorf("the referenced payload was touched: %s, %v", data, err)
+	}
+}
+
+// TestSubmitDropsDispatchFields submits a task with the fields only the
+// scheduler sets, none of them may be queued.
+func TestSubmitDropsDispatchFields(t *testing.T) {
+	submitted, err := newClient().SubmitTask(testContext(t), &models.Task{
+		Type:         "contract",
+		Prediction:   &models.TaskPrediction{RecommendedWorker: "worker-chosen-by-client"},
+		FencingToken: 1 << 40,
+		LimitKey:     "limits:running:type:other",
+	})
+	if err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+	if submitted.Prediction != nil || submitted.FencingToken != 0 || submitted.LimitKey != "" {
+		t.Errorf("client-set dispatch fields kept: prediction %+v, fencing token %d, limit key %q",
+			submitted.Prediction, submitted.FencingToken, submitted.LimitKey)
+	}
+}
+
+// TestQueuedQuotaCountsDelayed fills tenant quota-test's queued quota of 1
+// with a delayed task, which must hold back the next submission until it is
+// cancelled.
+func TestQueuedQuotaCountsDelayed(t *testing.T) {
+	ctx := testContext(t)
+	c := newClient()
+
+	later := time.Now().Add(time.Hour)
+	delayed, err := c.SubmitTask(ctx, &models.Task{Type: "contract", Tenant: "quota-test", ScheduledAt: &later})
+	if err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+	once := client.New(cluster.APIURL, client.WithAPIKey(bootstrapKey), client.WithRetries(0, 0))
+	if _, err := once.SubmitTask(ctx, &models.Task{Type: "contract", Tenant: "quota-test"}); client.ErrorCode(err) != "quota_exceeded" {
+		t.Errorf("SubmitTask over the queued quota: %v", err)
+	}
+
+	if _, err := c.CancelTask(ctx, delayed.ID); err != nil {
+		t.Fatalf("CancelTask: %v", err)
+	}
+	if _, err := c.SubmitAndWait(ctx, &models.Task{Type: "contract", Tenant: "quota-test"}, 200*time.Millisecond); err != nil {
+		t.Errorf("SubmitAndWait after cancelling: %v", err)
+	}
+	if _, err := c.SubmitTask(ctx, &models.Task{Type: "contract", Tenant: "quota-test"}); err != nil {
+		t.Errorf("SubmitTask once the quota's tasks finished: %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/internal/auth"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// v1Routes lists the registered /v1 operations as "METHOD /path", for
+// checking them against openapi.json.
+var v1Routes []string
+
+// Routes returns the /v1 operations NewHandler registers, sorted.
+func Routes() []string {
+	routes := append([]string(nil), v1Routes...)
+	sort.Strings(routes)
+	return routes
+}
+
+// registerV1 adds the /v1 routes. Every response, errors included, is a
+// models.APIEnvelope, except for the OpenAPI document describing them.
+func registerV1(mux *http.ServeMux) {
+	v1Routes = nil
+	route(mux, "/v1/tasks", map[string]http.HandlerFunc{
+		http.MethodGet:  withAuth(v1ListTasks),
+		http.MethodPost: withAuth(withAudit("task.submit", v1SubmitTask)),
+	})
+	route(mux, "/v1/tasks/{id}", map[string]http.HandlerFunc{
+		http.MethodGet:    withAuth(v1GetTask),
+		http.MethodDelete: withAuth(withAudit("task.cancel", v1CancelTask)),
+	})
+	route(mux, "/v1/tasks/{id}/requeue", map[string]http.HandlerFunc{
+		http.MethodPost: withAuth(withAudit("dlq.requeue", v1RequeueTask)),
+	})
+	route(mux, "/v1/schedulers", map[string]http.HandlerFunc{
+		http.MethodGet: withAuth(withPermission(auth.PermStatusView, v1Schedulers)),
+	})
+	route(mux, "/v1/workers", map[string]http.HandlerFunc{
+		http.MethodGet: withAuth(withPermission(auth.PermStatusView, v1Workers)),
+	})
+	route(mux, "/v1/workers/{id}/drain", map[string]http.HandlerFunc{
+		http.MethodPost:   withAuth(withAudit("worker.drain", withPermission(auth.PermWorkerDrain, v1DrainWorker))),
+		http.MethodDelete: withAuth(withAudit("worker.undrain", withPermission(auth.PermWorkerDrain, v1UndrainWorker))),
+	})
+	route(mux, "/v1/keys", map[string]http.HandlerFunc{
+		http.MethodGet:  withAuth(withPermission(auth.PermKeyManage, v1ListKeys)),
+		http.MethodPost: withAuth(withAudit("key.create", withPermission(auth.PermKeyManage, v1CreateKey))),
+	})
+	route(mux, "/v1/keys/{id}", map[string]http.HandlerFunc{
+		http.MethodDelete: withAuth(withAudit("key.revoke", withPermission(auth.PermKeyManage, v1RevokeKey))),
+	})
+	route(mux, "/v1/keys/{id}/rotate", map[string]http.HandlerFunc{
+		http.MethodPost: withAuth(withAudit("key.rotate", withPermission(auth.PermKeyManage, v1RotateKey))),
+	})
+	route(mux, "/v1/audit", map[string]http.HandlerFunc{
+		http.MethodGet: withAuth(withPermission(auth.PermAuditView, v1ListAudit)),
+	})
+	route(mux, "/v1/schemas", map[string]http.HandlerFunc{
+		http.MethodGet:  withAuth(v1ListSchemas),
+		http.MethodPost: withAuth(withAudit("schema.create", withPermission(auth.PermSchemaManage, v1CreateSchema))),
+	})
+	route(mux, "/v1/schemas/{type}", map[string]http.HandlerFunc{
+		http.MethodGet: withAuth(v1GetSchema),
+	})
+	route(mux, "/v1/schemas/{type}/versions", map[string]http.HandlerFunc{
+		http.MethodGet: withAuth(v1ListSchemaVersions),
+	})
+	route(mux, "/v1/openapi.json", map[string]http.HandlerFunc{
+		http.MethodGet: serveOpenAPI,
+	})
+
+	mux.HandleFunc("/v1/", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, newError(http.StatusNotFound, codeNotFound, "No route "+r.URL.Path))
+	}))
+}
+
+// route registers path's handler of each method. Other methods get a 405
+// in the /v1 envelope rather than the mux's plain text one.
+func route(mux *http.ServeMux, path string, handlers map[string]http.HandlerFunc) {
+	allow := make([]string, 0, len(handlers))
+	for method, h := range handlers {
+		mux.HandleFunc(method+" "+path, withCORS(h))
+		allow = append(allow, method)
+		v1Routes = append(v1Routes, method+" "+path)
+	}
+	sort.Strings(allow)
+
+	mux.HandleFunc(path, withCORS(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		writeError(w, r, newError(http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed, use "+strings.Join(allow, " or ")))
+	}))
+}
+
+// v1SubmitTask queues a task, or delays it until scheduled_at if set.
+func v1SubmitTask(w http.ResponseWriter, r *http.Request) {
+	var t models.Task
+	if !decode(w, r, &t) {
+		return
+	}
+	if e := submitTask(r, &t, t.ScheduledAt != nil); e != nil {
+		writeError(w, r, e)
+		return
+	}
+	w.Header().Set("Location", "/v1/tasks/"+t.ID)
+	writeData(w, http.StatusAccepted, t, nil)
+}
+
+// v1ListTasks filters by the tenant query parameter and by payload_filter,
+// a JSON path expression that may be repeated, every one of which the
+// payload must match.
+func v1ListTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var errs []models.FieldError
+	req := models.APIListRequest{
+		Page:           queryInt(q, "page", &errs),
+		PageSize:       queryInt(q, "page_size", &errs),
+		Tenant:         q.Get("tenant"),
+		PayloadFilters: q["payload_filter"],
+	}
+	if errs != nil {
+		writeError(w, r, invalid(errs))
+		return
+	}
+
+	tasks, total, e := listTasks(r, &req)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, tasks, &total)
+}
+
+func v1GetTask(w http.ResponseWriter, r *http.Request) {
+	t, e := getTask(r, r.PathValue("id"))
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, t, nil)
+}
+
+func v1CancelTask(w http.ResponseWriter, r *http.Request) {
+	t, e := cancelTask(r, r.PathValue("id"))
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, t, nil)
+}
+
+// v1RequeueTask puts a failed task back on its queue.
+func v1RequeueTask(w http.ResponseWriter, r *http.Request) {
+	t, e := requeueTask(r, r.PathValue("id"))
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, t, nil)
+}
+
+func v1Schedulers(w http.ResponseWriter, r *http.Request) {
+	statuses, e := schedulerStatuses()
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, statuses, nil)
+}
+
+func v1Workers(w http.ResponseWriter, r *http.Request) {
+	statuses, e := workerStatuses()
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, statuses, nil)
+}
+
+// v1DrainWorker stops sending the worker tasks, it finishes those it has.
+func v1DrainWorker(w http.ResponseWriter, r *http.Request) {
+	ws, e := drainWorker(r, r.PathValue("id"), true)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, ws, nil)
+}
+
+func v1UndrainWorker(w http.ResponseWriter, r *http.Request) {
+	ws, e := drainWorker(r, r.PathValue("id"), false)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, ws, nil)
+}
+
+func v1ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, e := listKeys()
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, keys, nil)
+}
+
+// v1CreateKey answers with the new key, the only time it is shown.
+func v1CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req models.APIKeyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	k, e := createKey(r, req)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusCreated, k, nil)
+}
+
+func v1RotateKey(w http.ResponseWriter, r *http.Request) {
+	k, e := rotateKey(r, r.PathValue("id"))
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusCreated, k, nil)
+}
+
+func v1RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if e := revokeKey(r, id); e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, map[string]string{"id": id}, nil)
+}
+
+// v1ListAudit filters by the principal, action, target and outcome query
+// parameters, and by since and until as RFC 3339 times.
+func v1ListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var errs []models.FieldError
+	aq := models.AuditQuery{
+		Principal: q.Get("principal"),
+		Action:    q.Get("action"),
+		Target:    q.Get("target"),
+		Outcome:   q.Get("outcome"),
+		Since:     queryTime(q, "since", &errs),
+		Until:     queryTime(q, "until", &errs),
+		Page:      queryInt(q, "page", &errs),
+		PageSize:  queryInt(q, "page_size", &errs),
+	}
+	if errs != nil {
+		writeError(w, r, invalid(errs))
+		return
+	}
+
+	events, total, e := listAudit(&aq)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, events, &total)
+}
+
+func v1ListSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas, e := listSchemas("")
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, schemas, nil)
+}
+
+// v1CreateSchema answers with the new version.
+func v1CreateSchema(w http.ResponseWriter, r *http.Request) {
+	var req models.TaskSchemaRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	ts, e := createSchema(r, req)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusCreated, ts, nil)
+}
+
+// v1GetSchema answers with the latest version, or the one in the version
+// query parameter.
+func v1GetSchema(w http.ResponseWriter, r *http.Request) {
+	var errs []models.FieldError
+	version := queryInt(r.URL.Query(), "version", &errs)
+	if version < 0 {
+		errs = append(errs, models.FieldError{Field: "version", Message: "must be at least 1"})
+	}
+	if errs != nil {
+		writeError(w, r, invalid(errs))
+		return
+	}
+
+	ts, e := getSchema(r.PathValue("type"), version)
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, ts, nil)
+}
+
+func v1ListSchemaVersions(w http.ResponseWriter, r *http.Request) {
+	schemas, e := listSchemas(r.PathValue("type"))
+	if e != nil {
+		writeError(w, r, e)
+		return
+	}
+	writeData(w, http.StatusOK, schemas, nil)
+}
+
+// queryInt reads an optional integer query parameter, 0 if absent.
+func queryInt(q url.Values, name string, errs *[]models.FieldError) int {
+	v := q.Get(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		*errs = append(*errs, models.FieldError{Field: name, Message: "must be an integer"})
+	}
+	return n
+}
+
+// queryTime reads an optional RFC 3339 query parameter, nil if absent.
+func queryTime(q url.Values, name string, errs *[]models.FieldError) *time.Time {
+	v := q.Get(name)
+	if v == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		*errs = append(*errs, models.FieldError{Field: name, Message: "must be an RFC 3339 time"})
+		return nil
+	}
+	return &t
+}
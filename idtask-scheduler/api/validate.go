@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// validateTask checks a submitted task and defaults its tenant. delayed
+// submissions need a scheduled_at. The fields the scheduler and the limits
+// attach on dispatch are dropped if the client sent them, a client must
+// not pick the slot a task releases or the prediction it reports against.
+// A payload_ref is dropped too, only blob.Offload may point a task at a
+// stored payload.
+func validateTask(t *models.Task, delayed bool) []models.FieldError {
+	t.Prediction, t.FencingToken, t.LimitKey, t.PayloadRef = nil, 0, "", ""
+
+	var errs []models.FieldError
+	add := func(field, msg string) {
+		errs = append(errs, models.FieldError{Field: field, Message: msg})
+	}
+
+	if strings.TrimSpace(t.Type) == "" {
+		add("type", "is required")
+	}
+	if len(t.Payload) > 0 && !json.Valid(t.Payload) {
+		add("payload", "must be valid JSON")
+	}
+	if t.Tenant == "" {
+		t.Tenant = models.DefaultTenant
+	}
+	if !models.ValidTenant(t.Tenant) {
+		add("tenant", "must be lowercase letters, digits, '.', '_' or '-', up to 63 characters")
+	}
+	if t.Priority.Valid && t.Priority.Int64 < 0 {
+		add("priority", "must not be negative")
+	}
+	if t.MaxRetry.Valid && t.MaxRetry.Int64 < 0 {
+		add("max_retry", "must not be negative")
+	}
+	if t.ExpireAt != nil && t.ExpireAt.Before(time.Now()) {
+		add("expire_at", "is in the past")
+	}
+	if delayed && t.ScheduledAt == nil {
+		add("scheduled_at", "is required for delayed tasks")
+	}
+	if t.ScheduledAt != nil && t.ExpireAt != nil && !t.ScheduledAt.Before(*t.ExpireAt) {
+		add("scheduled_at", "must be before expire_at")
+	}
+	return errs
+}
+
+// validatePage defaults an unset page and page size and checks their range.
+func validatePage(page, pageSize *int) []models.FieldError {
+	var errs []models.FieldError
+	if *page == 0 {
+		*page = 1
+	}
+	if *pageSize == 0 {
+		*pageSize = defaultPageSize
+	}
+	if *page < 1 {
+		errs = append(errs, models.FieldError{Field: "page", Message: "must be at least 1"})
+	}
+	if *pageSize < 1 || *pageSize > maxPageSize {
+		errs = append(errs, models.FieldError{Field: "page_size", Message: "must be between 1 and 100"})
+	}
+	return errs
+}
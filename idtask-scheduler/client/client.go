@@ -0,0 +1,440 @@
+// Package client is a typed Go client for the /v1 API described by
+// api/openapi.json. It speaks the models types the API serves:
+//
+//	c := client.New("http://localhost:8080", client.WithAPIKey(key))
+//	task, err := c.SubmitAndWait(ctx, &models.Task{Type: "resize"}, time.Second)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	header  http.Header
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey authenticates requests with an API key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.header.Set("X-API-Key", key) }
+}
+
+// WithBearerToken authenticates requests with a JWT.
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.header.Set("Authorization", "Bearer "+token) }
+}
+
+// WithHTTPClient sends requests with h instead of a client with a 30s timeout.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithRetries retries a failed request up to n times, waiting backoff,
+// doubled on every attempt, or the server's Retry-After. Defaults to 3
+// retries from 200ms; 0 turns retries off.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// New returns a client for the API at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+		header:  make(http.Header),
+		retries: 3,
+		backoff: 200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	// Code is machine readable, e.g. "validation_failed" or "not_found".
+	Code    string
+	Message string
+	// Details lists the invalid fields of a "validation_failed" error.
+	Details []models.FieldError
+	// RetryAfter is how long the server asked to wait, if it did.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("idtask: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	for _, d := range e.Details {
+		msg += fmt.Sprintf("; %s %s", d.Field, d.Message)
+	}
+	return msg
+}
+
+// ErrorCode returns the API error code of err, empty if err is not an
+// API error.
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// ListOptions pages through a list, the server defaults zero values.
+type ListOptions struct {
+	Page     int
+	PageSize int
+	// Tenant limits ListTasks to one tenant.
+	Tenant string
+	// PayloadFilters limits ListTasks to tasks whose payload matches every
+	// JSON path expression, e.g. `$.width > 100`.
+	PayloadFilters []string
+}
+
+// SubmitTask submits a task, delayed until ScheduledAt if set, and
+// returns it as stored.
+func (c *Client) SubmitTask(ctx context.Context, t *models.Task) (*models.Task, error) {
+	var out models.Task
+	if _, err := c.do(ctx, http.MethodPost, "/v1/tasks", nil, t, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTask returns a task with its latest execution.
+func (c *Client) GetTask(ctx context.Context, id string) (*models.Task, error) {
+	var out models.Task
+	if _, err := c.do(ctx, http.MethodGet, "/v1/tasks/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTasks returns a page of tasks, newest first, and how many there are.
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) ([]models.Task, int, error) {
+	q := pageQuery(opts.Page, opts.PageSize)
+	if opts.Tenant != "" {
+		q.Set("tenant", opts.Tenant)
+	}
+	for _, f := range opts.PayloadFilters {
+		q.Add("payload_filter", f)
+	}
+	var out []models.Task
+	total, err := c.do(ctx, http.MethodGet, "/v1/tasks", q, nil, &out)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// CancelTask cancels a task that has not finished. For a finished one the
+// error has the code "task_finished".
+func (c *Client) CancelTask(ctx context.Context, id string) (*models.Task, error) {
+	var out models.Task
+	if _, err := c.do(ctx, http.MethodDelete, "/v1/tasks/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RequeueTask puts a failed task back on its queue. For one that has not
+// failed the error has the code "task_not_failed".
+func (c *Client) RequeueTask(ctx context.Context, id string) (*models.Task, error) {
+	var out models.Task
+	if _, err := c.do(ctx, http.MethodPost, "/v1/tasks/"+url.PathEscape(id)+"/requeue", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitAndWait submits a task and polls it every interval until it
+// completes, fails, expires or is cancelled, or ctx is done.
+func (c *Client) SubmitAndWait(ctx context.Context, t *models.Task, interval time.Duration) (*models.Task, error) {
+	submitted, err := c.SubmitTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return c.WaitForTask(ctx, submitted.ID, interval)
+}
+
+// WaitForTask polls a task every interval until it is in a final state.
+func (c *Client) WaitForTask(ctx context.Context, id string, interval time.Duration) (*models.Task, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t, err := c.GetTask(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if models.TaskFinished(t.Status) {
+			return t, nil
+		}
+		select {
+		case <-ctx.Done():
+			return t, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Schedulers returns every scheduler instance's last heartbeat.
+func (c *Client) Schedulers(ctx context.Context) ([]models.SchedulerStatus, error) {
+	var out []models.SchedulerStatus
+	_, err := c.do(ctx, http.MethodGet, "/v1/schedulers", nil, nil, &out)
+	return out, err
+}
+
+// Workers returns every registered worker's last heartbeat.
+func (c *Client) Workers(ctx context.Context) ([]models.WorkerStatus, error) {
+	var out []models.WorkerStatus
+	_, err := c.do(ctx, http.MethodGet, "/v1/workers", nil, nil, &out)
+	return out, err
+}
+
+// DrainWorker stops schedulers sending the worker tasks; it finishes the
+// ones it has.
+func (c *Client) DrainWorker(ctx context.Context, id string) (*models.WorkerStatus, error) {
+	var out models.WorkerStatus
+	if _, err := c.do(ctx, http.MethodPost, "/v1/workers/"+url.PathEscape(id)+"/drain", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UndrainWorker lets schedulers send a drained worker tasks again.
+func (c *Client) UndrainWorker(ctx context.Context, id string) (*models.WorkerStatus, error) {
+	var out models.WorkerStatus
+	if _, err := c.do(ctx, http.MethodDelete, "/v1/workers/"+url.PathEscape(id)+"/drain", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateKey issues an API key. Its Key field is the only copy of the key.
+func (c *Client) CreateKey(ctx context.Context, req models.APIKeyRequest) (*models.APIKey, error) {
+	var out models.APIKey
+	if _, err := c.do(ctx, http.MethodPost, "/v1/keys", nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListKeys returns every API key, revoked ones included, without the keys.
+func (c *Client) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	var out []models.APIKey
+	_, err := c.do(ctx, http.MethodGet, "/v1/keys", nil, nil, &out)
+	return out, err
+}
+
+// RotateKey revokes a key and returns the one replacing it.
+func (c *Client) RotateKey(ctx context.Context, id string) (*models.APIKey, error) {
+	var out models.APIKey
+	if _, err := c.do(ctx, http.MethodPost, "/v1/keys/"+url.PathEscape(id)+"/rotate", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeKey revokes a key, its requests fail from then on.
+func (c *Client) RevokeKey(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/v1/keys/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
+
+// AuditEvents queries the audit log, newest first.
+func (c *Client) AuditEvents(ctx context.Context, aq models.AuditQuery) ([]models.AuditEvent, error) {
+	q := pageQuery(aq.Page, aq.PageSize)
+	for name, v := range map[string]string{"principal": aq.Principal, "action": aq.Action, "target": aq.Target, "outcome": aq.Outcome} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	if aq.Since != nil {
+		q.Set("since", aq.Since.Format(time.RFC3339))
+	}
+	if aq.Until != nil {
+		q.Set("until", aq.Until.Format(time.RFC3339))
+	}
+	var out []models.AuditEvent
+	_, err := c.do(ctx, http.MethodGet, "/v1/audit", q, nil, &out)
+	return out, err
+}
+
+// RegisterSchema adds a version of a task type's payload schema. Tasks of
+// the type submitted from then on are validated against it.
+func (c *Client) RegisterSchema(ctx context.Context, req models.TaskSchemaRequest) (*models.TaskSchema, error) {
+	var out models.TaskSchema
+	if _, err := c.do(ctx, http.MethodPost, "/v1/schemas", nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSchema returns a version of a task type's schema, the latest for 0.
+func (c *Client) GetSchema(ctx context.Context, taskType string, version int) (*models.TaskSchema, error) {
+	var q url.Values
+	if version > 0 {
+		q = url.Values{"version": {strconv.Itoa(version)}}
+	}
+	var out models.TaskSchema
+	if _, err := c.do(ctx, http.MethodGet, "/v1/schemas/"+url.PathEscape(taskType), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSchemas returns the latest schema of every task type.
+func (c *Client) ListSchemas(ctx context.Context) ([]models.TaskSchema, error) {
+	var out []models.TaskSchema
+	_, err := c.do(ctx, http.MethodGet, "/v1/schemas", nil, nil, &out)
+	return out, err
+}
+
+// SchemaVersions returns every version of a task type's schema, newest first.
+func (c *Client) SchemaVersions(ctx context.Context, taskType string) ([]models.TaskSchema, error) {
+	var out []models.TaskSchema
+	_, err := c.do(ctx, http.MethodGet, "/v1/schemas/"+url.PathEscape(taskType)+"/versions", nil, nil, &out)
+	return out, err
+}
+
+func pageQuery(page, pageSize int) url.Values {
+	q := url.Values{}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		q.Set("page_size", strconv.Itoa(pageSize))
+	}
+	return q
+}
+
+// envelope is models.APIEnvelope with Data left raw for the caller's type.
+type envelope struct {
+	Data  json.RawMessage  `json:"data"`
+	Total int              `json:"total"`
+	Error *models.APIError `json:"error"`
+}
+
+// do sends a request and decodes the envelope's data into out, returning
+// the envelope's total. See retryable for which failures are retried.
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, body, out any) (int, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		total, err := c.send(ctx, method, u, payload, out)
+		if err == nil || attempt >= c.retries || !retryable(method, err) {
+			return total, err
+		}
+
+		delay := wait
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u string, payload []byte, out any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		if resp.StatusCode >= 400 {
+			return 0, &Error{StatusCode: resp.StatusCode, Code: "unknown", Message: strings.TrimSpace(string(raw))}
+		}
+		return 0, fmt.Errorf("idtask: decoding response: %w", err)
+	}
+	if resp.StatusCode >= 400 || env.Error != nil {
+		e := &Error{StatusCode: resp.StatusCode}
+		if env.Error != nil {
+			e.Code, e.Message, e.Details = env.Error.Code, env.Error.Message, env.Error.Details
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return 0, e
+	}
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return 0, fmt.Errorf("idtask: decoding response: %w", err)
+		}
+	}
+	return env.Total, nil
+}
+
+// retryable reports whether a failed request may be sent again: a 429,
+// which the server answers before acting, and for GET and DELETE also
+// transport errors and 502, 503 and 504. A POST that failed otherwise may
+// have taken effect, retrying it could submit a task twice.
+func retryable(method string, err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		switch e.StatusCode {
+		case http.StatusTooManyRequests:
+			return true
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return method != http.MethodPost
+		}
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return method != http.MethodPost
+}
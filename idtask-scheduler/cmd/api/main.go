@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/JamesDante/idtask-scheduler/api"
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/etcdclient"
+	"github.com/JamesDante/idtask-scheduler/internal/redisclient"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+)
+
+func main() {
+	configs.InitConfig()
+
+	// Connect Postgres
+	storage.Init()
+
+	// Connect Redis
+	redisclient.Init()
+
+	etcdclient.Init()
+
+	blob.Init()
+
+	monitor.InitApiMetrics()
+
+	handler := api.NewHandler()
+
+	if addr := configs.Config.GrpcApiPort; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC on %s: %v", addr, err)
+		}
+		log.Printf("gRPC server started at %s", addr)
+		go func() {
+			if err := api.NewGRPCServer().Serve(lis); err != nil {
+				log.Fatalf("gRPC server stopped: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("Server started at %s", configs.Config.WebApiPort)
+	http.ListenAndServe(configs.Config.WebApiPort, handler)
+}
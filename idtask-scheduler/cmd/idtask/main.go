@@ -0,0 +1,37 @@
+// Command idtask runs the whole system in one process for local development:
+// API, scheduler and workers over in-memory Redis, embedded etcd and an
+// in-memory task store. Nothing is persisted between runs.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/embedded"
+)
+
+func main() {
+	workers := flag.Int("workers", 2, "number of in-process workers")
+	flag.Parse()
+
+	configs.InitConfig()
+
+	cluster, err := embedded.Start(embedded.Options{
+		Workers: *workers,
+		APIAddr: configs.Config.WebApiPort,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cluster.Close()
+
+	log.Printf("Server started at %s", cluster.APIURL)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/aiclient"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/etcdclient"
+	"github.com/JamesDante/idtask-scheduler/internal/redisclient"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/scheduler"
+	"github.com/JamesDante/idtask-scheduler/storage"
+)
+
+func main() {
+
+	configs.InitConfig()
+
+	redisclient.Init()
+
+	aiclient.Init()
+
+	etcdclient.Init()
+	defer etcdclient.GetClient().Close()
+
+	// Connect Postgres
+	storage.Init()
+
+	blob.Init()
+
+	monitor.InitSchedulerMetrics()
+
+	var predictor aiclient.Predictor = aiclient.WithFallback(
+		aiclient.NewHeuristicPredictor(storage.GetTaskTypeStats, time.Minute),
+		aiclient.NewCircuitBreaker(configs.Config.AIBreakerThreshold, configs.Config.AIBreakerCooldown),
+	)
+
+	if shadows := aiclient.Shadows(); len(shadows) > 0 {
+		log.Printf("🕶️ Evaluating %d shadow predictor(s) next to the primary", len(shadows))
+		predictor = aiclient.NewShadowPredictor(
+			aiclient.NamedPredictor{Name: "primary", Predictor: predictor},
+			shadows,
+			storage.CreatePredictorPrediction,
+		)
+	}
+
+	s := scheduler.New(redisclient.GetClient(), etcdclient.GetClient(), predictor)
+
+	// hand leadership over before exiting so a rolling deploy does not
+	// leave shards unscheduled until the election TTL runs out
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.Resign(ctx); err != nil {
+			log.Printf("⚠️ Resign failed: %v", err)
+			os.Exit(1)
+		}
+	}()
+
+	if err := s.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Scheduler stopped.")
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/aiclient"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/redisclient"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/JamesDante/idtask-scheduler/worker"
+)
+
+func main() {
+
+	configs.InitConfig()
+
+	redisclient.Init()
+
+	storage.Init()
+
+	aiclient.Init()
+
+	blob.Init()
+
+	monitor.InitWorkerMetrics(configs.Config.WorkerMetricsPort)
+
+	w := worker.New(redisclient.GetClient())
+	w.Reporter = aiclient.Reporter()
+	if err := w.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
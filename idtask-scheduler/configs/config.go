@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -17,6 +18,115 @@ type ConfigStruct struct {
 	RedisAddress          string
 	PostgresConnectString string
 	WebApiPort            string
+	// GrpcApiPort serves the gRPC TaskService next to the HTTP API, off if empty.
+	GrpcApiPort       string
+	WorkerMetricsPort string
+
+	// AI circuit breaker: consecutive failures before opening, and how long
+	// predictions go to the fallback before the AI service is tried again.
+	AIBreakerThreshold int
+	AIBreakerCooldown  time.Duration
+
+	// Micro-batching of concurrent predictions into PredictBatch calls.
+	AIBatchSize   int
+	AIBatchWindow time.Duration
+
+	// Tasks the scheduler pops and predicts per round trip.
+	SchedulerBatchSize int
+
+	// Prediction cache: comma separated metadata keys forming the task
+	// signature, entry lifetime (0 disables the cache) and max entries.
+	AICacheKey  string
+	AICacheTTL  time.Duration
+	AICacheSize int
+
+	// AI worker recommendations below this confidence are ignored.
+	AIMinConfidence float64
+
+	// Shadow predictors, "name=host:port" comma separated. They get the same
+	// tasks as the primary but their answers are only recorded.
+	AIShadowURLs string
+	// Shadow calls and prediction writes running in the background at
+	// once; work beyond it is dropped and counted.
+	AIShadowConcurrency int
+
+	// Worker selection: the default strategy, and per task type overrides
+	// as "type:strategy" pairs, e.g. "report:consistent-hash".
+	SchedulerStrategy   string
+	SchedulerStrategies string
+
+	// Share of tasks this worker gets under the weighted strategy.
+	WorkerWeight int
+
+	// Worker labels as "key=value" pairs and the task types it accepts,
+	// both comma separated. No task types means any type.
+	WorkerLabels    string
+	WorkerTaskTypes string
+
+	// Tasks a worker accepts queued or running at once, 0 for no limit.
+	WorkerCapacity int
+
+	// Number of scheduler shards, and whether tasks are assigned to them
+	// by hash of "id" or "type". API and schedulers must agree on both.
+	SchedulerShards  int
+	SchedulerShardBy string
+
+	// Per task type limits as "type:rate=5,burst=10,concurrency=3,per=key"
+	// entries separated by ";", per is key or tenant. Running slots are released by the worker,
+	// or after TaskLimitLease if it never reports back.
+	TaskLimits     string
+	TaskLimitLease time.Duration
+
+	// Fair share between tenants as "tenant=value" pairs: relative weights
+	// (1 if unlisted) and guaranteed minimum shares between 0 and 1.
+	TenantWeights   string
+	TenantMinShares string
+
+	// Per tenant admission quotas as "tenant:queued=1000,rate=600,payload=65536"
+	// entries separated by ";", "*" for tenants not listed. queued caps the
+	// tasks waiting, rate the submissions per minute and payload the bytes
+	// of one task's payload.
+	TenantQuotas string
+
+	// Global backpressure once this many tasks are queued, 0 to disable.
+	// BackpressureMode "reject" refuses every submission, "shed" only those
+	// with a priority below BackpressureMinPriority.
+	BackpressureQueueDepth  int
+	BackpressureMode        string
+	BackpressureMinPriority int
+
+	// API authentication methods, "apikey" and/or "jwt" comma separated.
+	// Empty leaves the API open. APIBootstrapKey is an API key stored at
+	// startup, to create the first keys with.
+	APIAuth         string
+	APIBootstrapKey string
+
+	// JWTs must be signed by a key in the JWKS file and, when set, carry
+	// the issuer and audience.
+	JWTJWKSFile string
+	JWTIssuer   string
+	JWTAudience string
+	// Claim of the JWT listing the principal's "role@tenant" grants.
+	JWTRolesClaim string
+
+	// Authorization: extra roles as "role=perm,perm" entries separated by
+	// ";", role grants per subject as "subject=role@tenant,..." entries
+	// separated by ";", and grants every authenticated principal has.
+	RBACRoles        string
+	RBACBindings     string
+	RBACDefaultRoles string
+
+	// Origins allowed to call the API from a browser, comma separated.
+	CORSAllowedOrigins string
+
+	// Payloads larger than PayloadOffloadBytes are kept in the blob store
+	// and queued by reference, 0, the default, queues every payload
+	// inline. BlobStore names the implementation, only "file" so far, which
+	// keeps blobs in BlobDir; every API and worker process must see the
+	// same directory.
+	PayloadOffloadBytes int
+	BlobStore           string
+	BlobDir             string
 }
 
 var Config ConfigStruct
@@ -33,6 +143,51 @@ func InitConfig() {
 		RedisAddress:          getEnv("REDIS_ADDRESS", "localhost:6379"),
 		PostgresConnectString: getEnv("PG_CONN_STRING", "host=localhost port=5432 user=postgres password=postgres dbname=tasks sslmode=disable"),
 		WebApiPort:            getEnv("WEB_API_PORT", ":8080"),
+		GrpcApiPort:           getEnv("GRPC_API_PORT", ":9091"),
+		WorkerMetricsPort:     getEnv("WORKER_METRICS_PORT", ":8083"),
+		AIBreakerThreshold:    getEnvInt("AI_BREAKER_THRESHOLD", 5),
+		AIBreakerCooldown:     getEnvDuration("AI_BREAKER_COOLDOWN", 30*time.Second),
+		AIBatchSize:           getEnvInt("AI_BATCH_SIZE", 32),
+		AIBatchWindow:         getEnvDuration("AI_BATCH_WINDOW", 5*time.Millisecond),
+		SchedulerBatchSize:    getEnvInt("SCHEDULER_BATCH_SIZE", 32),
+		AICacheKey:            getEnv("AI_CACHE_KEY", "TaskType,Priority"),
+		AICacheTTL:            getEnvDuration("AI_CACHE_TTL", 30*time.Second),
+		AICacheSize:           getEnvInt("AI_CACHE_SIZE", 1000),
+		AIMinConfidence:       getEnvFloat("AI_MIN_CONFIDENCE", 0.3),
+		AIShadowURLs:          getEnv("AI_SHADOW_URLS", ""),
+		AIShadowConcurrency:   getEnvInt("AI_SHADOW_CONCURRENCY", 16),
+		SchedulerStrategy:     getEnv("SCHEDULER_STRATEGY", "ai"),
+		SchedulerStrategies:   getEnv("SCHEDULER_STRATEGIES", ""),
+		WorkerWeight:          getEnvInt("WORKER_WEIGHT", 1),
+		WorkerLabels:          getEnv("WORKER_LABELS", ""),
+		WorkerTaskTypes:       getEnv("WORKER_TASK_TYPES", ""),
+		WorkerCapacity:        getEnvInt("WORKER_CAPACITY", 0),
+		SchedulerShards:       getEnvInt("SCHEDULER_SHARDS", 1),
+		SchedulerShardBy:      getEnv("SCHEDULER_SHARD_BY", "id"),
+		TaskLimits:            getEnv("TASK_LIMITS", ""),
+		TaskLimitLease:        getEnvDuration("TASK_LIMIT_LEASE", 10*time.Minute),
+		TenantWeights:         getEnv("TENANT_WEIGHTS", ""),
+		TenantMinShares:       getEnv("TENANT_MIN_SHARES", ""),
+		TenantQuotas:          getEnv("TENANT_QUOTAS", ""),
+
+		BackpressureQueueDepth:  getEnvInt("BACKPRESSURE_QUEUE_DEPTH", 0),
+		BackpressureMode:        getEnv("BACKPRESSURE_MODE", "shed"),
+		BackpressureMinPriority: getEnvInt("BACKPRESSURE_MIN_PRIORITY", 1),
+
+		APIAuth:            getEnv("API_AUTH", ""),
+		APIBootstrapKey:    getEnv("API_BOOTSTRAP_KEY", ""),
+		JWTJWKSFile:        getEnv("JWT_JWKS_FILE", ""),
+		JWTIssuer:          getEnv("JWT_ISSUER", ""),
+		JWTAudience:        getEnv("JWT_AUDIENCE", ""),
+		JWTRolesClaim:      getEnv("JWT_ROLES_CLAIM", "roles"),
+		RBACRoles:          getEnv("RBAC_ROLES", ""),
+		RBACBindings:       getEnv("RBAC_BINDINGS", ""),
+		RBACDefaultRoles:   getEnv("RBAC_DEFAULT_ROLES", ""),
+		CORSAllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", ""),
+
+		PayloadOffloadBytes: getEnvInt("PAYLOAD_OFFLOAD_BYTES", 0),
+		BlobStore:           getEnv("BLOB_STORE", "file"),
+		BlobDir:             getEnv("BLOB_DIR", "data/blobs"),
 	}
 }
 
@@ -46,3 +201,33 @@ func getEnv(key, fallback string) string {
 	fmt.Println("fallback for", key, "is", fallback)
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value := getEnv(key, strconv.Itoa(fallback))
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnv(key, fallback.String())
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid %s=%q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value := getEnv(key, strconv.FormatFloat(fallback, 'f', -1, 64))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️ Invalid %s=%q, using %g", key, value, fallback)
+		return fallback
+	}
+	return f
+}
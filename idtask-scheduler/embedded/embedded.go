@@ -0,0 +1,302 @@
+// Package embedded runs the API, the scheduler and a set of workers inside
+// one process, backed by an in-memory Redis, an embedded etcd, an in-memory
+// task store and a stub predictor. It needs no external services, so full
+// task flows can be exercised from `go test` or a single local binary.
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/api"
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/aiclient"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/etcdclient"
+	"github.com/JamesDante/idtask-scheduler/internal/redisclient"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/scheduler"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/JamesDante/idtask-scheduler/worker"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"google.golang.org/grpc"
+)
+
+// Options configures an embedded cluster. The zero value is usable.
+type Options struct {
+	// Workers is the number of in-process workers, 1 if unset.
+	Workers int
+	// WorkerCapacity caps the tasks queued per worker, unlimited if unset.
+	WorkerCapacity int
+	// Schedulers is the number of scheduler instances, 1 if unset, and
+	// Shards the number of shards they split the tasks into,
+	// SCHEDULER_SHARDS if unset.
+	Schedulers int
+	Shards     int
+	// TaskLimits are per task type limits in the TASK_LIMITS format,
+	// TASK_LIMITS if unset.
+	TaskLimits string
+	// TenantQuotas are per tenant submission quotas in the TENANT_QUOTAS
+	// format, TENANT_QUOTAS if unset.
+	TenantQuotas string
+	// APIAuth and APIBootstrapKey enable authentication as API_AUTH and
+	// API_BOOTSTRAP_KEY do, the API is open if unset.
+	APIAuth         string
+	APIBootstrapKey string
+	// APIAddr is the listen address of the HTTP API, a random local port if unset.
+	APIAddr string
+	// GRPCAddr is the listen address of the gRPC TaskService, off if unset.
+	// "127.0.0.1:0" picks a random local port.
+	GRPCAddr string
+	// PayloadOffloadBytes moves payloads larger than this to Blobs instead
+	// of the queues, as PAYLOAD_OFFLOAD_BYTES does. Off if unset.
+	PayloadOffloadBytes int
+	// Predictor replaces the AI service, aiclient.StubPredictor if unset.
+	Predictor aiclient.Predictor
+}
+
+// Cluster is a running embedded deployment.
+type Cluster struct {
+	// APIURL is the base URL of the HTTP API, e.g. http://127.0.0.1:41234.
+	APIURL string
+	// GRPCAddr is the address of the gRPC TaskService, empty if it is off.
+	GRPCAddr string
+	// Store holds every task submitted to the cluster.
+	Store *storage.MemoryStore
+	// Blobs holds the offloaded payloads of unfinished tasks.
+	Blobs *blob.MemoryStore
+	// Workers are the in-process workers, already registered in etcd.
+	Workers []*worker.Worker
+
+	redis   *miniredis.Miniredis
+	etcd    *embed.Etcd
+	etcdDir string
+	etcdCli *clientv3.Client
+	rdb     *redis.Client
+	server  *http.Server
+	grpc    *grpc.Server
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Start boots every component and returns once the API is accepting requests.
+// Only one cluster may run per process because the shared clients are global.
+// Settings other than the addresses are read from configs.Config, so
+// configs.InitConfig must have run.
+func Start(opts Options) (*Cluster, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Schedulers <= 0 {
+		opts.Schedulers = 1
+	}
+	if opts.APIAddr == "" {
+		opts.APIAddr = "127.0.0.1:0"
+	}
+	if opts.Predictor == nil {
+		opts.Predictor = aiclient.StubPredictor{}
+	}
+
+	c := &Cluster{Store: storage.NewMemoryStore(), Blobs: blob.NewMemoryStore()}
+
+	var err error
+	c.redis, err = miniredis.Run()
+	if err != nil {
+		return nil, fmt.Errorf("start in-memory redis: %w", err)
+	}
+
+	if err := c.startEtcd(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	// keep the settings configs.InitConfig loaded, only the backends and
+	// the API address are the embedded ones
+	configs.Config.EtcdAddress = c.etcd.Clients[0].Addr().String()
+	configs.Config.RedisAddress = c.redis.Addr()
+	configs.Config.WebApiPort = opts.APIAddr
+	if opts.Shards > 0 {
+		configs.Config.SchedulerShards = opts.Shards
+	}
+	if opts.TaskLimits != "" {
+		configs.Config.TaskLimits = opts.TaskLimits
+	}
+	if opts.TenantQuotas != "" {
+		configs.Config.TenantQuotas = opts.TenantQuotas
+	}
+	if opts.APIAuth != "" {
+		configs.Config.APIAuth = opts.APIAuth
+	}
+	if opts.APIBootstrapKey != "" {
+		configs.Config.APIBootstrapKey = opts.APIBootstrapKey
+	}
+	if opts.PayloadOffloadBytes > 0 {
+		configs.Config.PayloadOffloadBytes = opts.PayloadOffloadBytes
+	}
+
+	c.rdb = redis.NewClient(&redis.Options{Addr: c.redis.Addr()})
+	redisclient.Use(c.rdb)
+
+	c.etcdCli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{configs.Config.EtcdAddress},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("connect embedded etcd: %w", err)
+	}
+	etcdclient.Use(c.etcdCli)
+
+	storage.Use(c.Store)
+	blob.Use(c.Blobs)
+	monitor.RegisterAll()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	for i := 0; i < opts.Workers; i++ {
+		w := worker.New(c.rdb)
+		w.Capacity = opts.WorkerCapacity
+		if r, ok := opts.Predictor.(aiclient.OutcomeReporter); ok {
+			w.Reporter = r
+		}
+		c.Workers = append(c.Workers, w)
+		c.run(func() error { return w.Run(ctx) })
+	}
+
+	for i := 0; i < opts.Schedulers; i++ {
+		s := scheduler.New(c.rdb, c.etcdCli, opts.Predictor)
+		c.run(func() error { return s.Run(ctx) })
+	}
+
+	ln, err := net.Listen("tcp", opts.APIAddr)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("listen api: %w", err)
+	}
+	c.APIURL = "http://" + ln.Addr().String()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", api.NewHandler())
+	mux.Handle("/metrics", monitor.Handler())
+	c.server = &http.Server{Handler: mux}
+	c.run(func() error {
+		if err := c.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+
+	if opts.GRPCAddr != "" {
+		gln, err := net.Listen("tcp", opts.GRPCAddr)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("listen grpc: %w", err)
+		}
+		c.GRPCAddr = gln.Addr().String()
+		c.grpc = api.NewGRPCServer()
+		c.run(func() error { return c.grpc.Serve(gln) })
+	}
+
+	log.Printf("🧪 Embedded cluster started: api=%s workers=%d", c.APIURL, opts.Workers)
+	return c, nil
+}
+
+// WaitForStatus polls the store until the task reaches status or ctx is done.
+func (c *Cluster) WaitForStatus(ctx context.Context, taskID, status string) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if t, err := c.Store.GetTask(taskID); err == nil && t.Status == status {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("task %s did not reach %q: %w", taskID, status, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops every component and releases the temporary etcd data dir.
+func (c *Cluster) Close() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.server != nil {
+		c.server.Close()
+	}
+	if c.grpc != nil {
+		c.grpc.Stop()
+	}
+	if c.rdb != nil {
+		c.rdb.Close()
+	}
+	c.wg.Wait()
+
+	if c.etcdCli != nil {
+		c.etcdCli.Close()
+	}
+	if c.etcd != nil {
+		c.etcd.Close()
+	}
+	if c.etcdDir != "" {
+		os.RemoveAll(c.etcdDir)
+	}
+	if c.redis != nil {
+		c.redis.Close()
+	}
+}
+
+func (c *Cluster) run(fn func() error) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := fn(); err != nil {
+			log.Printf("❌ Embedded component stopped: %v", err)
+		}
+	}()
+}
+
+func (c *Cluster) startEtcd() error {
+	dir, err := os.MkdirTemp("", "idtask-etcd-")
+	if err != nil {
+		return fmt.Errorf("create etcd dir: %w", err)
+	}
+	c.etcdDir = dir
+
+	local, _ := url.Parse("http://127.0.0.1:0")
+
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{*local}
+	cfg.AdvertiseClientUrls = []url.URL{*local}
+	cfg.ListenPeerUrls = []url.URL{*local}
+	cfg.AdvertisePeerUrls = []url.URL{*local}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	c.etcd, err = embed.StartEtcd(cfg)
+	if err != nil {
+		return fmt.Errorf("start embedded etcd: %w", err)
+	}
+
+	select {
+	case <-c.etcd.Server.ReadyNotify():
+		return nil
+	case <-time.After(10 * time.Second):
+		return errors.New("embedded etcd took too long to start")
+	}
+}
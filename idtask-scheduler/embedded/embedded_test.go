@@ -0,0 +1,157 @@
+package embedded_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/embedded"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// TestTaskFlow submits an immediate and a delayed task over the HTTP API and
+// follows them through the scheduler and the workers until they complete.
+func TestTaskFlow(t *testing.T) {
+	configs.InitConfig()
+	cluster, err := embedded.Start(embedded.Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var submitted struct {
+		Data models.Task `json:"data"`
+	}
+	post(t, cluster.APIURL+"/tasks", map[string]any{"type": "e2e"}, &submitted)
+	later := time.Now().Add(time.Second)
+	post(t, cluster.APIURL+"/delayedtasks", map[string]any{"type": "e2e-delayed", "scheduled_at": later}, nil)
+
+	if err := cluster.WaitForStatus(ctx, submitted.Data.ID, "Completed"); err != nil {
+		t.Fatal(err)
+	}
+
+	workers := make(map[string]bool)
+	for _, w := range cluster.Workers {
+		workers[w.ID] = true
+	}
+	for {
+		tasks, err := cluster.Store.GetTasks(&models.APIListRequest{Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		completed := 0
+		for _, task := range tasks {
+			if task.Status != "Completed" {
+				continue
+			}
+			completed++
+			if !workers[task.ExecutedBy.String] {
+				t.Errorf("task %s: executed by %q, not an embedded worker", task.ID, task.ExecutedBy.String)
+			}
+		}
+		if len(tasks) == 2 && completed == 2 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%d of %d tasks completed: %v", completed, len(tasks), ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// TestOperatorEndpoints requeues a failed task, which must run again, and
+// drains the only worker, which must get no task until it is undrained.
+func TestOperatorEndpoints(t *testing.T) {
+	configs.InitConfig()
+	cluster, err := embedded.Start(embedded.Options{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var submitted struct {
+		Data models.Task `json:"data"`
+	}
+	post(t, cluster.APIURL+"/tasks", map[string]any{"type": "e2e"}, &submitted)
+	id := submitted.Data.ID
+	if err := cluster.WaitForStatus(ctx, id, "Completed"); err != nil {
+		t.Fatal(err)
+	}
+	if status := postStatus(t, cluster.APIURL+"/tasks/requeue", map[string]any{"id": id}, nil); status != http.StatusConflict {
+		t.Errorf("requeue of a completed task: %d", status)
+	}
+	if status := postStatus(t, cluster.APIURL+"/tasks/requeue", map[string]any{"id": "no-such-task"}, nil); status != http.StatusNotFound {
+		t.Errorf("requeue of an unknown task: %d", status)
+	}
+
+	cluster.Store.UpdateTasks(id, "Failed")
+	var requeued struct {
+		Data models.Task `json:"data"`
+	}
+	post(t, cluster.APIURL+"/tasks/requeue", map[string]any{"id": id}, &requeued)
+	if requeued.Data.Status != "Pending" || requeued.Data.Retries.Int64 != submitted.Data.Retries.Int64+1 {
+		t.Errorf("requeued task is %q with %d retries", requeued.Data.Status, requeued.Data.Retries.Int64)
+	}
+	if err := cluster.WaitForStatus(ctx, id, "Completed"); err != nil {
+		t.Fatalf("requeued task: %v", err)
+	}
+
+	worker := map[string]any{"id": cluster.Workers[0].ID}
+	var drained struct {
+		Data models.WorkerStatus `json:"data"`
+	}
+	post(t, cluster.APIURL+"/worker/drain", worker, &drained)
+	if drained.Data.Status != "draining" {
+		t.Errorf("drained worker is %q", drained.Data.Status)
+	}
+	post(t, cluster.APIURL+"/tasks", map[string]any{"type": "e2e"}, &submitted)
+	time.Sleep(time.Second)
+	if task, _ := cluster.Store.GetTask(submitted.Data.ID); task.Status == "Completed" {
+		t.Errorf("task %s ran on a draining worker", task.ID)
+	}
+
+	post(t, cluster.APIURL+"/worker/undrain", worker, nil)
+	if err := cluster.WaitForStatus(ctx, submitted.Data.ID, "Completed"); err != nil {
+		t.Fatalf("task after undraining: %v", err)
+	}
+	if status := postStatus(t, cluster.APIURL+"/worker/drain", map[string]any{"id": "no-such-worker"}, nil); status != http.StatusNotFound {
+		t.Errorf("drain of an unknown worker: %d", status)
+	}
+}
+
+func post(t *testing.T, url string, body, out any) {
+	t.Helper()
+	if status := postStatus(t, url, body, out); status != http.StatusOK {
+		t.Fatalf("POST %s: %d %s", url, status, http.StatusText(status))
+	}
+}
+
+// postStatus posts body and returns the response status, decoding the
+// response into out if it is a 200.
+func postStatus(t *testing.T, url string, body, out any) int {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("POST %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
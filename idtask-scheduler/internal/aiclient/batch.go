@@ -0,0 +1,164 @@
+package aiclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BatchPredictor predicts several tasks in one call. Responses line up with
+// the requests by index.
+type BatchPredictor interface {
+	PredictBatch(reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error)
+}
+
+// PredictAll uses one PredictBatch call when p supports it and falls back to
+// a Predict call per request otherwise.
+func PredictAll(p Predictor, reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	if bp, ok := p.(BatchPredictor); ok {
+		return bp.PredictBatch(reqs)
+	}
+
+	resps := make([]*pb.PredictResponse, len(reqs))
+	for i, req := range reqs {
+		resp, err := p.Predict(req.TaskId, req.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		resps[i] = resp
+	}
+	return resps, nil
+}
+
+type pendingPrediction struct {
+	req  *pb.PredictRequest
+	done chan predictResult
+}
+
+type predictResult struct {
+	resp *pb.PredictResponse
+	err  error
+}
+
+// EnableBatching makes concurrent Predict calls share PredictBatch round
+// trips. A batch is sent once it holds size requests or window has passed
+// since its first request, whichever comes first.
+func (c *AIClient) EnableBatching(size int, window time.Duration) {
+	if size <= 1 || c.queue != nil {
+		return
+	}
+	c.batchSize = size
+	c.batchWindow = window
+	c.queue = make(chan *pendingPrediction, size*4)
+	go c.runBatcher()
+}
+
+func (c *AIClient) PredictBatch(reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), predictTimeout)
+	defer cancel()
+
+	monitor.AIPredictBatchSize().Observe(float64(len(reqs)))
+
+	resp, err := c.client.PredictBatch(ctx, &pb.PredictBatchRequest{Requests: reqs})
+	if status.Code(err) == codes.Unimplemented {
+		// older AI service without PredictBatch
+		return c.predictEach(ctx, reqs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gRPC PredictBatch call failed: %w", err)
+	}
+	if len(resp.Responses) != len(reqs) {
+		return nil, fmt.Errorf("gRPC PredictBatch returned %d responses for %d requests", len(resp.Responses), len(reqs))
+	}
+	return resp.Responses, nil
+}
+
+// predictEach sends one Predict call per request, all at once, so the
+// batch takes as long as its slowest call and never longer than ctx allows.
+func (c *AIClient) predictEach(ctx context.Context, reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	resps := make([]*pb.PredictResponse, len(reqs))
+	errs := make([]error, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resps[i], errs[i] = c.predictOne(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resps, nil
+}
+
+func (c *AIClient) runBatcher() {
+	for first := range c.queue {
+		batch := []*pendingPrediction{first}
+		timer := time.NewTimer(c.batchWindow)
+
+	collect:
+		for len(batch) < c.batchSize {
+			select {
+			case p, ok := <-c.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, p)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		go c.flush(batch)
+	}
+}
+
+func (c *AIClient) flush(batch []*pendingPrediction) {
+	reqs := make([]*pb.PredictRequest, len(batch))
+	for i, p := range batch {
+		reqs[i] = p.req
+	}
+
+	resps, err := c.PredictBatch(reqs)
+	for i, p := range batch {
+		if err != nil {
+			p.done <- predictResult{err: err}
+			continue
+		}
+		p.done <- predictResult{resp: resps[i]}
+	}
+}
+
+// predictBatched hands req to the batcher and waits for its answer, or
+// until ctx is done.
+func (c *AIClient) predictBatched(ctx context.Context, req *pb.PredictRequest) (*pb.PredictResponse, error) {
+	p := &pendingPrediction{req: req, done: make(chan predictResult, 1)}
+	select {
+	case c.queue <- p:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("prediction not batched: %w", ctx.Err())
+	}
+
+	select {
+	case res := <-p.done:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("batched prediction not answered: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,83 @@
+package aiclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slowService is an AI service without PredictBatch whose Predict takes delay.
+type slowService struct {
+	pb.AIPredictorClient
+	delay time.Duration
+}
+
+func (s slowService) PredictBatch(ctx context.Context, in *pb.PredictBatchRequest, opts ...grpc.CallOption) (*pb.PredictBatchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method PredictBatch")
+}
+
+func (s slowService) Predict(ctx context.Context, in *pb.PredictRequest, opts ...grpc.CallOption) (*pb.PredictResponse, error) {
+	select {
+	case <-time.After(s.delay):
+		return &pb.PredictResponse{Priority: int32(len(in.TaskId))}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TestPredictBatchFallsBackConcurrently expects the per-request fallback to
+// take about one call's time rather than one per request.
+func TestPredictBatchFallsBackConcurrently(t *testing.T) {
+	c := &AIClient{client: slowService{delay: 200 * time.Millisecond}}
+	reqs := make([]*pb.PredictRequest, 32)
+	for i := range reqs {
+		reqs[i] = &pb.PredictRequest{TaskId: string(make([]byte, i))}
+	}
+
+	start := time.Now()
+	resps, err := c.PredictBatch(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fallback took %s for 32 requests", elapsed)
+	}
+	for i, resp := range resps {
+		if resp.Priority != int32(i) {
+			t.Errorf("response %d answers request %d", i, resp.Priority)
+		}
+	}
+}
+
+// TestPredictBatchFallbackDeadline expects one deadline for the whole
+// fallback, however slow the service.
+func TestPredictBatchFallbackDeadline(t *testing.T) {
+	c := &AIClient{client: slowService{delay: time.Minute}}
+	start := time.Now()
+	if _, err := c.PredictBatch([]*pb.PredictRequest{{TaskId: "a"}, {TaskId: "b"}}); err == nil {
+		t.Fatal("no error from a service slower than the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > predictTimeout+time.Second {
+		t.Errorf("fallback took %s, past the %s deadline", elapsed, predictTimeout)
+	}
+}
+
+// TestPredictBatchedDeadline expects a batched Predict to give up once its
+// deadline passes instead of waiting on the batcher for good.
+func TestPredictBatchedDeadline(t *testing.T) {
+	c := &AIClient{client: slowService{delay: time.Minute}}
+	c.EnableBatching(4, 10*time.Millisecond)
+
+	start := time.Now()
+	if _, err := c.Predict("t1", nil); err == nil {
+		t.Fatal("no error from a service slower than the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > predictTimeout+time.Second {
+		t.Errorf("Predict took %s, past the %s deadline", elapsed, predictTimeout)
+	}
+}
@@ -0,0 +1,101 @@
+package aiclient
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker stops calling the AI service after Threshold consecutive
+// failures. Once Cooldown has passed a single trial call is let through;
+// success closes the breaker again, failure re-opens it.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     breakerState
+	openedAt  time.Time
+	trialBusy bool
+
+	// OnStateChange, if set, is called with true when the breaker opens
+	// and false when it closes.
+	OnStateChange func(open bool)
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Allow reports whether a call may go to the protected service.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.trialBusy = true
+		return true
+	case breakerHalfOpen:
+		if b.trialBusy {
+			return false
+		}
+		b.trialBusy = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.state != breakerClosed
+	b.failures = 0
+	b.state = breakerClosed
+	b.trialBusy = false
+	if wasOpen && b.OnStateChange != nil {
+		b.OnStateChange(false)
+	}
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trialBusy = false
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		wasClosed := b.state == breakerClosed
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		if wasClosed && b.OnStateChange != nil {
+			b.OnStateChange(true)
+		}
+	}
+}
+
+// Open reports whether calls are currently short-circuited.
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != breakerClosed
+}
@@ -0,0 +1,60 @@
+package aiclient
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCircuitBreakerTransitions walks the breaker from closed to open,
+// through a failed and a successful half-open trial, back to closed.
+func TestCircuitBreakerTransitions(t *testing.T) {
+	b := NewCircuitBreaker(2, 20*time.Millisecond)
+	var changes []bool
+	b.OnStateChange = func(open bool) { changes = append(changes, open) }
+
+	b.Failure()
+	if b.Open() || !b.Allow() {
+		t.Fatal("open after one failure, threshold is 2")
+	}
+	b.Failure()
+	if !b.Open() || b.Allow() {
+		t.Fatal("calls let through after reaching the threshold")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("no trial call after the cooldown")
+	}
+	if b.Allow() {
+		t.Error("a second call let through while the trial runs")
+	}
+	b.Failure()
+	if !b.Open() || b.Allow() {
+		t.Fatal("a failed trial did not re-open the breaker")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("no trial call after the second cooldown")
+	}
+	b.Success()
+	if b.Open() || !b.Allow() || !b.Allow() {
+		t.Fatal("a successful trial did not close the breaker")
+	}
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("state changes %v, want [true false]", changes)
+	}
+}
+
+// TestCircuitBreakerSuccessResets expects a success to reset the count of
+// consecutive failures.
+func TestCircuitBreakerSuccessResets(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Minute)
+	b.Failure()
+	b.Success()
+	b.Failure()
+	if b.Open() {
+		t.Error("opened on failures that were not consecutive")
+	}
+}
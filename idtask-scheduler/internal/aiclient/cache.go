@@ -0,0 +1,201 @@
+package aiclient
+
+import (
+	"container/list"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"google.golang.org/protobuf/proto"
+)
+
+type cacheEntry struct {
+	key       string
+	resp      *pb.PredictResponse
+	expiresAt time.Time
+}
+
+// CachingPredictor answers repeated task signatures from memory. The
+// signature is built from the configured metadata keys, e.g. TaskType and
+// Priority. Entries expire after ttl, the least recently used entry is
+// evicted beyond size, and everything is dropped when the model version
+// reported by the AI service changes. Hits never reach the service, so a
+// new version is noticed on the next miss; within a batch, hits predicted
+// by an older model than the batch's misses are predicted again. Only the priority and duration parts
+// are cached: a recommended worker holds for the task it was made for, so a
+// cache hit leaves the worker to the scheduling strategy's own choice.
+type CachingPredictor struct {
+	next    Predictor
+	keys    []string
+	ttl     time.Duration
+	size    int
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	version string
+}
+
+func NewCachingPredictor(next Predictor, keys []string, ttl time.Duration, size int) *CachingPredictor {
+	if size <= 0 {
+		size = 1
+	}
+	return &CachingPredictor{
+		next:    next,
+		keys:    keys,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *CachingPredictor) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	key := c.signature(metadata)
+	if resp, ok := c.get(key); ok {
+		return resp, nil
+	}
+
+	resp, err := c.next.Predict(taskID, metadata)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, resp)
+	return resp, nil
+}
+
+func (c *CachingPredictor) PredictBatch(reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	resps := make([]*pb.PredictResponse, len(reqs))
+	keys := make([]string, len(reqs))
+
+	var missIdx []int
+	for i, req := range reqs {
+		keys[i] = c.signature(req.Metadata)
+		if resp, ok := c.get(keys[i]); ok {
+			resps[i] = resp
+			continue
+		}
+		missIdx = append(missIdx, i)
+	}
+	if err := c.fetch(reqs, keys, resps, missIdx); err != nil {
+		return nil, err
+	}
+
+	// the misses may have brought a new model version, the hits served
+	// from the old one are not kept
+	var staleIdx []int
+	for i, resp := range resps {
+		if !c.current(resp) {
+			staleIdx = append(staleIdx, i)
+		}
+	}
+	if err := c.fetch(reqs, keys, resps, staleIdx); err != nil {
+		return nil, err
+	}
+	return resps, nil
+}
+
+// fetch predicts the requests at idx through the wrapped predictor and
+// caches the answers.
+func (c *CachingPredictor) fetch(reqs []*pb.PredictRequest, keys []string, resps []*pb.PredictResponse, idx []int) error {
+	if len(idx) == 0 {
+		return nil
+	}
+	batch := make([]*pb.PredictRequest, len(idx))
+	for j, i := range idx {
+		batch[j] = reqs[i]
+	}
+
+	fetched, err := PredictAll(c.next, batch)
+	if err != nil {
+		return err
+	}
+	for j, i := range idx {
+		resps[i] = fetched[j]
+		c.put(keys[i], fetched[j])
+	}
+	return nil
+}
+
+// current reports whether resp comes from the latest model version seen.
+func (c *CachingPredictor) current(resp *pb.PredictResponse) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return resp.ModelVersion == c.version
+}
+
+// ReportOutcome passes reports through when the wrapped predictor takes them.
+func (c *CachingPredictor) ReportOutcome(report *pb.OutcomeReport) error {
+	if r, ok := c.next.(OutcomeReporter); ok {
+		return r.ReportOutcome(report)
+	}
+	return nil
+}
+
+func (c *CachingPredictor) signature(metadata map[string]string) string {
+	parts := make([]string, len(c.keys))
+	for i, k := range c.keys {
+		parts[i] = metadata[k]
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+func (c *CachingPredictor) get(key string) (*pb.PredictResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok && time.Now().After(el.Value.(*cacheEntry).expiresAt) {
+		c.removeLocked(el)
+		ok = false
+	}
+	if !ok {
+		monitor.AIPredictionCache().WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	monitor.AIPredictionCache().WithLabelValues("hit").Inc()
+	return proto.Clone(el.Value.(*cacheEntry).resp).(*pb.PredictResponse), true
+}
+
+func (c *CachingPredictor) put(key string, resp *pb.PredictResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if resp.ModelVersion != c.version {
+		if c.version != "" {
+			log.Printf("[cache] Model version changed %s -> %s, dropping %d cached predictions", c.version, resp.ModelVersion, c.lru.Len())
+		}
+		c.entries = make(map[string]*list.Element)
+		c.lru.Init()
+		c.version = resp.ModelVersion
+	}
+
+	cached := proto.Clone(resp).(*pb.PredictResponse)
+	cached.RecommendedWorker, cached.WorkerConfidence = "", 0
+	entry := &cacheEntry{
+		key:       key,
+		resp:      cached,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[key] = c.lru.PushFront(entry)
+	}
+
+	for c.lru.Len() > c.size {
+		c.removeLocked(c.lru.Back())
+	}
+	monitor.AIPredictionCacheEntries().Set(float64(c.lru.Len()))
+}
+
+func (c *CachingPredictor) removeLocked(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+	monitor.AIPredictionCacheEntries().Set(float64(c.lru.Len()))
+}
@@ -0,0 +1,88 @@
+package aiclient
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+)
+
+// recommending recommends a worker of its own for every task, answering
+// with the model version set in version.
+type recommending struct {
+	calls   int
+	version string
+}
+
+func (r *recommending) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	r.calls++
+	return &pb.PredictResponse{Priority: 3, EstimatedTime: 2, RecommendedWorker: "worker-for-" + taskID, WorkerConfidence: 0.9, ModelVersion: r.version}, nil
+}
+
+// TestCacheLeavesWorkerOut expects a cache hit to carry the cached
+// estimate but no recommended worker, the first task's worker must not be
+// handed to every task sharing its signature.
+func TestCacheLeavesWorkerOut(t *testing.T) {
+	next := &recommending{version: "v1"}
+	c := NewCachingPredictor(next, []string{"TaskType"}, time.Minute, 10)
+	meta := map[string]string{"TaskType": "render"}
+
+	first, err := c.Predict("t1", meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.RecommendedWorker != "worker-for-t1" {
+		t.Errorf("miss recommended %q, want the service's worker", first.RecommendedWorker)
+	}
+
+	hit, err := c.Predict("t2", meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.calls != 1 {
+		t.Errorf("%d predictions, want the second one cached", next.calls)
+	}
+	if hit.RecommendedWorker != "" || hit.WorkerConfidence != 0 {
+		t.Errorf("hit recommended %q (%.1f), want no worker", hit.RecommendedWorker, hit.WorkerConfidence)
+	}
+	if hit.Priority != 3 || hit.EstimatedTime != 2 {
+		t.Errorf("hit priority %d, estimate %.1f, want the cached 3 and 2", hit.Priority, hit.EstimatedTime)
+	}
+}
+
+// TestCacheBatchModelVersion changes the model version between two
+// batches. The second batch's miss reveals it, and its hit from the old
+// model must be predicted again.
+func TestCacheBatchModelVersion(t *testing.T) {
+	next := &recommending{version: "v1"}
+	c := NewCachingPredictor(next, []string{"TaskType"}, time.Minute, 10)
+
+	if _, err := c.PredictBatch([]*pb.PredictRequest{{TaskId: "t1", Metadata: map[string]string{"TaskType": "render"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	next.version = "v2"
+	resps, err := c.PredictBatch([]*pb.PredictRequest{
+		{TaskId: "t2", Metadata: map[string]string{"TaskType": "render"}},
+		{TaskId: "t3", Metadata: map[string]string{"TaskType": "encode"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, resp := range resps {
+		if resp.ModelVersion != "v2" {
+			t.Errorf("response %d from model %q, want v2", i, resp.ModelVersion)
+		}
+	}
+	if next.calls != 3 {
+		t.Errorf("%d predictions, want the stale hit predicted again", next.calls)
+	}
+
+	hit, err := c.Predict("t4", map[string]string{"TaskType": "render"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit.ModelVersion != "v2" || next.calls != 3 {
+		t.Errorf("hit from model %q after %d predictions, want v2 from the cache", hit.ModelVersion, next.calls)
+	}
+}
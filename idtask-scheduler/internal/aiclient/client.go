@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +23,11 @@ var (
 type AIClient struct {
 	conn   *grpc.ClientConn
 	client pb.AIPredictorClient
+
+	// set by EnableBatching
+	queue       chan *pendingPrediction
+	batchSize   int
+	batchWindow time.Duration
 }
 
 func NewAIClient(addr string) (*AIClient, error) {
@@ -36,7 +42,13 @@ func NewAIClient(addr string) (*AIClient, error) {
 
 func Init() {
 	once.Do(func() {
-		client, _ = NewAIClient(configs.Config.AIPredictURL)
+		var err error
+		client, err = NewAIClient(configs.Config.AIPredictURL)
+		if err != nil {
+			log.Printf("⚠️ AI client unavailable, predictions will use the fallback: %v", err)
+			return
+		}
+		client.EnableBatching(configs.Config.AIBatchSize, configs.Config.AIBatchWindow)
 	})
 }
 
@@ -47,15 +59,51 @@ func GetClient() *AIClient {
 	return client
 }
 
-func (c *AIClient) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
+// Primary returns the shared client, wrapped in a CachingPredictor when
+// AI_CACHE_TTL is set, or nil if Init could not create the client.
+func Primary() Predictor {
+	if client == nil {
+		return nil
+	}
+	if configs.Config.AICacheTTL <= 0 {
+		return client
+	}
+	keys := strings.Split(configs.Config.AICacheKey, ",")
+	for i := range keys {
+		keys[i] = strings.TrimSpace(keys[i])
+	}
+	return NewCachingPredictor(client, keys, configs.Config.AICacheTTL, configs.Config.AICacheSize)
+}
+
+// Reporter returns the shared client as an OutcomeReporter, or nil if Init
+// could not create it, so callers can skip reporting instead of failing.
+func Reporter() OutcomeReporter {
+	if client == nil {
+		return nil
+	}
+	return client
+}
 
+// predictTimeout bounds a prediction, batched or not, from the caller's
+// side, and a PredictBatch call as a whole.
+const predictTimeout = 2 * time.Second
+
+func (c *AIClient) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
 	req := &pb.PredictRequest{
 		TaskId:   taskID,
 		Metadata: metadata,
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), predictTimeout)
+	defer cancel()
+
+	if c.queue != nil {
+		return c.predictBatched(ctx, req)
+	}
+	return c.predictOne(ctx, req)
+}
+
+func (c *AIClient) predictOne(ctx context.Context, req *pb.PredictRequest) (*pb.PredictResponse, error) {
 	resp, err := c.client.Predict(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("gRPC Predict call failed: %w", err)
@@ -63,6 +111,18 @@ func (c *AIClient) Predict(taskID string, metadata map[string]string) (*pb.Predi
 	return resp, nil
 }
 
+// ReportOutcome sends the actual result of a task back to the AI service.
+func (c *AIClient) ReportOutcome(report *pb.OutcomeReport) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.client.ReportOutcome(ctx, report)
+	if err != nil {
+		return fmt.Errorf("gRPC ReportOutcome call failed: %w", err)
+	}
+	return nil
+}
+
 func (c *AIClient) Close() {
 	c.conn.Close()
 }
@@ -0,0 +1,76 @@
+package aiclient
+
+import (
+	"log"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+)
+
+// FallbackPredictor calls the primary predictor through a circuit breaker
+// and answers from the fallback whenever the primary fails or the breaker
+// is open, so scheduling never stalls on the AI service.
+type FallbackPredictor struct {
+	primary  Predictor
+	fallback Predictor
+	breaker  *CircuitBreaker
+}
+
+// NewFallbackPredictor wraps primary, which may be nil if it could not be created.
+func NewFallbackPredictor(primary, fallback Predictor, breaker *CircuitBreaker) *FallbackPredictor {
+	breaker.OnStateChange = func(open bool) {
+		if open {
+			log.Println("⚠️ AI circuit breaker opened, using fallback predictor")
+			monitor.SchedulerAIBreakerOpen().Set(1)
+		} else {
+			log.Println("✅ AI circuit breaker closed, AI predictions resumed")
+			monitor.SchedulerAIBreakerOpen().Set(0)
+		}
+	}
+
+	return &FallbackPredictor{
+		primary:  primary,
+		fallback: fallback,
+		breaker:  breaker,
+	}
+}
+
+// WithFallback wraps the shared client from Init, see Primary. If Init could
+// not create it, every prediction goes to fallback.
+func WithFallback(fallback Predictor, breaker *CircuitBreaker) *FallbackPredictor {
+	return NewFallbackPredictor(Primary(), fallback, breaker)
+}
+
+func (f *FallbackPredictor) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	if f.primary != nil && f.breaker.Allow() {
+		resp, err := f.primary.Predict(taskID, metadata)
+		if err == nil {
+			f.breaker.Success()
+			monitor.SchedulerPredictions().WithLabelValues("ai").Inc()
+			return resp, nil
+		}
+
+		f.breaker.Failure()
+		log.Printf("[predict] AI prediction failed for task %s, using fallback: %v", taskID, err)
+	}
+
+	monitor.SchedulerPredictions().WithLabelValues("fallback").Inc()
+	return f.fallback.Predict(taskID, metadata)
+}
+
+func (f *FallbackPredictor) PredictBatch(reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	if f.primary != nil && f.breaker.Allow() {
+		resps, err := PredictAll(f.primary, reqs)
+		if err == nil {
+			f.breaker.Success()
+			monitor.SchedulerPredictions().WithLabelValues("ai").Add(float64(len(reqs)))
+			return resps, nil
+		}
+
+		f.breaker.Failure()
+		log.Printf("[predict] AI batch prediction of %d tasks failed, using fallback: %v", len(reqs), err)
+	}
+
+	monitor.SchedulerPredictions().WithLabelValues("fallback").Add(float64(len(reqs)))
+	return PredictAll(f.fallback, reqs)
+}
@@ -78,8 +78,16 @@ type PredictResponse struct {
 	Priority          int32                  `protobuf:"varint,1,opt,name=priority,proto3" json:"priority,omitempty"`
 	EstimatedTime     float32                `protobuf:"fixed32,2,opt,name=estimated_time,json=estimatedTime,proto3" json:"estimated_time,omitempty"`
 	RecommendedWorker string                 `protobuf:"bytes,3,opt,name=recommended_worker,json=recommendedWorker,proto3" json:"recommended_worker,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+	// Identifies the trained model; clients drop cached predictions when it changes.
+	ModelVersion string `protobuf:"bytes,4,opt,name=model_version,json=modelVersion,proto3" json:"model_version,omitempty"`
+	// Confidence scores in [0, 1] for each predicted value.
+	PriorityConfidence float32 `protobuf:"fixed32,5,opt,name=priority_confidence,json=priorityConfidence,proto3" json:"priority_confidence,omitempty"`
+	TimeConfidence     float32 `protobuf:"fixed32,6,opt,name=time_confidence,json=timeConfidence,proto3" json:"time_confidence,omitempty"`
+	WorkerConfidence   float32 `protobuf:"fixed32,7,opt,name=worker_confidence,json=workerConfidence,proto3" json:"worker_confidence,omitempty"`
+	// Contribution of each input feature to the prediction.
+	FeatureAttributions map[string]float32 `protobuf:"bytes,8,rep,name=feature_attributions,json=featureAttributions,proto3" json:"feature_attributions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed32,2,opt,name=value"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
 func (x *PredictResponse) Reset() {
@@ -133,6 +141,277 @@ func (x *PredictResponse) GetRecommendedWorker() string {
 	return ""
 }
 
+func (x *PredictResponse) GetModelVersion() string {
+	if x != nil {
+		return x.ModelVersion
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetPriorityConfidence() float32 {
+	if x != nil {
+		return x.PriorityConfidence
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetTimeConfidence() float32 {
+	if x != nil {
+		return x.TimeConfidence
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetWorkerConfidence() float32 {
+	if x != nil {
+		return x.WorkerConfidence
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetFeatureAttributions() map[string]float32 {
+	if x != nil {
+		return x.FeatureAttributions
+	}
+	return nil
+}
+
+// Several predictions in one round trip. Responses are returned in the
+// same order as the requests.
+type PredictBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*PredictRequest      `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictBatchRequest) Reset() {
+	*x = PredictBatchRequest{}
+	mi := &file_predict_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictBatchRequest) ProtoMessage() {}
+
+func (x *PredictBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_predict_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictBatchRequest.ProtoReflect.Descriptor instead.
+func (*PredictBatchRequest) Descriptor() ([]byte, []int) {
+	return file_predict_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PredictBatchRequest) GetRequests() []*PredictRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type PredictBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Responses     []*PredictResponse     `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictBatchResponse) Reset() {
+	*x = PredictBatchResponse{}
+	mi := &file_predict_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictBatchResponse) ProtoMessage() {}
+
+func (x *PredictBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_predict_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictBatchResponse.ProtoReflect.Descriptor instead.
+func (*PredictBatchResponse) Descriptor() ([]byte, []int) {
+	return file_predict_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PredictBatchResponse) GetResponses() []*PredictResponse {
+	if x != nil {
+		return x.Responses
+	}
+	return nil
+}
+
+// Actual result of a task, sent after execution so the model can be
+// retrained on real outcomes. The predicted fields echo what Predict returned.
+type OutcomeReport struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	TaskId            string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Metadata          map[string]string      `protobuf:"bytes,2,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Worker            string                 `protobuf:"bytes,3,opt,name=worker,proto3" json:"worker,omitempty"`
+	Success           bool                   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	ActualTime        float32                `protobuf:"fixed32,5,opt,name=actual_time,json=actualTime,proto3" json:"actual_time,omitempty"`
+	PredictedPriority int32                  `protobuf:"varint,6,opt,name=predicted_priority,json=predictedPriority,proto3" json:"predicted_priority,omitempty"`
+	PredictedTime     float32                `protobuf:"fixed32,7,opt,name=predicted_time,json=predictedTime,proto3" json:"predicted_time,omitempty"`
+	PredictedWorker   string                 `protobuf:"bytes,8,opt,name=predicted_worker,json=predictedWorker,proto3" json:"predicted_worker,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *OutcomeReport) Reset() {
+	*x = OutcomeReport{}
+	mi := &file_predict_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutcomeReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutcomeReport) ProtoMessage() {}
+
+func (x *OutcomeReport) ProtoReflect() protoreflect.Message {
+	mi := &file_predict_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutcomeReport.ProtoReflect.Descriptor instead.
+func (*OutcomeReport) Descriptor() ([]byte, []int) {
+	return file_predict_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *OutcomeReport) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *OutcomeReport) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *OutcomeReport) GetWorker() string {
+	if x != nil {
+		return x.Worker
+	}
+	return ""
+}
+
+func (x *OutcomeReport) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *OutcomeReport) GetActualTime() float32 {
+	if x != nil {
+		return x.ActualTime
+	}
+	return 0
+}
+
+func (x *OutcomeReport) GetPredictedPriority() int32 {
+	if x != nil {
+		return x.PredictedPriority
+	}
+	return 0
+}
+
+func (x *OutcomeReport) GetPredictedTime() float32 {
+	if x != nil {
+		return x.PredictedTime
+	}
+	return 0
+}
+
+func (x *OutcomeReport) GetPredictedWorker() string {
+	if x != nil {
+		return x.PredictedWorker
+	}
+	return ""
+}
+
+type OutcomeAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutcomeAck) Reset() {
+	*x = OutcomeAck{}
+	mi := &file_predict_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutcomeAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutcomeAck) ProtoMessage() {}
+
+func (x *OutcomeAck) ProtoReflect() protoreflect.Message {
+	mi := &file_predict_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutcomeAck.ProtoReflect.Descriptor instead.
+func (*OutcomeAck) Descriptor() ([]byte, []int) {
+	return file_predict_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *OutcomeAck) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
 var File_predict_proto protoreflect.FileDescriptor
 
 const file_predict_proto_rawDesc = "" +
@@ -143,13 +422,43 @@ const file_predict_proto_rawDesc = "" +
 	"\bmetadata\x18\x02 \x03(\v2%.predict.PredictRequest.MetadataEntryR\bmetadata\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x83\x01\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xdd\x03\n" +
 	"\x0fPredictResponse\x12\x1a\n" +
 	"\bpriority\x18\x01 \x01(\x05R\bpriority\x12%\n" +
 	"\x0eestimated_time\x18\x02 \x01(\x02R\restimatedTime\x12-\n" +
-	"\x12recommended_worker\x18\x03 \x01(\tR\x11recommendedWorker2K\n" +
+	"\x12recommended_worker\x18\x03 \x01(\tR\x11recommendedWorker\x12#\n" +
+	"\rmodel_version\x18\x04 \x01(\tR\fmodelVersion\x12/\n" +
+	"\x13priority_confidence\x18\x05 \x01(\x02R\x12priorityConfidence\x12'\n" +
+	"\x0ftime_confidence\x18\x06 \x01(\x02R\x0etimeConfidence\x12+\n" +
+	"\x11worker_confidence\x18\a \x01(\x02R\x10workerConfidence\x12d\n" +
+	"\x14feature_attributions\x18\b \x03(\v21.predict.PredictResponse.FeatureAttributionsEntryR\x13featureAttributions\x1aF\n" +
+	"\x18FeatureAttributionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x02R\x05value:\x028\x01\"J\n" +
+	"\x13PredictBatchRequest\x123\n" +
+	"\brequests\x18\x01 \x03(\v2\x17.predict.PredictRequestR\brequests\"N\n" +
+	"\x14PredictBatchResponse\x126\n" +
+	"\tresponses\x18\x01 \x03(\v2\x18.predict.PredictResponseR\tresponses\"\xfb\x02\n" +
+	"\rOutcomeReport\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12@\n" +
+	"\bmetadata\x18\x02 \x03(\v2$.predict.OutcomeReport.MetadataEntryR\bmetadata\x12\x16\n" +
+	"\x06worker\x18\x03 \x01(\tR\x06worker\x12\x18\n" +
+	"\asuccess\x18\x04 \x01(\bR\asuccess\x12\x1f\n" +
+	"\vactual_time\x18\x05 \x01(\x02R\n" +
+	"actualTime\x12-\n" +
+	"\x12predicted_priority\x18\x06 \x01(\x05R\x11predictedPriority\x12%\n" +
+	"\x0epredicted_time\x18\a \x01(\x02R\rpredictedTime\x12)\n" +
+	"\x10predicted_worker\x18\b \x01(\tR\x0fpredictedWorker\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"(\n" +
+	"\n" +
+	"OutcomeAck\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\bR\baccepted2\xd6\x01\n" +
 	"\vAIPredictor\x12<\n" +
-	"\aPredict\x12\x17.predict.PredictRequest\x1a\x18.predict.PredictResponseB:Z8github.com/JamesDante/idtask-scheduler/internal/aiclientb\x06proto3"
+	"\aPredict\x12\x17.predict.PredictRequest\x1a\x18.predict.PredictResponse\x12<\n" +
+	"\rReportOutcome\x12\x16.predict.OutcomeReport\x1a\x13.predict.OutcomeAck\x12K\n" +
+	"\fPredictBatch\x12\x1c.predict.PredictBatchRequest\x1a\x1d.predict.PredictBatchResponseB:Z8github.com/JamesDante/idtask-scheduler/internal/aiclientb\x06proto3"
 
 var (
 	file_predict_proto_rawDescOnce sync.Once
@@ -163,21 +472,35 @@ func file_predict_proto_rawDescGZIP() []byte {
 	return file_predict_proto_rawDescData
 }
 
-var file_predict_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_predict_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_predict_proto_goTypes = []any{
-	(*PredictRequest)(nil),  // 0: predict.PredictRequest
-	(*PredictResponse)(nil), // 1: predict.PredictResponse
-	nil,                     // 2: predict.PredictRequest.MetadataEntry
+	(*PredictRequest)(nil),       // 0: predict.PredictRequest
+	(*PredictResponse)(nil),      // 1: predict.PredictResponse
+	(*PredictBatchRequest)(nil),  // 2: predict.PredictBatchRequest
+	(*PredictBatchResponse)(nil), // 3: predict.PredictBatchResponse
+	(*OutcomeReport)(nil),        // 4: predict.OutcomeReport
+	(*OutcomeAck)(nil),           // 5: predict.OutcomeAck
+	nil,                          // 6: predict.PredictRequest.MetadataEntry
+	nil,                          // 7: predict.PredictResponse.FeatureAttributionsEntry
+	nil,                          // 8: predict.OutcomeReport.MetadataEntry
 }
 var file_predict_proto_depIdxs = []int32{
-	2, // 0: predict.PredictRequest.metadata:type_name -> predict.PredictRequest.MetadataEntry
-	0, // 1: predict.AIPredictor.Predict:input_type -> predict.PredictRequest
-	1, // 2: predict.AIPredictor.Predict:output_type -> predict.PredictResponse
-	2, // [2:3] is the sub-list for method output_type
-	1, // [1:2] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	6, // 0: predict.PredictRequest.metadata:type_name -> predict.PredictRequest.MetadataEntry
+	7, // 1: predict.PredictResponse.feature_attributions:type_name -> predict.PredictResponse.FeatureAttributionsEntry
+	0, // 2: predict.PredictBatchRequest.requests:type_name -> predict.PredictRequest
+	1, // 3: predict.PredictBatchResponse.responses:type_name -> predict.PredictResponse
+	8, // 4: predict.OutcomeReport.metadata:type_name -> predict.OutcomeReport.MetadataEntry
+	0, // 5: predict.AIPredictor.Predict:input_type -> predict.PredictRequest
+	4, // 6: predict.AIPredictor.ReportOutcome:input_type -> predict.OutcomeReport
+	2, // 7: predict.AIPredictor.PredictBatch:input_type -> predict.PredictBatchRequest
+	1, // 8: predict.AIPredictor.Predict:output_type -> predict.PredictResponse
+	5, // 9: predict.AIPredictor.ReportOutcome:output_type -> predict.OutcomeAck
+	3, // 10: predict.AIPredictor.PredictBatch:output_type -> predict.PredictBatchResponse
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_predict_proto_init() }
@@ -191,7 +514,7 @@ func file_predict_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_predict_proto_rawDesc), len(file_predict_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AIPredictor_Predict_FullMethodName = "/predict.AIPredictor/Predict"
+	AIPredictor_Predict_FullMethodName       = "/predict.AIPredictor/Predict"
+	AIPredictor_ReportOutcome_FullMethodName = "/predict.AIPredictor/ReportOutcome"
+	AIPredictor_PredictBatch_FullMethodName  = "/predict.AIPredictor/PredictBatch"
 )
 
 // AIPredictorClient is the client API for AIPredictor service.
@@ -27,6 +29,8 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type AIPredictorClient interface {
 	Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error)
+	ReportOutcome(ctx context.Context, in *OutcomeReport, opts ...grpc.CallOption) (*OutcomeAck, error)
+	PredictBatch(ctx context.Context, in *PredictBatchRequest, opts ...grpc.CallOption) (*PredictBatchResponse, error)
 }
 
 type aIPredictorClient struct {
@@ -47,11 +51,33 @@ func (c *aIPredictorClient) Predict(ctx context.Context, in *PredictRequest, opt
 	return out, nil
 }
 
+func (c *aIPredictorClient) ReportOutcome(ctx context.Context, in *OutcomeReport, opts ...grpc.CallOption) (*OutcomeAck, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OutcomeAck)
+	err := c.cc.Invoke(ctx, AIPredictor_ReportOutcome_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aIPredictorClient) PredictBatch(ctx context.Context, in *PredictBatchRequest, opts ...grpc.CallOption) (*PredictBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PredictBatchResponse)
+	err := c.cc.Invoke(ctx, AIPredictor_PredictBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AIPredictorServer is the server API for AIPredictor service.
 // All implementations must embed UnimplementedAIPredictorServer
 // for forward compatibility.
 type AIPredictorServer interface {
 	Predict(context.Context, *PredictRequest) (*PredictResponse, error)
+	ReportOutcome(context.Context, *OutcomeReport) (*OutcomeAck, error)
+	PredictBatch(context.Context, *PredictBatchRequest) (*PredictBatchResponse, error)
 	mustEmbedUnimplementedAIPredictorServer()
 }
 
@@ -65,6 +91,12 @@ type UnimplementedAIPredictorServer struct{}
 func (UnimplementedAIPredictorServer) Predict(context.Context, *PredictRequest) (*PredictResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Predict not implemented")
 }
+func (UnimplementedAIPredictorServer) ReportOutcome(context.Context, *OutcomeReport) (*OutcomeAck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportOutcome not implemented")
+}
+func (UnimplementedAIPredictorServer) PredictBatch(context.Context, *PredictBatchRequest) (*PredictBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PredictBatch not implemented")
+}
 func (UnimplementedAIPredictorServer) mustEmbedUnimplementedAIPredictorServer() {}
 func (UnimplementedAIPredictorServer) testEmbeddedByValue()                     {}
 
@@ -104,6 +136,42 @@ func _AIPredictor_Predict_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AIPredictor_ReportOutcome_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OutcomeReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AIPredictorServer).ReportOutcome(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AIPredictor_ReportOutcome_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AIPredictorServer).ReportOutcome(ctx, req.(*OutcomeReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AIPredictor_PredictBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PredictBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AIPredictorServer).PredictBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AIPredictor_PredictBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AIPredictorServer).PredictBatch(ctx, req.(*PredictBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AIPredictor_ServiceDesc is the grpc.ServiceDesc for AIPredictor service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +183,14 @@ var AIPredictor_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Predict",
 			Handler:    _AIPredictor_Predict_Handler,
 		},
+		{
+			MethodName: "ReportOutcome",
+			Handler:    _AIPredictor_ReportOutcome_Handler,
+		},
+		{
+			MethodName: "PredictBatch",
+			Handler:    _AIPredictor_PredictBatch_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "predict.proto",
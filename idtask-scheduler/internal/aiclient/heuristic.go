@@ -0,0 +1,76 @@
+package aiclient
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// defaultEstimatedTime is used for task types with no execution history.
+const defaultEstimatedTime = 1.0
+
+// HeuristicPredictor is a Go-native Predictor built on historical averages
+// per task type. It never recommends a worker, which makes chooseWorker pick
+// the least-loaded one.
+type HeuristicPredictor struct {
+	stats   func() (map[string]models.TaskTypeStats, error)
+	refresh time.Duration
+
+	mu       sync.Mutex
+	cache    map[string]models.TaskTypeStats
+	loadedAt time.Time
+}
+
+// NewHeuristicPredictor reloads stats at most once per refresh interval,
+// e.g. NewHeuristicPredictor(storage.GetTaskTypeStats, time.Minute).
+func NewHeuristicPredictor(stats func() (map[string]models.TaskTypeStats, error), refresh time.Duration) *HeuristicPredictor {
+	return &HeuristicPredictor{
+		stats:   stats,
+		refresh: refresh,
+	}
+}
+
+func (h *HeuristicPredictor) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	stats, ok := h.typeStats()[metadata["TaskType"]]
+
+	estimated := defaultEstimatedTime
+	if ok && stats.AvgDurationSeconds > 0 {
+		estimated = stats.AvgDurationSeconds
+	}
+
+	priority, err := strconv.Atoi(metadata["Priority"])
+	if err != nil && ok {
+		priority = int(math.Round(stats.AvgPriority))
+	}
+
+	return &pb.PredictResponse{
+		Priority:      int32(priority),
+		EstimatedTime: float32(estimated),
+	}, nil
+}
+
+func (h *HeuristicPredictor) typeStats() map[string]models.TaskTypeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cache != nil && time.Since(h.loadedAt) < h.refresh {
+		return h.cache
+	}
+
+	stats, err := h.stats()
+	if err != nil {
+		log.Printf("[heuristic] Failed to load task type stats: %v", err)
+		// keep serving the previous snapshot, retry on the next refresh
+		h.loadedAt = time.Now()
+		return h.cache
+	}
+
+	h.cache = stats
+	h.loadedAt = time.Now()
+	return h.cache
+}
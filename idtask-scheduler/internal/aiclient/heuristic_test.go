@@ -0,0 +1,74 @@
+package aiclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+func TestHeuristicPredictor(t *testing.T) {
+	loads := 0
+	h := NewHeuristicPredictor(func() (map[string]models.TaskTypeStats, error) {
+		loads++
+		return map[string]models.TaskTypeStats{
+			"render": {Type: "render", AvgDurationSeconds: 4.5, AvgPriority: 2.6, Samples: 10},
+		}, nil
+	}, time.Minute)
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		priority int32
+		estimate float32
+	}{
+		{"known type, submitted priority", map[string]string{"TaskType": "render", "Priority": "7"}, 7, 4.5},
+		{"known type, average priority", map[string]string{"TaskType": "render"}, 3, 4.5},
+		{"unknown type", map[string]string{"TaskType": "other", "Priority": "1"}, 1, defaultEstimatedTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := h.Predict("t1", tt.metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Priority != tt.priority || resp.EstimatedTime != tt.estimate || resp.RecommendedWorker != "" {
+				t.Errorf("got priority %d, estimate %.1f, worker %q; want %d, %.1f and none",
+					resp.Priority, resp.EstimatedTime, resp.RecommendedWorker, tt.priority, tt.estimate)
+			}
+		})
+	}
+	if loads != 1 {
+		t.Errorf("stats loaded %d times within the refresh interval, want 1", loads)
+	}
+}
+
+// failing is a primary predictor that is always down.
+type failing struct{ calls int }
+
+func (f *failing) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	f.calls++
+	return nil, errors.New("unavailable")
+}
+
+// TestFallbackPredictor expects the fallback to answer while the primary
+// fails, and the primary to be left alone once the breaker opens.
+func TestFallbackPredictor(t *testing.T) {
+	primary := &failing{}
+	f := NewFallbackPredictor(primary, StubPredictor{EstimatedTime: 2}, NewCircuitBreaker(2, time.Minute))
+
+	for i := 0; i < 5; i++ {
+		resp, err := f.Predict("t1", map[string]string{"Priority": "4"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Priority != 4 || resp.EstimatedTime != 2 {
+			t.Errorf("call %d answered %+v, want the fallback's", i, resp)
+		}
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary called %d times, want 2 before the breaker opened", primary.calls)
+	}
+}
@@ -0,0 +1,44 @@
+package aiclient
+
+import (
+	"strconv"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+)
+
+// Predictor is what the scheduler needs from the AI service.
+// AIClient talks to the Python gRPC service; StubPredictor answers locally.
+type Predictor interface {
+	Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error)
+}
+
+// OutcomeReporter feeds actual task outcomes back to the prediction model.
+type OutcomeReporter interface {
+	ReportOutcome(report *pb.OutcomeReport) error
+}
+
+// StubPredictor is a fixed, dependency-free Predictor for the embedded mode.
+// It echoes the submitted priority and never recommends a worker, so the
+// scheduler falls back to its own worker selection.
+type StubPredictor struct {
+	EstimatedTime float32
+}
+
+func (s StubPredictor) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	priority, _ := strconv.Atoi(metadata["Priority"])
+
+	estimated := s.EstimatedTime
+	if estimated <= 0 {
+		estimated = 1
+	}
+
+	return &pb.PredictResponse{
+		Priority:      int32(priority),
+		EstimatedTime: estimated,
+	}, nil
+}
+
+// ReportOutcome discards the report, the stub has nothing to learn.
+func (s StubPredictor) ReportOutcome(report *pb.OutcomeReport) error {
+	return nil
+}
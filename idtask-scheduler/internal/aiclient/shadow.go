@@ -0,0 +1,142 @@
+package aiclient
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+)
+
+// NamedPredictor is a predictor with the name it is reported under.
+type NamedPredictor struct {
+	Name      string
+	Predictor Predictor
+}
+
+// ShadowPredictor answers from the primary predictor and calls every shadow
+// predictor with the same tasks in the background. Shadow answers never
+// reach the scheduler; they are only recorded, next to the primary's, so a
+// new model can be compared against production before it takes over.
+// At most AI_SHADOW_CONCURRENCY shadow calls and writes run at once, a slow
+// shadow sheds work instead of piling it up.
+type ShadowPredictor struct {
+	primary NamedPredictor
+	shadows []NamedPredictor
+	record  func(p *models.PredictorPrediction) error
+	slots   chan struct{}
+}
+
+// NewShadowPredictor wraps primary. record stores each prediction, usually
+// storage.CreatePredictorPrediction.
+func NewShadowPredictor(primary NamedPredictor, shadows []NamedPredictor, record func(p *models.PredictorPrediction) error) *ShadowPredictor {
+	return &ShadowPredictor{
+		primary: primary,
+		shadows: shadows,
+		record:  record,
+		slots:   make(chan struct{}, max(configs.Config.AIShadowConcurrency, 1)),
+	}
+}
+
+// Shadows connects to every endpoint in AI_SHADOW_URLS, a comma separated
+// list of name=host:port entries. Endpoints that cannot be reached are
+// logged and skipped.
+func Shadows() []NamedPredictor {
+	var shadows []NamedPredictor
+	for _, entry := range strings.Split(configs.Config.AIShadowURLs, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, addr = entry, entry
+		}
+
+		c, err := NewAIClient(addr)
+		if err != nil {
+			log.Printf("⚠️ Shadow predictor %s unavailable: %v", name, err)
+			continue
+		}
+		c.EnableBatching(configs.Config.AIBatchSize, configs.Config.AIBatchWindow)
+		shadows = append(shadows, NamedPredictor{Name: name, Predictor: c})
+	}
+	return shadows
+}
+
+func (s *ShadowPredictor) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	reqs := []*pb.PredictRequest{{TaskId: taskID, Metadata: metadata}}
+	resps, err := s.PredictBatch(reqs)
+	if err != nil {
+		return nil, err
+	}
+	return resps[0], nil
+}
+
+func (s *ShadowPredictor) PredictBatch(reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	for _, shadow := range s.shadows {
+		s.background(shadow.Name, "predict", func() {
+			s.call(shadow, "shadow", reqs)
+		})
+	}
+	return s.call(s.primary, "primary", reqs)
+}
+
+// background runs job in a free slot, or drops it and counts the drop.
+func (s *ShadowPredictor) background(name, stage string, job func()) {
+	select {
+	case s.slots <- struct{}{}:
+		go func() {
+			defer func() { <-s.slots }()
+			job()
+		}()
+	default:
+		monitor.AIShadowDropped().WithLabelValues(name, stage).Inc()
+	}
+}
+
+func (s *ShadowPredictor) call(np NamedPredictor, role string, reqs []*pb.PredictRequest) ([]*pb.PredictResponse, error) {
+	start := time.Now()
+	resps, err := PredictAll(np.Predictor, reqs)
+	monitor.AIPredictorLatency().WithLabelValues(np.Name).Observe(time.Since(start).Seconds())
+
+	if err == nil && len(resps) != len(reqs) {
+		err = fmt.Errorf("predictor %s returned %d responses for %d requests", np.Name, len(resps), len(reqs))
+	}
+	if err != nil {
+		monitor.AIPredictorRequests().WithLabelValues(np.Name, role, "error").Add(float64(len(reqs)))
+		if role == "shadow" {
+			log.Printf("[predict] shadow predictor %s failed: %v", np.Name, err)
+		}
+		return nil, err
+	}
+	monitor.AIPredictorRequests().WithLabelValues(np.Name, role, "ok").Add(float64(len(reqs)))
+
+	// recording must not hold up the scheduling loop
+	s.background(np.Name, "record", func() {
+		s.recordAll(np.Name, role, reqs, resps)
+	})
+	return resps, nil
+}
+
+func (s *ShadowPredictor) recordAll(name, role string, reqs []*pb.PredictRequest, resps []*pb.PredictResponse) {
+	for i, resp := range resps {
+		p := models.PredictorPrediction{
+			TaskID:            reqs[i].TaskId,
+			Predictor:         name,
+			Role:              role,
+			Priority:          resp.Priority,
+			EstimatedTime:     float64(resp.EstimatedTime),
+			RecommendedWorker: resp.RecommendedWorker,
+			ModelVersion:      resp.ModelVersion,
+		}
+		if err := s.record(&p); err != nil {
+			log.Printf("⚠️ Failed to record %s prediction for task %s: %v", name, p.TaskID, err)
+		}
+	}
+}
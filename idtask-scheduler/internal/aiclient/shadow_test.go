@@ -0,0 +1,65 @@
+package aiclient
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+)
+
+// blocking holds every call until release is closed.
+type blocking struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blocking) Predict(taskID string, metadata map[string]string) (*pb.PredictResponse, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &pb.PredictResponse{Priority: 1}, nil
+}
+
+func TestShadowPredictorDropsOverflow(t *testing.T) {
+	configs.Config.AIShadowConcurrency = 1
+	defer func() { configs.Config.AIShadowConcurrency = 0 }()
+
+	slow := &blocking{started: make(chan struct{}, 2), release: make(chan struct{})}
+	defer close(slow.release)
+
+	s := NewShadowPredictor(
+		NamedPredictor{Name: "test-primary", Predictor: StubPredictor{}},
+		[]NamedPredictor{{Name: "test-shadow", Predictor: slow}},
+		func(p *models.PredictorPrediction) error { return nil },
+	)
+
+	dropped := func(name, stage string) float64 {
+		return testutil.ToFloat64(monitor.AIShadowDropped().WithLabelValues(name, stage))
+	}
+	predictBefore, recordBefore := dropped("test-shadow", "predict"), dropped("test-primary", "record")
+
+	reqs := []*pb.PredictRequest{{TaskId: "t1", Metadata: map[string]string{"Priority": "4"}}}
+	for i := 0; i < 3; i++ {
+		resps, err := s.PredictBatch(reqs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resps) != 1 || resps[0].Priority != 4 {
+			t.Fatalf("got %v, want the primary's answer", resps)
+		}
+		if i == 0 {
+			// the shadow now holds the only slot
+			<-slow.started
+		}
+	}
+
+	if got := dropped("test-shadow", "predict") - predictBefore; got != 2 {
+		t.Errorf("dropped %v shadow calls, want 2", got)
+	}
+	if got := dropped("test-primary", "record") - recordBefore; got != 3 {
+		t.Errorf("dropped %v primary writes, want 3", got)
+	}
+}
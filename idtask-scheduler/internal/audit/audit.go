@@ -0,0 +1,37 @@
+// Package audit appends state changing actions to the audit log, from API
+// calls and from scheduler leaders acting on their own.
+package audit
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/storage"
+)
+
+const (
+	Success = "success"
+	Denied  = "denied"
+	Failure = "failure"
+)
+
+// Params encodes an action's parameters for AuditEvent.Params, nil for none.
+func Params(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Record appends e to the audit log. The action already happened, so a
+// failure to record it is logged rather than returned.
+func Record(e *models.AuditEvent) {
+	if err := storage.CreateAuditEvent(e); err != nil {
+		log.Printf("⚠️ Failed to record audit event %s on %s: %v", e.Action, e.Target, err)
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/storage"
+)
+
+// KeyPrefix starts every API key, so keys are told apart from JWTs in an
+// Authorization header and are easy to spot in leaked text.
+const KeyPrefix = "idt_"
+
+// NewKey returns a random API key and its hash.
+func NewKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashKey(key), nil
+}
+
+// HashKey is how keys are stored. Keys are random, so a plain SHA-256 is
+// enough and, unlike a salted hash, lets a key be looked up by its hash.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeys authenticates requests by an API key in the X-API-Key header or
+// as a bearer token.
+type APIKeys struct{}
+
+func (APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		if token := bearer(r); strings.HasPrefix(token, KeyPrefix) {
+			key = token
+		}
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	k, err := storage.GetAPIKeyByHash(HashKey(key))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: k.Subject, Method: "apikey", KeyID: k.ID, Roles: k.Roles}, nil
+}
+
+// EnsureKey stores key under name unless it is already stored, so a key
+// given in the environment can bootstrap access to the key endpoints.
+func EnsureKey(id, name, subject, key string, roles []string) error {
+	hash := HashKey(key)
+	if _, err := storage.GetAPIKeyByHash(hash); err == nil || !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return storage.CreateAPIKey(&models.APIKey{ID: id, Name: name, Subject: subject, Hash: hash, Roles: roles, CreatedBy: "config"})
+}
@@ -0,0 +1,82 @@
+// Package auth resolves API requests to the principal making them, from
+// API keys stored hashed or from JWTs signed by a key in a JWKS file.
+// Authenticators are tried in turn, so methods can be combined.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials the
+	// authenticator understands, the next one may.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means the credentials were recognised but are
+	// wrong, expired or revoked.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is who a request acts as.
+type Principal struct {
+	// Subject identifies the principal, recorded on the tasks it creates.
+	Subject string `json:"subject"`
+	// Method is how it authenticated: "apikey", "jwt" or "none".
+	Method string `json:"method"`
+	// KeyID is the API key used, for the apikey method.
+	KeyID string `json:"key_id,omitempty"`
+	// Roles are grants carried by the credentials themselves, as
+	// "role@tenant", see Policy.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Anonymous is the principal of every request while authentication is off.
+var Anonymous = &Principal{Subject: "anonymous", Method: "none"}
+
+// Authenticator resolves a request to its principal.
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials if the request has no
+	// credentials of its kind, ErrInvalidCredentials if they are wrong.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries each authenticator until one recognises the credentials.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// bearer returns the token of an "Authorization: Bearer" header.
+func bearer(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored by WithPrincipal, Anonymous if
+// there is none.
+func FromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalKey{}).(*Principal); ok {
+		return p
+	}
+	return Anonymous
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwksReload bounds how often an unknown key ID rereads the JWKS file, so
+// tokens with made up key IDs cannot keep the API busy reading it.
+const jwksReload = 10 * time.Second
+
+// JWT authenticates requests by a bearer JWT signed with one of the keys
+// of a JWKS file. The token's "sub" claim is the principal's subject and
+// its roles claim, if any, the principal's roles.
+type JWT struct {
+	file       string
+	issuer     string
+	audience   string
+	rolesClaim string
+	parser     *jwt.Parser
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewJWT loads the JWKS file. Empty issuer or audience are not checked.
+// rolesClaim names the claim listing "role@tenant" grants, as an array or
+// a space separated string.
+func NewJWT(jwksFile, issuer, audience, rolesClaim string) (*JWT, error) {
+	j := &JWT{
+		file:       jwksFile,
+		issuer:     issuer,
+		audience:   audience,
+		rolesClaim: rolesClaim,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512",
+		})),
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearer(r)
+	if token == "" || strings.HasPrefix(token, KeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(token, claims, j.keyFor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if j.issuer != "" && !claims.VerifyIssuer(j.issuer, true) {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidCredentials)
+	}
+	if j.audience != "" && !claims.VerifyAudience(j.audience, true) {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidCredentials)
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: sub, Method: "jwt", Roles: j.roles(claims)}, nil
+}
+
+func (j *JWT) roles(claims jwt.MapClaims) []string {
+	if j.rolesClaim == "" {
+		return nil
+	}
+	switch v := claims[j.rolesClaim].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// keyFor finds the key a token names in its "kid" header, rereading the
+// file once for a key it does not know in case the keys were rotated.
+func (j *JWT) keyFor(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if key := j.lookup(kid); key != nil {
+		return key, nil
+	}
+
+	j.mu.RLock()
+	recent := time.Since(j.loadedAt) < jwksReload
+	j.mu.RUnlock()
+	if !recent {
+		if err := j.load(); err != nil {
+			return nil, err
+		}
+		if key := j.lookup(kid); key != nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookup returns the key with the ID, or the only key for tokens without one.
+func (j *JWT) lookup(kid string) crypto.PublicKey {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			return k
+		}
+	}
+	return j.keys[kid]
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWT) load() error {
+	data, err := os.ReadFile(j.file)
+	if err != nil {
+		return fmt.Errorf("read jwks: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks has no signing keys")
+	}
+
+	j.mu.Lock()
+	j.keys, j.loadedAt = keys, time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64Int(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64Int(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64Int(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64Int(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func b64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
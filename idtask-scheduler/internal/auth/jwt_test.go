@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// writeJWKS writes a JWKS file with the public halves of the keys and
+// returns its path.
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+	set := map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa-1", Use: "sig", N: b64(rsaKey.N), E: b64(big.NewInt(int64(rsaKey.E)))},
+		{Kty: "EC", Kid: "ec-1", Crv: "P-256", X: b64(ecKey.X), Y: b64(ecKey.Y)},
+		{Kty: "RSA", Kid: "enc-1", Use: "enc", N: b64(rsaKey.N), E: b64(big.NewInt(int64(rsaKey.E)))},
+	}}
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	j, err := NewJWT(writeJWKS(t, rsaKey, ecKey), "https://issuer.test", "idtask", "roles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub": "alice",
+			"iss": "https://issuer.test",
+			"aud": "idtask",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"rsa", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(nil)), nil},
+		{"ec", sign(t, jwt.SigningMethodES256, "ec-1", ecKey, claims(nil)), nil},
+		{"audience list", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["aud"] = []string{"other", "idtask"}
+		})), nil},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["exp"] = now.Add(-time.Minute).Unix()
+		})), ErrInvalidCredentials},
+		{"not yet valid", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["nbf"] = now.Add(time.Hour).Unix()
+		})), ErrInvalidCredentials},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["iss"] = "https://evil.test"
+		})), ErrInvalidCredentials},
+		{"no issuer", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			delete(c, "iss")
+		})), ErrInvalidCredentials},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["aud"] = "other"
+		})), ErrInvalidCredentials},
+		{"no audience", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			delete(c, "aud")
+		})), ErrInvalidCredentials},
+		{"no subject", sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			delete(c, "sub")
+		})), ErrInvalidCredentials},
+		{"other key", sign(t, jwt.SigningMethodRS256, "rsa-1", otherKey, claims(nil)), ErrInvalidCredentials},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, claims(nil)), ErrInvalidCredentials},
+		{"encryption key", sign(t, jwt.SigningMethodRS256, "enc-1", rsaKey, claims(nil)), ErrInvalidCredentials},
+		{"hmac", sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), claims(nil)), ErrInvalidCredentials},
+		{"garbage", "not.a.jwt", ErrInvalidCredentials},
+		{"api key", KeyPrefix + "abc", ErrNoCredentials},
+		{"none", "", ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			p, err := j.Authenticate(r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && (p.Subject != "alice" || p.Method != "jwt") {
+				t.Errorf("principal = %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWTRoles(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	j, err := NewJWT(writeJWKS(t, rsaKey, ecKey), "", "", "roles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		roles interface{}
+		want  []string
+	}{
+		{"array", []string{"operator@analytics", "viewer"}, []string{"operator@analytics", "viewer"}},
+		{"space separated", "operator@analytics  viewer", []string{"operator@analytics", "viewer"}},
+		{"missing", nil, nil},
+		{"wrong type", 42, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+			if tt.roles != nil {
+				claims["roles"] = tt.roles
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims))
+			p, err := j.Authenticate(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(p.Roles) != fmt.Sprint(tt.want) {
+				t.Errorf("roles = %v, want %v", p.Roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTWithoutIssuerOrAudience(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	j, err := NewJWT(writeJWKS(t, rsaKey, ecKey), "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+		"sub": "bob",
+		"iss": "anyone",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	if p, err := j.Authenticate(r); err != nil || p.Subject != "bob" {
+		t.Fatalf("Authenticate = %+v, %v", p, err)
+	}
+}
+
+func TestNewJWTInvalidJWKS(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"not json":      `{`,
+		"no keys":       `{"keys":[]}`,
+		"only enc keys": `{"keys":[{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+		"unknown kty":   `{"keys":[{"kty":"oct","kid":"a"}]}`,
+		"unknown curve": `{"keys":[{"kty":"EC","kid":"a","crv":"P-192","x":"AQ","y":"AQ"}]}`,
+		"bad base64":    `{"keys":[{"kty":"RSA","kid":"a","n":"!!","e":"AQAB"}]}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".json")
+			os.WriteFile(path, []byte(content), 0o600)
+			if _, err := NewJWT(path, "", "", ""); err == nil {
+				t.Fatal("NewJWT accepted an invalid JWKS")
+			}
+		})
+	}
+	if _, err := NewJWT(filepath.Join(dir, "missing.json"), "", "", ""); err == nil {
+		t.Fatal("NewJWT accepted a missing file")
+	}
+}
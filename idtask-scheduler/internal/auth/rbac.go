@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission is an operation a role may perform.
+type Permission string
+
+const (
+	PermTaskSubmit   Permission = "task:submit"
+	PermTaskList     Permission = "task:list"
+	PermTaskCancel   Permission = "task:cancel"
+	PermDLQRequeue   Permission = "dlq:requeue"
+	PermWorkerDrain  Permission = "worker:drain"
+	PermStatusView   Permission = "status:view"
+	PermKeyManage    Permission = "key:manage"
+	PermAuditView    Permission = "audit:view"
+	PermSchemaManage Permission = "schema:manage"
+)
+
+// AnyTenant scopes a grant to every tenant. Operations that are not about
+// one tenant's tasks, like worker status or key management, need it.
+const AnyTenant = "*"
+
+// DefaultRoles are the built in roles, RBAC_ROLES adds to or replaces them.
+var DefaultRoles = map[string][]Permission{
+	"viewer":    {PermTaskList, PermStatusView},
+	"submitter": {PermTaskSubmit, PermTaskList},
+	"operator":  {PermTaskList, PermTaskCancel, PermDLQRequeue, PermWorkerDrain, PermStatusView},
+	"admin":     {"*"},
+}
+
+// Grant gives a role within one tenant, or every tenant for AnyTenant.
+type Grant struct {
+	Role   string
+	Tenant string
+}
+
+func (g Grant) String() string {
+	return g.Role + "@" + g.Tenant
+}
+
+// ParseGrant reads "role@tenant", the tenant defaulting to AnyTenant.
+func ParseGrant(s string) Grant {
+	role, tenant, ok := strings.Cut(strings.TrimSpace(s), "@")
+	if !ok || tenant == "" {
+		tenant = AnyTenant
+	}
+	return Grant{Role: role, Tenant: tenant}
+}
+
+// Policy decides what principals may do from the roles granted to them.
+type Policy struct {
+	roles    map[string][]Permission
+	bindings map[string][]Grant
+	defaults []Grant
+}
+
+// NewPolicy builds a policy from
+//   - roles as "role=perm,perm" entries separated by ";", on top of DefaultRoles
+//   - bindings as "subject=role@tenant,role@tenant" entries separated by ";"
+//   - grants every authenticated principal has, as "role@tenant,..."
+func NewPolicy(roles, bindings, defaults string) (*Policy, error) {
+	p := &Policy{
+		roles:    make(map[string][]Permission, len(DefaultRoles)),
+		bindings: make(map[string][]Grant),
+	}
+	for role, perms := range DefaultRoles {
+		p.roles[role] = perms
+	}
+
+	for _, entry := range splitEntries(roles) {
+		role, perms, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(role) == "" {
+			return nil, fmt.Errorf("invalid role %q, want role=perm,...", entry)
+		}
+		var list []Permission
+		for _, perm := range strings.Split(perms, ",") {
+			if perm = strings.TrimSpace(perm); perm != "" {
+				list = append(list, Permission(perm))
+			}
+		}
+		p.roles[strings.TrimSpace(role)] = list
+	}
+
+	for _, entry := range splitEntries(bindings) {
+		subject, grants, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(subject) == "" {
+			return nil, fmt.Errorf("invalid binding %q, want subject=role@tenant,...", entry)
+		}
+		parsed, err := p.parseGrants(grants)
+		if err != nil {
+			return nil, err
+		}
+		subject = strings.TrimSpace(subject)
+		p.bindings[subject] = append(p.bindings[subject], parsed...)
+	}
+
+	var err error
+	if p.defaults, err = p.parseGrants(defaults); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) parseGrants(spec string) ([]Grant, error) {
+	var grants []Grant
+	for _, s := range strings.Split(spec, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		g := ParseGrant(s)
+		if _, ok := p.roles[g.Role]; !ok {
+			return nil, fmt.Errorf("unknown role %q", g.Role)
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// ValidGrant reports whether g names a known role.
+func (p *Policy) ValidGrant(g Grant) bool {
+	_, ok := p.roles[g.Role]
+	return ok
+}
+
+// Allowed reports whether the principal may perform perm on tenant's
+// tasks, AnyTenant for operations not about one tenant. Requests are
+// anonymous only while authentication is off, so they may do anything.
+func (p *Policy) Allowed(pr *Principal, perm Permission, tenant string) bool {
+	if pr.Method == Anonymous.Method {
+		return true
+	}
+	for _, g := range p.grants(pr) {
+		if g.Tenant != AnyTenant && g.Tenant != tenant {
+			continue
+		}
+		for _, have := range p.roles[g.Role] {
+			if have == "*" || have == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// grants are the principal's own roles, its bindings and the defaults.
+func (p *Policy) grants(pr *Principal) []Grant {
+	grants := make([]Grant, 0, len(pr.Roles)+len(p.bindings[pr.Subject])+len(p.defaults))
+	for _, r := range pr.Roles {
+		grants = append(grants, ParseGrant(r))
+	}
+	grants = append(grants, p.bindings[pr.Subject]...)
+	return append(grants, p.defaults...)
+}
+
+func splitEntries(spec string) []string {
+	var out []string
+	for _, entry := range strings.Split(spec, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
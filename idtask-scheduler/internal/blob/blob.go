@@ -0,0 +1,104 @@
+// Package blob keeps task payloads too large to copy through the Redis
+// queues. The API stores such a payload once and queues the task with a
+// reference, PayloadRef; the worker fetches the payload when it runs the
+// task and deletes it once the task is finished.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// ErrNotFound is returned for a key with no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store holds blobs by key. FileStore keeps them in a directory,
+// MemoryStore in process for the embedded mode; an S3 compatible store
+// can implement the same interface.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes a blob, a missing one is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	store Store
+	once  sync.Once
+)
+
+// Init opens the store named by configs.Config.BlobStore.
+func Init() {
+	once.Do(func() {
+		switch configs.Config.BlobStore {
+		case "file":
+			fs, err := NewFileStore(configs.Config.BlobDir)
+			if err != nil {
+				log.Fatalf("❌ Blob store failed: %v", err)
+			}
+			store = fs
+			log.Println("✅ Blob store ready:", configs.Config.BlobDir)
+			if configs.Config.PayloadOffloadBytes > 0 && !filepath.IsAbs(configs.Config.BlobDir) {
+				log.Printf("⚠️ BLOB_DIR %q is relative, the API and the workers must all resolve it to the same directory", configs.Config.BlobDir)
+			}
+		default:
+			log.Fatalf("❌ Unknown BLOB_STORE %q", configs.Config.BlobStore)
+		}
+	})
+}
+
+// Use installs a store, e.g. a MemoryStore.
+func Use(s Store) {
+	store = s
+}
+
+// GetStore returns the store installed by Init or Use.
+func GetStore() Store {
+	if store == nil {
+		log.Fatal("Blob store not initialized. Call blob.Init() first.")
+	}
+	return store
+}
+
+// Offload moves t's payload to the store if it is larger than threshold
+// bytes, leaving PayloadRef in its place. A threshold of 0 never offloads.
+func Offload(ctx context.Context, t *models.Task, threshold int) error {
+	if threshold <= 0 || len(t.Payload) <= threshold {
+		return nil
+	}
+	if err := GetStore().Put(ctx, t.ID, t.Payload); err != nil {
+		return fmt.Errorf("store payload of task %s: %w", t.ID, err)
+	}
+	t.PayloadRef, t.Payload = t.ID, nil
+	return nil
+}
+
+// Load fetches an offloaded payload back into t.
+func Load(ctx context.Context, t *models.Task) error {
+	if t.PayloadRef == "" {
+		return nil
+	}
+	data, err := GetStore().Get(ctx, t.PayloadRef)
+	if err != nil {
+		return fmt.Errorf("fetch payload of task %s: %w", t.ID, err)
+	}
+	t.Payload = data
+	return nil
+}
+
+// Release deletes an offloaded payload once its task will not run again.
+func Release(ctx context.Context, t *models.Task) {
+	if t.PayloadRef == "" {
+		return
+	}
+	if err := GetStore().Delete(ctx, t.PayloadRef); err != nil {
+		log.Printf("⚠️ Failed to delete payload of task %s: %v", t.ID, err)
+	}
+}
@@ -0,0 +1,30 @@
+// Package cancel flags cancelled tasks in Redis. The API marks a task when
+// it is cancelled; schedulers and workers check the mark and drop the task
+// wherever it is queued instead of searching every queue for it.
+package cancel
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ttl outlives any task, which expire after a day by default.
+const ttl = 48 * time.Hour
+
+func key(taskID string) string {
+	return "task-cancelled:" + taskID
+}
+
+// Mark flags the task as cancelled.
+func Mark(ctx context.Context, rdb *redis.Client, taskID string) error {
+	return rdb.Set(ctx, key(taskID), 1, ttl).Err()
+}
+
+// Cancelled reports whether the task was cancelled. Redis errors count as
+// not cancelled, running a cancelled task beats losing a live one.
+func Cancelled(ctx context.Context, rdb *redis.Client, taskID string) bool {
+	n, err := rdb.Exists(ctx, key(taskID)).Result()
+	return err == nil && n > 0
+}
@@ -0,0 +1,26 @@
+// Package drain marks workers an operator is draining. The API puts the
+// mark in etcd under the worker's own lease, so it goes away with the
+// worker; the worker then publishes itself as "draining" and schedulers
+// stop sending it tasks while it finishes the ones it has.
+package drain
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Status is the worker status published while draining.
+const Status = "draining"
+
+// Key is the etcd key marking the worker as draining.
+func Key(workerID string) string {
+	return "/drain/" + workerID
+}
+
+// Draining reports whether the worker is marked. etcd errors count as not
+// draining, an idle worker beats a lost one.
+func Draining(ctx context.Context, cli *clientv3.Client, workerID string) bool {
+	resp, err := cli.Get(ctx, Key(workerID), clientv3.WithCountOnly())
+	return err == nil && resp.Count > 0
+}
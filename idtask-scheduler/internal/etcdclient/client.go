@@ -25,6 +25,11 @@ func Init() {
 	log.Println("✅ etcd connected:", configs.Config.EtcdAddress)
 }
 
+// Use installs an already connected client, e.g. one backed by an embedded etcd.
+func Use(c *clientv3.Client) {
+	cli = c
+}
+
 func GetClient() *clientv3.Client {
 	if cli == nil {
 		log.Fatal("etcd client not initialized. Call etcdclient.Init() first.")
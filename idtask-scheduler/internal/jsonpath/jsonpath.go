@@ -0,0 +1,707 @@
+// Package jsonpath evaluates the subset of Postgres SQL/JSON path
+// expressions the task list accepts as payload filters, so that the
+// memory store matches tasks the way Postgres' @? and @@ operators do.
+//
+// An expression is either a path, which matches when it selects anything:
+//
+//	$.tags[*] ? (@ == "urgent")
+//
+// or a predicate, which matches when it is true:
+//
+//	$.width > 100 && $.format == "png"
+//
+// Paths start at $ and use .key, ."quoted key", .*, [n], [*] and ? (filter)
+// steps; filters refer to the current item as @. Predicates compare with
+// ==, !=, <>, <, <=, > and >=, test with exists (path) and starts with
+// "prefix", and combine with &&, || and !. Literals are JSON strings,
+// numbers, true, false and null. Evaluation is lax: arrays are unwrapped
+// where an object or a single value is expected, and comparisons between
+// mismatched types are unknown rather than errors, as in Postgres.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	path *path
+	pred predicate
+}
+
+// Compile parses an expression, failing on syntax outside the subset.
+func Compile(src string) (*Expr, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if p.peek().text == "lax" {
+		p.next()
+	}
+
+	e := &Expr{src: src}
+	n, err := p.expr(false)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	switch n := n.(type) {
+	case *path:
+		e.path = n
+	case predicate:
+		e.pred = n
+	default:
+		return nil, fmt.Errorf("a bare literal is not a path or a predicate")
+	}
+	return e, nil
+}
+
+// String returns the expression as written, which Postgres accepts as is.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Predicate reports whether the expression is a predicate, matched with
+// Postgres' @@, rather than a path, matched with @?.
+func (e *Expr) Predicate() bool {
+	return e.pred != nil
+}
+
+// Match reports whether doc matches: the path selects at least one item,
+// or the predicate is true. Documents that are not JSON never match.
+func (e *Expr) Match(doc json.RawMessage) bool {
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return false
+	}
+	if e.pred != nil {
+		return e.pred.eval(root, nil) == isTrue
+	}
+	return len(e.path.eval(root, nil)) > 0
+}
+
+// truth is SQL/JSON's three-valued logic.
+type truth int
+
+const (
+	isFalse truth = iota
+	isTrue
+	isUnknown
+)
+
+func (t truth) not() truth {
+	switch t {
+	case isTrue:
+		return isFalse
+	case isFalse:
+		return isTrue
+	}
+	return isUnknown
+}
+
+type predicate interface {
+	eval(root, current any) truth
+}
+
+type operand interface {
+	values(root, current any) []any
+}
+
+type literal struct{ v any }
+
+func (l literal) values(root, current any) []any { return []any{l.v} }
+
+type stepKind int
+
+const (
+	stepMember stepKind = iota
+	stepAnyMember
+	stepIndex
+	stepAnyIndex
+	stepFilter
+)
+
+type step struct {
+	kind   stepKind
+	name   string
+	index  int
+	filter predicate
+}
+
+type path struct {
+	// current starts the path at @ instead of $.
+	current bool
+	steps   []step
+}
+
+func (p *path) values(root, current any) []any {
+	return unwrap(p.eval(root, current))
+}
+
+func (p *path) eval(root, current any) []any {
+	items := []any{root}
+	if p.current {
+		items = []any{current}
+	}
+	for _, s := range p.steps {
+		var next []any
+		for _, item := range items {
+			switch s.kind {
+			case stepMember:
+				for _, v := range unwrap([]any{item}) {
+					if obj, ok := v.(map[string]any); ok {
+						if m, ok := obj[s.name]; ok {
+							next = append(next, m)
+						}
+					}
+				}
+			case stepAnyMember:
+				for _, v := range unwrap([]any{item}) {
+					if obj, ok := v.(map[string]any); ok {
+						for _, m := range obj {
+							next = append(next, m)
+						}
+					}
+				}
+			case stepIndex:
+				arr, ok := item.([]any)
+				if !ok {
+					arr = []any{item}
+				}
+				if s.index < len(arr) {
+					next = append(next, arr[s.index])
+				}
+			case stepAnyIndex:
+				next = append(next, unwrap([]any{item})...)
+			case stepFilter:
+				for _, v := range unwrap([]any{item}) {
+					if s.filter.eval(root, v) == isTrue {
+						next = append(next, v)
+					}
+				}
+			}
+		}
+		items = next
+	}
+	return items
+}
+
+// unwrap replaces arrays in items by their elements, one level deep.
+func unwrap(items []any) []any {
+	var out []any
+	for _, item := range items {
+		if arr, ok := item.([]any); ok {
+			out = append(out, arr...)
+		} else {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+type and struct{ left, right predicate }
+
+func (n and) eval(root, current any) truth {
+	l, r := n.left.eval(root, current), n.right.eval(root, current)
+	switch {
+	case l == isFalse || r == isFalse:
+		return isFalse
+	case l == isTrue && r == isTrue:
+		return isTrue
+	}
+	return isUnknown
+}
+
+type or struct{ left, right predicate }
+
+func (n or) eval(root, current any) truth {
+	l, r := n.left.eval(root, current), n.right.eval(root, current)
+	switch {
+	case l == isTrue || r == isTrue:
+		return isTrue
+	case l == isFalse && r == isFalse:
+		return isFalse
+	}
+	return isUnknown
+}
+
+type not struct{ p predicate }
+
+func (n not) eval(root, current any) truth { return n.p.eval(root, current).not() }
+
+type exists struct{ p *path }
+
+func (n exists) eval(root, current any) truth {
+	if len(n.p.eval(root, current)) > 0 {
+		return isTrue
+	}
+	return isFalse
+}
+
+type startsWith struct {
+	left   operand
+	prefix string
+}
+
+func (n startsWith) eval(root, current any) truth {
+	result := isFalse
+	for _, v := range n.left.values(root, current) {
+		s, ok := v.(string)
+		switch {
+		case !ok:
+			result = isUnknown
+		case strings.HasPrefix(s, n.prefix):
+			return isTrue
+		}
+	}
+	return result
+}
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+// eval is true if any pair of the operands' items compares true, else
+// unknown if any pair could not be compared.
+func (n comparison) eval(root, current any) truth {
+	result := isFalse
+	for _, l := range n.left.values(root, current) {
+		for _, r := range n.right.values(root, current) {
+			switch compare(n.op, l, r) {
+			case isTrue:
+				return isTrue
+			case isUnknown:
+				result = isUnknown
+			}
+		}
+	}
+	return result
+}
+
+// compare compares two scalars as Postgres does: a null equals only a
+// null, and other items of different types, objects or arrays are not
+// comparable.
+func compare(op string, l, r any) truth {
+	if l == nil || r == nil {
+		if l != nil || r != nil {
+			return boolTruth(op == "!=" || op == "<>")
+		}
+		return boolTruth(op == "==" || op == "<=" || op == ">=")
+	}
+
+	var c int
+	switch l := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return isUnknown
+		}
+		c = cmpFloat(l, rv)
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return isUnknown
+		}
+		c = strings.Compare(l, rv)
+	case bool:
+		rv, ok := r.(bool)
+		if !ok {
+			return isUnknown
+		}
+		switch {
+		case l == rv:
+		case l:
+			c = 1
+		default:
+			c = -1
+		}
+	default:
+		return isUnknown
+	}
+
+	switch op {
+	case "==":
+		return boolTruth(c == 0)
+	case "!=", "<>":
+		return boolTruth(c != 0)
+	case "<":
+		return boolTruth(c < 0)
+	case "<=":
+		return boolTruth(c <= 0)
+	case ">":
+		return boolTruth(c > 0)
+	}
+	return boolTruth(c >= 0)
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolTruth(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokPunct
+	tokIdent
+	tokString
+	tokNumber
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src  string
+	toks []token
+	i    int
+	// inFilter allows @, which only exists inside filters.
+	inFilter bool
+}
+
+var punct = []string{"==", "!=", "<>", "<=", ">=", "&&", "||", "$", "@", ".", "[", "]", "(", ")", "?", "*", "<", ">", "!"}
+
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("at %d: unterminated string", i)
+			}
+			p.toks = append(p.toks, token{tokString, s[i : j+1], i})
+			i = j + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				if (s[j] == '+' || s[j] == '-') && s[j-1] != 'e' && s[j-1] != 'E' {
+					break
+				}
+				j++
+			}
+			p.toks = append(p.toks, token{tokNumber, s[i:j], i})
+			i = j
+		case c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || (s[j]|0x20 >= 'a' && s[j]|0x20 <= 'z') || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			p.toks = append(p.toks, token{tokIdent, s[i:j], i})
+			i = j
+		default:
+			matched := false
+			for _, op := range punct {
+				if strings.HasPrefix(s[i:], op) {
+					p.toks = append(p.toks, token{tokPunct, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return fmt.Errorf("at %d: unexpected %q", i, c)
+			}
+		}
+	}
+	p.toks = append(p.toks, token{tokEOF, "", len(s)})
+	return nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("at %d: "+format, append([]any{t.pos}, args...)...)
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.next(); t.text != text || t.kind == tokString {
+		return p.errorf(t, "expected %q", text)
+	}
+	return nil
+}
+
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return t.text == text && (t.kind == tokPunct || t.kind == tokIdent)
+}
+
+// expr parses an || chain. It returns a *path, a literal or a predicate;
+// needPred requires a predicate.
+func (p *parser) expr(needPred bool) (any, error) {
+	left, err := p.and(needPred)
+	if err != nil {
+		return nil, err
+	}
+	for p.is("||") {
+		t := p.next()
+		right, err := p.and(true)
+		if err != nil {
+			return nil, err
+		}
+		l, ok := left.(predicate)
+		if !ok {
+			return nil, p.errorf(t, "|| needs predicates on both sides")
+		}
+		left = or{l, right.(predicate)}
+	}
+	return left, nil
+}
+
+func (p *parser) and(needPred bool) (any, error) {
+	left, err := p.unary(needPred)
+	if err != nil {
+		return nil, err
+	}
+	for p.is("&&") {
+		t := p.next()
+		right, err := p.unary(true)
+		if err != nil {
+			return nil, err
+		}
+		l, ok := left.(predicate)
+		if !ok {
+			return nil, p.errorf(t, "&& needs predicates on both sides")
+		}
+		left = and{l, right.(predicate)}
+	}
+	return left, nil
+}
+
+func (p *parser) unary(needPred bool) (any, error) {
+	t := p.peek()
+	switch {
+	case p.is("!"):
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.expr(true)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return not{inner.(predicate)}, nil
+	case p.is("("):
+		p.next()
+		inner, err := p.expr(needPred)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return p.comparison(inner, needPred, t)
+	case p.is("exists"):
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		pt, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return exists{pt}, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return p.comparison(left, needPred, t)
+}
+
+// comparison parses what may follow an operand: a comparison operator or
+// starts with.
+func (p *parser) comparison(left any, needPred bool, start token) (any, error) {
+	if _, ok := left.(predicate); ok {
+		return left, nil
+	}
+	l := left.(operand)
+
+	t := p.peek()
+	switch {
+	case t.kind == tokPunct && strings.Contains(" == != <> < <= > >= ", " "+t.text+" "):
+		p.next()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{t.text, l, right}, nil
+	case p.is("starts"):
+		p.next()
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+		s := p.next()
+		if s.kind != tokString {
+			return nil, p.errorf(s, "starts with needs a string")
+		}
+		prefix, err := unquote(s.text)
+		if err != nil {
+			return nil, p.errorf(s, "%v", err)
+		}
+		return startsWith{l, prefix}, nil
+	}
+	if needPred {
+		return nil, p.errorf(start, "expected a predicate, e.g. a comparison")
+	}
+	return left, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.peek()
+	switch {
+	case p.is("$") || p.is("@"):
+		return p.path()
+	case t.kind == tokString:
+		p.next()
+		s, err := unquote(t.text)
+		if err != nil {
+			return nil, p.errorf(t, "%v", err)
+		}
+		return literal{s}, nil
+	case t.kind == tokNumber:
+		p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "bad number %q", t.text)
+		}
+		return literal{f}, nil
+	case p.is("true"), p.is("false"):
+		p.next()
+		return literal{t.text == "true"}, nil
+	case p.is("null"):
+		p.next()
+		return literal{nil}, nil
+	case t.kind == tokEOF:
+		return nil, p.errorf(t, "unexpected end")
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+func (p *parser) path() (*path, error) {
+	t := p.next()
+	pt := &path{}
+	switch {
+	case t.text == "$" && t.kind == tokPunct:
+	case t.text == "@" && t.kind == tokPunct:
+		if !p.inFilter {
+			return nil, p.errorf(t, "@ is only allowed in filters")
+		}
+		pt.current = true
+	default:
+		return nil, p.errorf(t, "a path starts with $")
+	}
+
+	for {
+		switch {
+		case p.is("."):
+			p.next()
+			k := p.next()
+			switch {
+			case k.kind == tokIdent:
+				pt.steps = append(pt.steps, step{kind: stepMember, name: k.text})
+			case k.kind == tokString:
+				name, err := unquote(k.text)
+				if err != nil {
+					return nil, p.errorf(k, "%v", err)
+				}
+				pt.steps = append(pt.steps, step{kind: stepMember, name: name})
+			case k.text == "*" && k.kind == tokPunct:
+				pt.steps = append(pt.steps, step{kind: stepAnyMember})
+			default:
+				return nil, p.errorf(k, "expected a key after '.'")
+			}
+		case p.is("["):
+			p.next()
+			i := p.next()
+			switch {
+			case i.text == "*" && i.kind == tokPunct:
+				pt.steps = append(pt.steps, step{kind: stepAnyIndex})
+			case i.kind == tokNumber:
+				n, err := strconv.Atoi(i.text)
+				if err != nil || n < 0 {
+					return nil, p.errorf(i, "an index must be a non-negative integer")
+				}
+				pt.steps = append(pt.steps, step{kind: stepIndex, index: n})
+			default:
+				return nil, p.errorf(i, "expected an index or *")
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		case p.is("?"):
+			p.next()
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			outer := p.inFilter
+			p.inFilter = true
+			f, err := p.expr(true)
+			p.inFilter = outer
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			pt.steps = append(pt.steps, step{kind: stepFilter, filter: f.(predicate)})
+		default:
+			return pt, nil
+		}
+	}
+}
+
+func unquote(s string) (string, error) {
+	var out string
+	if err := json.Unmarshal([]byte(s), &out); err != nil {
+		return "", fmt.Errorf("bad string %s", s)
+	}
+	return out, nil
+}
@@ -0,0 +1,229 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		src       string
+		predicate bool
+	}{
+		{`$`, false},
+		{`$.a`, false},
+		{`lax $.a.b`, false},
+		{`$."a key".b`, false},
+		{`$.*`, false},
+		{`$.tags[*]`, false},
+		{`$.tags[0]`, false},
+		{`$.tags[*] ? (@ == "urgent")`, false},
+		{`$ ? (@.a > 1 && exists (@.b))`, false},
+		{`$.a ? (@.b ? (@ == 1) == 1)`, false},
+		{`($.a)`, false},
+		{`$.a == 1`, true},
+		{`$.a <> "x"`, true},
+		{`1 < $.a`, true},
+		{`$.a == 1 || $.b == 2`, true},
+		{`$.a == 1 && ($.b == 2 || $.c == 3)`, true},
+		{`!($.a == 1)`, true},
+		{`exists ($.a)`, true},
+		{`$.name starts with "ab"`, true},
+		{`($.a == 1)`, true},
+		{`$.a == null`, true},
+		{`$.a == true`, true},
+		{`$.a >= -1.5e3`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if e.Predicate() != tt.predicate {
+				t.Errorf("Predicate() = %v, want %v", e.Predicate(), tt.predicate)
+			}
+			if e.String() != tt.src {
+				t.Errorf("String() = %q", e.String())
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{``, "unexpected end"},
+		{`a.b`, `unexpected "a"`},
+		{`"a"`, "a bare literal is not a path or a predicate"},
+		{`42`, "a bare literal is not a path or a predicate"},
+		{`$.a ==`, "unexpected end"},
+		{`$.a = 1`, `unexpected '='`},
+		{`$.a == 1 extra`, `unexpected "extra"`},
+		{`$.a && $.b == 1`, "&& needs predicates on both sides"},
+		{`$.a == 1 || $.b`, "expected a predicate"},
+		{`!$.a`, `expected "("`},
+		{`!($.a)`, "expected a predicate"},
+		{`@.a == 1`, "@ is only allowed in filters"},
+		{`$.a ? (@ == 1`, `expected ")"`},
+		{`$.a ? (@)`, "expected a predicate"},
+		{`$.`, "expected a key after '.'"},
+		{`$.a[`, "expected an index or *"},
+		{`$.a[-1]`, "an index must be a non-negative integer"},
+		{`$.a[1.5]`, "an index must be a non-negative integer"},
+		{`$.a[0`, `expected "]"`},
+		{`$."unterminated`, "unterminated string"},
+		{`$."bad \q escape"`, "bad string"},
+		{`$.a == "bad \q"`, "bad string"},
+		{`$.a starts "x"`, `expected "with"`},
+		{`$.a starts with 1`, "starts with needs a string"},
+		{`exists $.a`, `expected "("`},
+		{`exists (1)`, "a path starts with $"},
+		{`$.a == 1e`, "bad number"},
+		{`$.a == #`, `unexpected '#'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Compile(%q) = %v, want %q", tt.src, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	doc := `{
+		"name": "resize-42",
+		"width": 640,
+		"ratio": 1.5,
+		"format": "png",
+		"urgent": true,
+		"owner": null,
+		"tags": ["urgent", "batch"],
+		"sizes": [320, 640, 1280],
+		"meta": {"a key": "spaced", "quote\"d": 1, "nested": {"depth": 2}},
+		"items": [{"id": 1, "ok": true}, {"id": 2, "ok": false}]
+	}`
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// paths match when they select anything
+		{`$`, true},
+		{`$.width`, true},
+		{`$.missing`, false},
+		{`$.meta.nested.depth`, true},
+		{`$.meta."a key"`, true},
+		{`$.meta."quote\"d"`, true},
+		{`$.meta.*`, true},
+		{`$.tags[1]`, true},
+		{`$.tags[2]`, false},
+		{`$.tags[*] ? (@ == "urgent")`, true},
+		{`$.tags[*] ? (@ == "later")`, false},
+		{`$.items ? (@.ok == true).id`, true},
+		{`$.items[*] ? (@.id > 5)`, false},
+		{`$ ? (@.width > 100 && @.format == "png")`, true},
+		{`$ ? (exists (@.meta.nested))`, true},
+		{`$.owner`, true},
+		// lax mode unwraps arrays where a member is expected
+		{`$.items.id`, true},
+		{`$.width[0]`, true},
+
+		// predicates match when they are true
+		{`$.width == 640`, true},
+		{`$.width != 640`, false},
+		{`$.width <> 320`, true},
+		{`$.width > 100`, true},
+		{`$.width >= 640`, true},
+		{`$.width < 640`, false},
+		{`$.width <= 640`, true},
+		{`$.ratio == 1.5`, true},
+		{`640 == $.width`, true},
+		{`$.format == "png"`, true},
+		{`$.format > "jpg"`, true},
+		{`$.urgent == true`, true},
+		{`$.urgent == false`, false},
+		{`$.owner == null`, true},
+		{`$.owner != null`, false},
+		{`$.width == null`, false},
+		{`$.width != null`, true},
+		{`$.sizes == 1280`, true},
+		{`$.sizes > 2000`, false},
+		{`$.items[*].id == 2`, true},
+		{`$.name starts with "resize"`, true},
+		{`$.name starts with "crop"`, false},
+		{`$.tags starts with "ba"`, true},
+		{`exists ($.meta.nested)`, true},
+		{`exists ($.meta.other)`, false},
+		{`!(exists ($.meta.other))`, true},
+		{`$.width > 100 && $.format == "png"`, true},
+		{`$.width > 100 && $.format == "jpg"`, false},
+		{`$.width > 1000 || $.format == "png"`, true},
+		{`$.width > 1000 || $.format == "jpg"`, false},
+		{`!($.width > 1000)`, true},
+		// comparing mismatched types is unknown, which neither matches
+		// nor turns true under !
+		{`$.format > 1`, false},
+		{`!($.format > 1)`, false},
+		{`$.format > 1 || $.width == 640`, true},
+		{`$.format > 1 && $.width == 640`, false},
+		{`$.meta == 1`, false},
+		{`$.width starts with "6"`, false},
+		{`!($.width starts with "6")`, false},
+		// a missing path compares false, not unknown
+		{`$.missing == 1`, false},
+		{`!($.missing == 1)`, true},
+		{`$.format == "png\u0021"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := e.Match(json.RawMessage(doc)); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchStringEscapes(t *testing.T) {
+	doc := json.RawMessage(`{"path": "C:\\tmp\\a.png", "quote": "say \"hi\"", "unicode": "é✓", "line": "a\nb"}`)
+	tests := []string{
+		`$.path == "C:\\tmp\\a.png"`,
+		`$.quote == "say \"hi\""`,
+		`$.unicode == "\u00e9\u2713"`,
+		`$.unicode starts with "é"`,
+		`$.line == "a\nb"`,
+	}
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			e, err := Compile(src)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if !e.Match(doc) {
+				t.Error("no match")
+			}
+		})
+	}
+}
+
+func TestMatchNotJSON(t *testing.T) {
+	for _, src := range []string{`$`, `$.a == 1`, `!($.a == 1)`} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", src, err)
+		}
+		for _, doc := range []string{``, `{"a":`, `not json`} {
+			if e.Match(json.RawMessage(doc)) {
+				t.Errorf("%q matched %q", src, doc)
+			}
+		}
+	}
+}
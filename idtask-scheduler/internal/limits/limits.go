@@ -0,0 +1,195 @@
+// Package limits enforces per task type rate limits and concurrency caps
+// with counters in Redis, so every scheduler shares them. The scheduler
+// acquires a slot at dispatch; workers release concurrency slots once the
+// task finishes.
+package limits
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// Limit caps how fast and how many tasks of one type are dispatched.
+// Zero Rate or Concurrency means no limit of that kind.
+type Limit struct {
+	// Rate is tasks per second, Burst how many may go at once after idling.
+	Rate  float64
+	Burst int
+	// Concurrency caps the tasks dispatched but not yet finished.
+	Concurrency int
+	// Per is "" for one limit shared by the type, "key" for a separate
+	// limit per Task.Key or "tenant" for a separate limit per tenant.
+	Per string
+}
+
+// Decision is the outcome of Acquire.
+type Decision struct {
+	Allowed bool
+	// Reason is "rate" or "concurrency" when not allowed.
+	Reason string
+	// RetryAfter estimates when the task may pass, for rate limits.
+	RetryAfter time.Duration
+}
+
+// Parse reads limits as "type:rate=5,burst=10,concurrency=3,per=key"
+// entries separated by ";", per is key or tenant. Burst defaults to the rate rounded up.
+func Parse(spec string) (map[string]Limit, error) {
+	out := make(map[string]Limit)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		taskType, opts, ok := strings.Cut(entry, ":")
+		taskType = strings.TrimSpace(taskType)
+		if !ok || taskType == "" {
+			return nil, fmt.Errorf("invalid limit %q, want type:option=value,...", entry)
+		}
+
+		var l Limit
+		for _, opt := range strings.Split(opts, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			var err error
+			switch k {
+			case "rate":
+				l.Rate, err = strconv.ParseFloat(v, 64)
+			case "burst":
+				l.Burst, err = strconv.Atoi(v)
+			case "concurrency":
+				l.Concurrency, err = strconv.Atoi(v)
+			case "per":
+				if v != "key" && v != "tenant" {
+					err = fmt.Errorf("unknown scope %q", v)
+				}
+				l.Per = v
+			default:
+				err = fmt.Errorf("unknown option %q", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid limit for %s: %w", taskType, err)
+			}
+		}
+		if l.Rate > 0 && l.Burst <= 0 {
+			l.Burst = int(math.Ceil(l.Rate))
+		}
+		out[taskType] = l
+	}
+	return out, nil
+}
+
+// acquireScript takes a concurrency slot and a rate token for one task, or
+// neither. KEYS: token bucket hash, running set. ARGV: now (ms), rate,
+// burst, concurrency, task ID, lease (ms). Returns {0} when allowed,
+// {1, retry after ms} when rate limited and {2} at the concurrency cap.
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local conc = tonumber(ARGV[4])
+
+if conc > 0 then
+	redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+	if redis.call('ZSCORE', KEYS[2], ARGV[5]) == false and redis.call('ZCARD', KEYS[2]) >= conc then
+		return {2, 0}
+	end
+end
+
+if rate > 0 then
+	local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+	local tokens = tonumber(b[1]) or burst
+	local ts = tonumber(b[2]) or now
+	tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+	if tokens < 1 then
+		redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+		return {1, math.ceil((1 - tokens) * 1000 / rate)}
+	end
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens - 1), 'ts', now)
+	redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+end
+
+if conc > 0 then
+	redis.call('ZADD', KEYS[2], now + tonumber(ARGV[6]), ARGV[5])
+end
+return {0, 0}
+`)
+
+// Limiter applies the configured limits.
+type Limiter struct {
+	rdb    *redis.Client
+	limits map[string]Limit
+	lease  time.Duration
+}
+
+// NewLimiter parses spec, see Parse. Concurrency slots a worker never
+// releases expire after lease.
+func NewLimiter(rdb *redis.Client, spec string, lease time.Duration) (*Limiter, error) {
+	limits, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{rdb: rdb, limits: limits, lease: lease}, nil
+}
+
+// For returns the limit on the task's type, if any.
+func (l *Limiter) For(t *models.Task) (Limit, bool) {
+	if l == nil {
+		return Limit{}, false
+	}
+	lim, ok := l.limits[t.Type]
+	return lim, ok
+}
+
+// Scope names the counters a task is limited by: its type, plus its key
+// for per-key limits or its tenant for per-tenant limits.
+func Scope(lim Limit, t *models.Task) string {
+	switch lim.Per {
+	case "key":
+		return t.Type + ":" + t.Key
+	case "tenant":
+		// '@' cannot appear in a tenant name, per-key scopes use ':'
+		return t.Type + "@" + models.TenantOf(t)
+	}
+	return t.Type
+}
+
+// Acquire lets the task through if its scope has a rate token and a free
+// concurrency slot, taking both. The slot is held under RunningKey(scope)
+// until Release.
+func (l *Limiter) Acquire(ctx context.Context, lim Limit, scope string, taskID string) (Decision, error) {
+	keys := []string{"limit:rate:" + scope, RunningKey(scope)}
+	res, err := acquireScript.Run(ctx, l.rdb, keys,
+		time.Now().UnixMilli(), lim.Rate, lim.Burst, lim.Concurrency, taskID, l.lease.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	switch res[0] {
+	case 1:
+		return Decision{Reason: "rate", RetryAfter: time.Duration(res[1]) * time.Millisecond}, nil
+	case 2:
+		return Decision{Reason: "concurrency"}, nil
+	}
+	return Decision{Allowed: true}, nil
+}
+
+// RunningKey is the Redis sorted set of the tasks holding a concurrency
+// slot in scope, scored by lease expiry.
+func RunningKey(scope string) string {
+	return "limit:running:" + scope
+}
+
+// Release frees the concurrency slot the task holds, if any. Workers call
+// it once the task finished, whatever the outcome.
+func Release(ctx context.Context, rdb *redis.Client, t *models.Task) error {
+	if t.LimitKey == "" {
+		return nil
+	}
+	return rdb.ZRem(ctx, t.LimitKey, t.ID).Err()
+}
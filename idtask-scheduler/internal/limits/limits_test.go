@@ -0,0 +1,70 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]Limit
+		wantErr bool
+	}{
+		{spec: "", want: map[string]Limit{}},
+		{spec: "email:rate=10,burst=20", want: map[string]Limit{"email": {Rate: 10, Burst: 20}}},
+		{spec: "email:rate=2.5", want: map[string]Limit{"email": {Rate: 2.5, Burst: 3}}},
+		{spec: " ocr:concurrency=2,per=key ; email:rate=1 ;", want: map[string]Limit{
+			"ocr":   {Concurrency: 2, Per: "key"},
+			"email": {Rate: 1, Burst: 1},
+		}},
+		{spec: "sms:rate=5,per=tenant", want: map[string]Limit{"sms": {Rate: 5, Burst: 5, Per: "tenant"}}},
+		{spec: "email", wantErr: true},
+		{spec: ":rate=1", wantErr: true},
+		{spec: "email:rate=fast", wantErr: true},
+		{spec: "email:per=region", wantErr: true},
+		{spec: "email:speed=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for taskType, lim := range tt.want {
+				if got[taskType] != lim {
+					t.Errorf("%s: got %+v, want %+v", taskType, got[taskType], lim)
+				}
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	task := &models.Task{Type: "sms", Key: "customer-1", Tenant: "acme"}
+	tests := []struct {
+		per  string
+		task *models.Task
+		want string
+	}{
+		{"", task, "sms"},
+		{"key", task, "sms:customer-1"},
+		{"tenant", task, "sms@acme"},
+		{"tenant", &models.Task{Type: "sms"}, "sms@" + models.DefaultTenant},
+	}
+	for _, tt := range tests {
+		if got := Scope(Limit{Per: tt.per}, tt.task); got != tt.want {
+			t.Errorf("per=%q: got %q, want %q", tt.per, got, tt.want)
+		}
+	}
+}
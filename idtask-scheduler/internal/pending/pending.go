@@ -0,0 +1,64 @@
+// Package pending tracks each tenant's unfinished tasks in Redis for the
+// queued quota of TENANT_QUOTAS. The API adds a task when it admits it and
+// whoever finishes the task removes it, wherever it waits in between:
+// delayed, throttled, being dispatched or queued for a worker.
+package pending
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// key is a sorted set of the tenant's unfinished task IDs, scored by their
+// expire_at. A task whose release got lost stops counting once it expires.
+func key(tenant string) string {
+	return "pending-tasks:" + tenant
+}
+
+// Admit counts t against its tenant. A task without an expire_at counts
+// until a day after it is due.
+func Admit(ctx context.Context, rdb *redis.Client, t *models.Task) error {
+	pipe := rdb.TxPipeline()
+	pipe.ZAdd(ctx, key(models.TenantOf(t)), &redis.Z{Score: float64(deadline(t).Unix()), Member: t.ID})
+	prune(ctx, pipe, models.TenantOf(t))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Count returns how many of the tenant's tasks are not finished yet.
+func Count(ctx context.Context, rdb *redis.Client, tenant string) (int64, error) {
+	pipe := rdb.TxPipeline()
+	prune(ctx, pipe, tenant)
+	count := pipe.ZCard(ctx, key(tenant))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// Release stops counting t once it finished, was cancelled or expired.
+func Release(ctx context.Context, rdb *redis.Client, t *models.Task) {
+	if err := rdb.ZRem(ctx, key(models.TenantOf(t)), t.ID).Err(); err != nil {
+		log.Printf("⚠️ Failed to stop counting task %s as pending: %v", t.ID, err)
+	}
+}
+
+func deadline(t *models.Task) time.Time {
+	if t.ExpireAt != nil {
+		return *t.ExpireAt
+	}
+	due := time.Now()
+	if t.ScheduledAt != nil && t.ScheduledAt.After(due) {
+		due = *t.ScheduledAt
+	}
+	return due.Add(24 * time.Hour)
+}
+
+func prune(ctx context.Context, pipe redis.Pipeliner, tenant string) {
+	pipe.ZRemRangeByScore(ctx, key(tenant), "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+}
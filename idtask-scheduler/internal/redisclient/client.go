@@ -30,6 +30,11 @@ func Init() {
 	})
 }
 
+// Use installs an already connected client, e.g. one backed by an in-process Redis.
+func Use(c *redis.Client) {
+	client = c
+}
+
 // GetClient returns the initialized Redis client.
 func GetClient() *redis.Client {
 	if client == nil {
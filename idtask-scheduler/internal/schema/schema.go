@@ -0,0 +1,397 @@
+// Package schema validates task payloads against JSON Schemas. It covers
+// the keywords payload schemas need: type, properties, required,
+// additionalProperties, items, enum, const, the numeric, string length
+// and array length bounds, pattern, and allOf, anyOf and oneOf. Schemas
+// using any other keyword are rejected when compiled rather than half
+// enforced; annotations like title, description or format are ignored.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// annotations are keywords that do not constrain a document.
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "format": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// typeNames maps the type keyword's names to how violations name them.
+var typeNames = map[string]string{
+	"null": "null", "boolean": "a boolean", "object": "an object", "array": "an array",
+	"number": "a number", "integer": "an integer", "string": "a string",
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	// never is the false schema, nothing is valid against it.
+	never bool
+
+	types      []string
+	properties map[string]*Schema
+	required   []string
+	// additional applies to properties not in properties, nil allows any.
+	additional *Schema
+	items      *Schema
+	enum       []any
+	constant   *any
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	minLength, maxLength               *int
+	minItems, maxItems                 *int
+	pattern                            *regexp.Regexp
+
+	allOf, anyOf, oneOf []*Schema
+}
+
+// Violation is one way a document fails a schema, at the field path,
+// e.g. "payload.items[2].name".
+type Violation struct {
+	Path    string
+	Message string
+}
+
+// Compile parses a JSON Schema, failing on malformed or unsupported ones.
+func Compile(raw json.RawMessage) (*Schema, error) {
+	return compile(raw, "")
+}
+
+func compile(raw json.RawMessage, at string) (*Schema, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return &Schema{never: !b}, nil
+	}
+	var kw map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &kw); err != nil {
+		return nil, fmt.Errorf("%s: a schema must be an object or a boolean", where(at))
+	}
+
+	s := &Schema{}
+	keys := make([]string, 0, len(kw))
+	for k := range kw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := s.keyword(k, kw[k], at); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) keyword(k string, v json.RawMessage, at string) error {
+	bad := func(want string) error {
+		return fmt.Errorf("%s: %s must be %s", where(at), k, want)
+	}
+	var err error
+	switch k {
+	case "type":
+		var one string
+		if json.Unmarshal(v, &one) == nil {
+			s.types = []string{one}
+		} else if json.Unmarshal(v, &s.types) != nil {
+			return bad("a type name or a list of them")
+		}
+		for _, t := range s.types {
+			if _, ok := typeNames[t]; !ok {
+				return fmt.Errorf("%s: unknown type %q", where(at), t)
+			}
+		}
+	case "properties":
+		var props map[string]json.RawMessage
+		if json.Unmarshal(v, &props) != nil {
+			return bad("an object")
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, p := range props {
+			if s.properties[name], err = compile(p, at+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	case "required":
+		if json.Unmarshal(v, &s.required) != nil {
+			return bad("a list of property names")
+		}
+	case "additionalProperties":
+		s.additional, err = compile(v, at+"/additionalProperties")
+	case "items":
+		s.items, err = compile(v, at+"/items")
+	case "enum":
+		if json.Unmarshal(v, &s.enum) != nil || len(s.enum) == 0 {
+			return bad("a non-empty list")
+		}
+	case "const":
+		var c any
+		if json.Unmarshal(v, &c) != nil {
+			return bad("a JSON value")
+		}
+		s.constant = &c
+	case "minimum":
+		s.minimum, err = number(v, bad)
+	case "maximum":
+		s.maximum, err = number(v, bad)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = number(v, bad)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = number(v, bad)
+	case "minLength":
+		s.minLength, err = count(v, bad)
+	case "maxLength":
+		s.maxLength, err = count(v, bad)
+	case "minItems":
+		s.minItems, err = count(v, bad)
+	case "maxItems":
+		s.maxItems, err = count(v, bad)
+	case "pattern":
+		var p *string
+		if json.Unmarshal(v, &p) != nil || p == nil {
+			return bad("a regular expression")
+		}
+		if s.pattern, err = regexp.Compile(*p); err != nil {
+			return fmt.Errorf("%s: pattern: %v", where(at), err)
+		}
+	case "allOf":
+		s.allOf, err = compileAll(v, at+"/allOf", bad)
+	case "anyOf":
+		s.anyOf, err = compileAll(v, at+"/anyOf", bad)
+	case "oneOf":
+		s.oneOf, err = compileAll(v, at+"/oneOf", bad)
+	default:
+		if !annotations[k] {
+			return fmt.Errorf("%s: unsupported keyword %q", where(at), k)
+		}
+	}
+	return err
+}
+
+// number and count decode into pointers, a null leaves them nil rather
+// than zero.
+func number(v json.RawMessage, bad func(string) error) (*float64, error) {
+	var n *float64
+	if json.Unmarshal(v, &n) != nil || n == nil {
+		return nil, bad("a number")
+	}
+	return n, nil
+}
+
+func count(v json.RawMessage, bad func(string) error) (*int, error) {
+	var n *int
+	if json.Unmarshal(v, &n) != nil || n == nil || *n < 0 {
+		return nil, bad("a non-negative integer")
+	}
+	return n, nil
+}
+
+func compileAll(v json.RawMessage, at string, bad func(string) error) ([]*Schema, error) {
+	var list []json.RawMessage
+	if json.Unmarshal(v, &list) != nil || len(list) == 0 {
+		return nil, bad("a non-empty list of schemas")
+	}
+	subs := make([]*Schema, len(list))
+	for i, sub := range list {
+		var err error
+		if subs[i], err = compile(sub, at+"/"+strconv.Itoa(i)); err != nil {
+			return nil, err
+		}
+	}
+	return subs, nil
+}
+
+func where(at string) string {
+	if at == "" {
+		return "schema"
+	}
+	return "schema at " + at
+}
+
+// Validate checks a JSON document against the schema, reporting violations
+// under path, the name of the document itself.
+func (s *Schema) Validate(doc json.RawMessage, path string) []Violation {
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return []Violation{{Path: path, Message: "must be valid JSON"}}
+	}
+	return s.validate(v, path)
+}
+
+func (s *Schema) validate(v any, path string) []Violation {
+	if s.never {
+		return []Violation{{Path: path, Message: "is not allowed"}}
+	}
+	var out []Violation
+	add := func(format string, args ...any) {
+		out = append(out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !s.typeMatches(v) {
+		names := make([]string, len(s.types))
+		for i, t := range s.types {
+			names[i] = typeNames[t]
+		}
+		add("must be %s", strings.Join(names, " or "))
+		return out
+	}
+	if s.enum != nil && !contains(s.enum, v) {
+		add("must be one of %s", list(s.enum))
+	}
+	if s.constant != nil && !reflect.DeepEqual(*s.constant, v) {
+		add("must be %s", list([]any{*s.constant}))
+	}
+
+	switch v := v.(type) {
+	case float64:
+		switch {
+		case s.minimum != nil && v < *s.minimum:
+			add("must be at least %v", *s.minimum)
+		case s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum:
+			add("must be greater than %v", *s.exclusiveMinimum)
+		}
+		switch {
+		case s.maximum != nil && v > *s.maximum:
+			add("must be at most %v", *s.maximum)
+		case s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum:
+			add("must be less than %v", *s.exclusiveMaximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			add("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			add("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("must match %s", s.pattern)
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			add("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			add("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				out = append(out, s.items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]any:
+		out = append(out, s.validateObject(v, path)...)
+	}
+
+	for _, sub := range s.allOf {
+		out = append(out, sub.validate(v, path)...)
+	}
+	if s.anyOf != nil && s.matching(s.anyOf, v) == 0 {
+		add("must match at least one of the allowed schemas")
+	}
+	if s.oneOf != nil && s.matching(s.oneOf, v) != 1 {
+		add("must match exactly one of the allowed schemas")
+	}
+	return out
+}
+
+func (s *Schema) validateObject(obj map[string]any, path string) []Violation {
+	var out []Violation
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			out = append(out, Violation{Path: join(path, name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if p, ok := s.properties[name]; ok {
+			out = append(out, p.validate(obj[name], join(path, name))...)
+		} else if s.additional != nil {
+			if s.additional.never {
+				out = append(out, Violation{Path: join(path, name), Message: "is not a known field"})
+				continue
+			}
+			out = append(out, s.additional.validate(obj[name], join(path, name))...)
+		}
+	}
+	return out
+}
+
+// matching counts the schemas v is valid against.
+func (s *Schema) matching(schemas []*Schema, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.validate(v, "")) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Schema) typeMatches(v any) bool {
+	for _, t := range s.types {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []any, v any) bool {
+	for _, e := range values {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func list(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{"not a schema", `"object"`, "schema: a schema must be an object or a boolean"},
+		{"malformed", `{"type":`, "a schema must be an object or a boolean"},
+		{"unknown type", `{"type":"picture"}`, `schema: unknown type "picture"`},
+		{"type not a name", `{"type":7}`, "type must be a type name or a list of them"},
+		{"unknown type in list", `{"type":["string","date"]}`, `unknown type "date"`},
+		{"properties not an object", `{"properties":[]}`, "properties must be an object"},
+		{"bad property", `{"properties":{"a":{"type":"x"}}}`, `schema at /properties/a: unknown type "x"`},
+		{"required not a list", `{"required":"a"}`, "required must be a list of property names"},
+		{"bad additionalProperties", `{"additionalProperties":1}`, "schema at /additionalProperties: a schema must be"},
+		{"bad items", `{"items":{"minItems":"2"}}`, "schema at /items: minItems must be a non-negative integer"},
+		{"empty enum", `{"enum":[]}`, "enum must be a non-empty list"},
+		{"enum not a list", `{"enum":"a"}`, "enum must be a non-empty list"},
+		{"minimum not a number", `{"minimum":"1"}`, "minimum must be a number"},
+		{"maximum not a number", `{"maximum":true}`, "maximum must be a number"},
+		{"exclusiveMinimum not a number", `{"exclusiveMinimum":null}`, "exclusiveMinimum must be a number"},
+		{"exclusiveMaximum not a number", `{"exclusiveMaximum":[]}`, "exclusiveMaximum must be a number"},
+		{"null minLength", `{"minLength":null}`, "minLength must be a non-negative integer"},
+		{"negative minLength", `{"minLength":-1}`, "minLength must be a non-negative integer"},
+		{"fractional maxLength", `{"maxLength":1.5}`, "maxLength must be a non-negative integer"},
+		{"negative maxItems", `{"maxItems":-3}`, "maxItems must be a non-negative integer"},
+		{"pattern not a string", `{"pattern":1}`, "pattern must be a regular expression"},
+		{"null pattern", `{"pattern":null}`, "pattern must be a regular expression"},
+		{"invalid pattern", `{"pattern":"("}`, "schema: pattern: error parsing regexp"},
+		{"empty allOf", `{"allOf":[]}`, "allOf must be a non-empty list of schemas"},
+		{"anyOf not a list", `{"anyOf":{}}`, "anyOf must be a non-empty list of schemas"},
+		{"bad oneOf branch", `{"oneOf":[true,{"type":"x"}]}`, `schema at /oneOf/1: unknown type "x"`},
+		{"unsupported keyword", `{"$ref":"#/defs/a"}`, `schema: unsupported keyword "$ref"`},
+		{"nested unsupported keyword", `{"properties":{"a":{"if":{}}}}`, `schema at /properties/a: unsupported keyword "if"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(json.RawMessage(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Compile(%s) = %v, want %q", tt.schema, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCompileAnnotations(t *testing.T) {
+	s, err := Compile(json.RawMessage(`{"$schema":"https://json-schema.org/draft/2020-12/schema",
+		"title":"t","description":"d","format":"email","default":"x","examples":["a"],"type":"string"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Validate(json.RawMessage(`"not an email"`), "payload"); v != nil {
+		t.Errorf("format was enforced: %v", v)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		// want lists the violations as "path: message", none if valid
+		want []string
+	}{
+		{"true", `true`, `{"a":1}`, nil},
+		{"false", `false`, `1`, []string{"payload: is not allowed"}},
+		{"empty schema", `{}`, `[1,"a",null]`, nil},
+		{"invalid JSON", `{}`, `{"a":`, []string{"payload: must be valid JSON"}},
+
+		{"type string", `{"type":"string"}`, `"a"`, nil},
+		{"type mismatch", `{"type":"string"}`, `1`, []string{"payload: must be a string"}},
+		{"type list", `{"type":["string","null"]}`, `null`, nil},
+		{"type list mismatch", `{"type":["string","null"]}`, `true`, []string{"payload: must be a string or null"}},
+		{"integer", `{"type":"integer"}`, `3.0`, nil},
+		{"integer fraction", `{"type":"integer"}`, `3.5`, []string{"payload: must be an integer"}},
+		{"number", `{"type":"number"}`, `3.5`, nil},
+		{"boolean", `{"type":"boolean"}`, `"true"`, []string{"payload: must be a boolean"}},
+		{"object", `{"type":"object"}`, `[]`, []string{"payload: must be an object"}},
+		{"array", `{"type":"array"}`, `{}`, []string{"payload: must be an array"}},
+		{"type stops other checks", `{"type":"string","minimum":5}`, `1`, []string{"payload: must be a string"}},
+
+		{"required", `{"required":["a","b"]}`, `{"a":1}`, []string{"payload.b: is required"}},
+		{"required ignores non objects", `{"required":["a"]}`, `"a"`, nil},
+		{"properties", `{"properties":{"a":{"type":"string"},"b":{"type":"integer"}}}`, `{"a":1,"b":"x"}`,
+			[]string{"payload.a: must be a string", "payload.b: must be an integer"}},
+		{"nested properties", `{"properties":{"a":{"properties":{"b":{"type":"string"}}}}}`, `{"a":{"b":2}}`,
+			[]string{"payload.a.b: must be a string"}},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"z":2}`,
+			[]string{"payload.z: is not a known field"}},
+		{"additionalProperties schema", `{"additionalProperties":{"type":"integer"}}`, `{"x":1,"y":"2"}`,
+			[]string{"payload.y: must be an integer"}},
+
+		{"items", `{"items":{"type":"integer"}}`, `[1,"a",2,true]`,
+			[]string{"payload[1]: must be an integer", "payload[3]: must be an integer"}},
+		{"items of objects", `{"items":{"required":["id"]}}`, `[{"id":1},{}]`, []string{"payload[1].id: is required"}},
+		{"minItems", `{"minItems":2}`, `[1]`, []string{"payload: must have at least 2 items"}},
+		{"maxItems", `{"maxItems":1}`, `[1,2]`, []string{"payload: must have at most 1 items"}},
+
+		{"enum", `{"enum":["a",1,null]}`, `1`, nil},
+		{"enum mismatch", `{"enum":["a",1]}`, `"b"`, []string{`payload: must be one of "a", 1`}},
+		{"enum object", `{"enum":[{"a":[1]}]}`, `{"a":[1]}`, nil},
+		{"const", `{"const":"x"}`, `"x"`, nil},
+		{"const mismatch", `{"const":{"a":1}}`, `{"a":2}`, []string{`payload: must be {"a":1}`}},
+
+		{"minimum", `{"minimum":1}`, `0.5`, []string{"payload: must be at least 1"}},
+		{"minimum inclusive", `{"minimum":1}`, `1`, nil},
+		{"maximum", `{"maximum":10}`, `11`, []string{"payload: must be at most 10"}},
+		{"exclusiveMinimum", `{"exclusiveMinimum":1}`, `1`, []string{"payload: must be greater than 1"}},
+		{"exclusiveMaximum", `{"exclusiveMaximum":1}`, `1`, []string{"payload: must be less than 1"}},
+		{"both bounds", `{"minimum":0,"maximum":1}`, `0.5`, nil},
+		{"bounds ignore strings", `{"minimum":5}`, `"1"`, nil},
+
+		{"minLength", `{"minLength":3}`, `"ab"`, []string{"payload: must be at least 3 characters"}},
+		{"minLength counts runes", `{"minLength":2,"maxLength":2}`, `"é✓"`, nil},
+		{"maxLength", `{"maxLength":2}`, `"abc"`, []string{"payload: must be at most 2 characters"}},
+		{"pattern", `{"pattern":"^[a-z]+$"}`, `"abc"`, nil},
+		{"pattern mismatch", `{"pattern":"^[a-z]+$"}`, `"ab1"`, []string{"payload: must match ^[a-z]+$"}},
+		{"pattern unanchored", `{"pattern":"b"}`, `"abc"`, nil},
+
+		{"allOf", `{"allOf":[{"minimum":1},{"maximum":5}]}`, `7`, []string{"payload: must be at most 5"}},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `2`, nil},
+		{"anyOf none", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `true`,
+			[]string{"payload: must match at least one of the allowed schemas"}},
+		{"oneOf", `{"oneOf":[{"type":"string"},{"type":"integer"}]}`, `"a"`, nil},
+		{"oneOf several", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `2`,
+			[]string{"payload: must match exactly one of the allowed schemas"}},
+		{"oneOf none", `{"oneOf":[{"type":"string"}]}`, `2`,
+			[]string{"payload: must match exactly one of the allowed schemas"}},
+
+		{"several violations", `{"type":"object","required":["image"],"properties":{"width":{"type":"integer","minimum":1}}}`,
+			`{"width":0}`, []string{"payload.image: is required", "payload.width: must be at least 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile(json.RawMessage(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile(%s): %v", tt.schema, err)
+			}
+			var got []string
+			for _, v := range s.Validate(json.RawMessage(tt.doc), "payload") {
+				got = append(got, v.Path+": "+v.Message)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Validate(%s) = %q, want %q", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package shard partitions tasks between scheduler leaders. Each shard has
+// its own task queue, processing queue and election key. With a single
+// shard the original unsharded names are used, so existing deployments
+// and queued tasks keep working.
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// Count is the configured number of shards, at least 1.
+func Count() int {
+	if configs.Config.SchedulerShards < 1 {
+		return 1
+	}
+	return configs.Config.SchedulerShards
+}
+
+// Of returns the shard a task belongs to, by hash of its type when
+// SCHEDULER_SHARD_BY=type and of its ID otherwise.
+func Of(t *models.Task) int {
+	n := Count()
+	if n == 1 {
+		return 0
+	}
+
+	key := t.ID
+	if configs.Config.SchedulerShardBy == "type" {
+		key = t.Type
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// TaskQueue is the Redis list new tasks of shard n wait in.
+func TaskQueue(n int) string {
+	return name("task-queue", n)
+}
+
+// TenantQueue is the Redis list a tenant's tasks of shard n wait in. The
+// default tenant uses TaskQueue(n), so tasks queued before tenants
+// existed are still scheduled.
+func TenantQueue(n int, tenant string) string {
+	if tenant == "" || tenant == models.DefaultTenant {
+		return TaskQueue(n)
+	}
+	return TaskQueue(n) + ":tenant:" + tenant
+}
+
+// QueueOf is the queue task t is submitted to.
+func QueueOf(t *models.Task) string {
+	return TenantQueue(Of(t), t.Tenant)
+}
+
+// Tenants is the Redis set of tenants, other than the default one, that
+// have submitted tasks to shard n.
+func Tenants(n int) string {
+	return name("tenants", n)
+}
+
+// ProcessingQueue holds tasks of shard n between pop and dispatch.
+func ProcessingQueue(n int) string {
+	return name("processing-queue", n)
+}
+
+// UnschedulableQueue parks tasks of shard n that no registered worker can
+// run, until a new worker joins.
+func UnschedulableQueue(n int) string {
+	return name("unschedulable-tasks", n)
+}
+
+// LeaderKey is the etcd election prefix of shard n.
+func LeaderKey(n int) string {
+	return name("/scheduler/leader", n)
+}
+
+// ThrottledQueue holds shard n's tasks of a tenant's limit scope, given as
+// "tenant/scope", that wait for the limit to allow them, oldest at the
+// right like the task queue.
+func ThrottledQueue(n int, member string) string {
+	return name("throttled-tasks", n) + ":" + member
+}
+
+// ThrottledScopes is the sorted set of shard n's "tenant/scope" members
+// with throttled tasks, scored by the unix millisecond of the next retry.
+func ThrottledScopes(n int) string {
+	return name("throttled-scopes", n)
+}
+
+// FenceKey holds the highest leader fencing token seen for shard n; Redis
+// writes carrying a lower one are rejected.
+func FenceKey(n int) string {
+	return name("scheduler-fence", n)
+}
+
+func name(base string, n int) string {
+	if Count() == 1 {
+		return base
+	}
+	return fmt.Sprintf("%s:%d", base, n)
+}
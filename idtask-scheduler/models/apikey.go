@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// APIKey is a static credential for the API. Only a hash of the key is
+// stored, the key itself is shown once when it is created or rotated.
+type APIKey struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	// Subject is the principal requests made with the key act as.
+	Subject string `db:"subject" json:"subject"`
+	// Roles are granted to the key's requests, as "role@tenant".
+	Roles     Roles      `db:"roles" json:"roles,omitempty"`
+	Hash      string     `db:"key_hash" json:"-"`
+	CreatedBy string     `db:"created_by" json:"created_by"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+
+	// Key is the plain key, only set in the response that issues it.
+	Key string `db:"-" json:"key,omitempty"`
+}
+
+// APIKeyRequest asks for a new API key.
+type APIKeyRequest struct {
+	Name string `json:"name"`
+	// Subject defaults to "key:<name>".
+	Subject string `json:"subject,omitempty"`
+	Roles   Roles  `json:"roles,omitempty"`
+}
+
+// Roles lists role grants. Stored as JSONB.
+type Roles []string
+
+func (r Roles) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r)
+}
+
+func (r *Roles) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Roles", src)
+	}
+	return json.Unmarshal(data, r)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent is one entry of the append-only audit log: who did what to
+// which target, with which parameters and how it ended.
+type AuditEvent struct {
+	ID int64 `db:"id" json:"id"`
+	// Principal is the subject of the caller, or "scheduler:<instance>"
+	// for actions a scheduler leader took on its own.
+	Principal string `db:"principal" json:"principal"`
+	// Action is what was attempted, e.g. "task.submit" or "worker.remove".
+	Action string `db:"action" json:"action"`
+	// Target is the task, key or worker acted on, if any.
+	Target string          `db:"target" json:"target,omitempty"`
+	Params json.RawMessage `db:"params" json:"params,omitempty"`
+	// Outcome is "success", "denied" or "failure", Status the HTTP status
+	// of API calls.
+	Outcome string     `db:"outcome" json:"outcome"`
+	Status  int        `db:"status" json:"status,omitempty"`
+	At      *time.Time `db:"at" json:"at"`
+}
+
+// AuditQuery filters the audit log, empty fields match everything.
+type AuditQuery struct {
+	Principal string     `json:"principal,omitempty"`
+	Action    string     `json:"action,omitempty"`
+	Target    string     `json:"target,omitempty"`
+	Outcome   string     `json:"outcome,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+}
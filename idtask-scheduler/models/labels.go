@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Labels describe a worker (region, hardware class, installed tools) or
+// what a task needs from one. Stored as JSONB.
+type Labels map[string]string
+
+// ParseLabels reads "key=value" pairs, comma separated, e.g.
+// "region=eu-west,hardware=gpu,ffmpeg=installed".
+func ParseLabels(spec string) Labels {
+	labels := Labels{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return labels
+}
+
+// Satisfies reports whether l has every label in required with the same value.
+func (l Labels) Satisfies(required Labels) bool {
+	for k, v := range required {
+		if got, ok := l[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Score counts the preferred labels l matches.
+func (l Labels) Score(preferred Labels) int {
+	score := 0
+	for k, v := range preferred {
+		if got, ok := l[k]; ok && got == v {
+			score++
+		}
+	}
+	return score
+}
+
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *Labels) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Labels", src)
+	}
+	return json.Unmarshal(data, l)
+}
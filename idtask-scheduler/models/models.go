@@ -2,22 +2,94 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
 type Task struct {
-	ID          string         `db:"id" json:"id"`
-	Type        string         `db:"type" json:"type"`
-	Payload     string         `db:"payload" json:"payload"`
-	Retries     sql.NullInt64  `db:"retries" json:"retries"`
-	Status      string         `db:"status" json:"status"`
-	MaxRetry    sql.NullInt64  `db:"max_retry" json:"max_retry"`
-	CreatedAt   *time.Time     `db:"created_at" json:"created_at"`
-	ExpireAt    *time.Time     `db:"expire_at" json:"expire_at"`
-	Priority    sql.NullInt64  `db:"priority" json:"priority"`
-	ExecutedBy  sql.NullString `db:"executed_by" json:"executed_by"`
-	ExecutedAt  *time.Time     `db:"executed_at" json:"executed_at"`
-	ScheduledAt *time.Time     `db:"scheduled_at" json:"scheduled_at"`
+	ID          string          `db:"id" json:"id"`
+	Type        string          `db:"type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Retries     sql.NullInt64   `db:"retries" json:"retries"`
+	Status      string          `db:"status" json:"status"`
+	MaxRetry    sql.NullInt64   `db:"max_retry" json:"max_retry"`
+	CreatedAt   *time.Time      `db:"created_at" json:"created_at"`
+	ExpireAt    *time.Time      `db:"expire_at" json:"expire_at"`
+	Priority    sql.NullInt64   `db:"priority" json:"priority"`
+	ExecutedBy  sql.NullString  `db:"executed_by" json:"executed_by"`
+	ExecutedAt  *time.Time      `db:"executed_at" json:"executed_at"`
+	ScheduledAt *time.Time      `db:"scheduled_at" json:"scheduled_at"`
+	// Key groups related tasks, the consistent-hash strategy keeps tasks
+	// with the same key on the same worker.
+	Key string `db:"task_key" json:"key,omitempty"`
+	// RequiredLabels must all match a worker's labels for it to be chosen,
+	// PreferredLabels only rank the workers that qualify.
+	RequiredLabels  Labels `db:"required_labels" json:"required_labels,omitempty"`
+	PreferredLabels Labels `db:"preferred_labels" json:"preferred_labels,omitempty"`
+	// Tenant owns the task, tenants share the scheduler by fair share.
+	// Empty means DefaultTenant.
+	Tenant string `db:"tenant" json:"tenant,omitempty"`
+	// CreatedBy is the subject of the principal that submitted the task.
+	CreatedBy string `db:"created_by" json:"created_by,omitempty"`
+
+	// Prediction is attached by the scheduler on dispatch so the worker can
+	// report predicted-versus-actual once the task finishes.
+	Prediction *TaskPrediction `db:"-" json:"prediction,omitempty"`
+	// FencingToken is the term of the scheduler leader that dispatched the
+	// task, see scheduler.LeaderElector.Token.
+	FencingToken int64 `db:"-" json:"fencing_token,omitempty"`
+	// LimitKey is the concurrency slot the task holds under TASK_LIMITS,
+	// released by the worker when the task finishes.
+	LimitKey string `db:"-" json:"limit_key,omitempty"`
+	// PayloadRef is the blob store key of a payload too large to queue,
+	// see internal/blob. Only queued tasks carry it, instead of Payload.
+	PayloadRef string `db:"-" json:"payload_ref,omitempty"`
+}
+
+// TaskFinished reports whether a task in status will not change any more:
+// it completed, failed, expired or was cancelled.
+func TaskFinished(status string) bool {
+	switch status {
+	case "Completed", "Failed", "Expired", "Cancelled":
+		return true
+	}
+	return false
+}
+
+type TaskPrediction struct {
+	Priority          int32   `json:"priority"`
+	EstimatedTime     float32 `json:"estimated_time"`
+	RecommendedWorker string  `json:"recommended_worker"`
+	ModelVersion      string  `json:"model_version,omitempty"`
+	WorkerConfidence  float32 `json:"worker_confidence,omitempty"`
+}
+
+// SchedulingDecision records why a task went to a worker, for audit.
+type SchedulingDecision struct {
+	ID                sql.NullInt64 `db:"id" json:"id"`
+	TaskID            string        `db:"task_id" json:"task_id"`
+	Worker            string        `db:"worker" json:"worker"`
+	Reason            string        `db:"reason" json:"reason"`
+	ModelVersion      string        `db:"model_version" json:"model_version"`
+	RecommendedWorker string        `db:"recommended_worker" json:"recommended_worker"`
+	WorkerConfidence  float64       `db:"worker_confidence" json:"worker_confidence"`
+	FencingToken      int64         `db:"fencing_token" json:"fencing_token"`
+	DecidedAt         *time.Time    `db:"decided_at" json:"decided_at"`
+}
+
+// PredictionOutcome pairs a prediction with what actually happened.
+type PredictionOutcome struct {
+	ID                sql.NullInt64 `db:"id" json:"id"`
+	TaskID            string        `db:"task_id" json:"task_id"`
+	TaskType          string        `db:"task_type" json:"task_type"`
+	PredictedPriority int32         `db:"predicted_priority" json:"predicted_priority"`
+	PredictedTime     float64       `db:"predicted_time" json:"predicted_time"`
+	PredictedWorker   string        `db:"predicted_worker" json:"predicted_worker"`
+	ModelVersion      string        `db:"model_version" json:"model_version"`
+	ActualTime        float64       `db:"actual_time" json:"actual_time"`
+	ActualWorker      string        `db:"actual_worker" json:"actual_worker"`
+	Success           bool          `db:"success" json:"success"`
+	CreatedAt         *time.Time    `db:"created_at" json:"created_at"`
 }
 
 type TaskLogs struct {
@@ -25,9 +97,18 @@ type TaskLogs struct {
 	TaskID     string        `db:"task_id" json:"task_id"`
 	ExecutedBy string        `db:"executed_by" json:"executed_by"`
 	Result     string        `db:"result" json:"result"`
+	DurationMs sql.NullInt64 `db:"duration_ms" json:"duration_ms"`
 	ExecutedAt *time.Time    `db:"executed_at" json:"executed_at"`
 }
 
+// TaskTypeStats aggregates past executions of one task type.
+type TaskTypeStats struct {
+	Type               string  `db:"type" json:"type"`
+	AvgDurationSeconds float64 `db:"avg_duration_seconds" json:"avg_duration_seconds"`
+	AvgPriority        float64 `db:"avg_priority" json:"avg_priority"`
+	Samples            int     `db:"samples" json:"samples"`
+}
+
 type AIPredictionResponse struct {
 	Priority          sql.NullInt64 `json:"priority"`
 	EstimatedTime     float64       `json:"estimated_time"`
@@ -44,12 +125,35 @@ type SchedulerStatus struct {
 	Status    string    `json:"status"`
 	IsLeader  string    `json:"isLeader"`
 	HeartBeat time.Time `json:"heart_beat"`
+	// Shards this instance currently leads.
+	Shards []int `json:"shards,omitempty"`
 }
 
 type WorkerStatus struct {
 	ID        string    `json:"id"`
 	Status    string    `json:"status"`
 	HeartBeat time.Time `json:"heart_beat"`
+	Weight    int       `json:"weight,omitempty"`
+	Labels    Labels    `json:"labels,omitempty"`
+	// TaskTypes the worker handles, any type if empty.
+	TaskTypes []string `json:"task_types,omitempty"`
+	// InFlight counts tasks queued for or running on the worker, Capacity
+	// caps it. No capacity means unlimited.
+	InFlight int `json:"in_flight"`
+	Capacity int `json:"capacity,omitempty"`
+}
+
+// Handles reports whether the worker accepts tasks of taskType.
+func (w WorkerStatus) Handles(taskType string) bool {
+	if len(w.TaskTypes) == 0 {
+		return true
+	}
+	for _, t := range w.TaskTypes {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
 }
 
 type APIResponse struct {
@@ -57,6 +161,33 @@ type APIResponse struct {
 	Data   interface{} `json:"data,omitempty"`
 	Total  int         `json:"total"`
 	Error  string      `json:"error,omitempty"`
+	// Code classifies the error, e.g. "unauthenticated" for a 401 and
+	// "forbidden" for a 403.
+	Code string `json:"code,omitempty"`
+	// Details lists the invalid fields of a "validation_failed" error.
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// APIEnvelope is the body of every /v1 response, Data on success and
+// Error otherwise. Total counts every item of a paged list.
+type APIEnvelope struct {
+	Data  interface{} `json:"data,omitempty"`
+	Total *int        `json:"total,omitempty"`
+	Error *APIError   `json:"error,omitempty"`
+}
+
+// APIError describes a failed /v1 request. Code is stable for clients to
+// match on, Message is for people.
+type APIError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError is one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type APIListResponse struct {
@@ -68,4 +199,24 @@ type APIListResponse struct {
 type APIListRequest struct {
 	Page     int `json:"page"`
 	PageSize int `json:"page_size"`
+	// Tenant limits the list to one tenant's tasks, all tenants if empty.
+	Tenant string `json:"tenant,omitempty"`
+	// PayloadFilters are JSON path expressions, see internal/jsonpath,
+	// every one of which a task's payload must match.
+	PayloadFilters []string `json:"payload_filters,omitempty"`
+}
+
+// PredictorPrediction is one predictor's answer for a task. With shadow
+// predictors enabled every task gets one row per predictor, to be joined
+// with prediction_outcomes for offline comparison.
+type PredictorPrediction struct {
+	ID                sql.NullInt64 `db:"id" json:"id"`
+	TaskID            string        `db:"task_id" json:"task_id"`
+	Predictor         string        `db:"predictor" json:"predictor"`
+	Role              string        `db:"role" json:"role"`
+	Priority          int32         `db:"priority" json:"priority"`
+	EstimatedTime     float64       `db:"estimated_time" json:"estimated_time"`
+	RecommendedWorker string        `db:"recommended_worker" json:"recommended_worker"`
+	ModelVersion      string        `db:"model_version" json:"model_version"`
+	CreatedAt         *time.Time    `db:"created_at" json:"created_at"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TaskSchema is a JSON Schema payloads of one task type are validated
+// against. Registering a schema for a type adds a version, submissions
+// are checked against the latest one.
+type TaskSchema struct {
+	Type      string          `db:"type" json:"type"`
+	Version   int             `db:"version" json:"version"`
+	Schema    json.RawMessage `db:"schema" json:"schema"`
+	CreatedBy string          `db:"created_by" json:"created_by"`
+	CreatedAt *time.Time      `db:"created_at" json:"created_at"`
+}
+
+// TaskSchemaRequest registers a new version of a task type's schema.
+type TaskSchemaRequest struct {
+	Type   string          `json:"type"`
+	Schema json.RawMessage `json:"schema"`
+}
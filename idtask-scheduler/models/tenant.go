@@ -0,0 +1,22 @@
+package models
+
+import "regexp"
+
+// DefaultTenant owns tasks submitted without a tenant.
+const DefaultTenant = "default"
+
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+// ValidTenant reports whether name can be used as a tenant: lowercase
+// letters, digits, '.', '_' and '-', up to 63 characters.
+func ValidTenant(name string) bool {
+	return tenantPattern.MatchString(name)
+}
+
+// TenantOf returns the task's tenant, DefaultTenant if it has none.
+func TenantOf(t *Task) string {
+	if t.Tenant == "" {
+		return DefaultTenant
+	}
+	return t.Tenant
+}
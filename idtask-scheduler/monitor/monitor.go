@@ -3,6 +3,7 @@ package monitor
 import (
 	"log"
 	"net/http"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -24,6 +25,28 @@ var (
 		Help:    "Histogram of task execution duration",
 		Buckets: prometheus.DefBuckets,
 	})
+
+	predictionTimeError = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "prediction_time_error_seconds",
+		Help:    "Actual minus predicted execution time, by task type",
+		Buckets: []float64{-10, -5, -2, -1, -0.5, -0.1, 0, 0.1, 0.5, 1, 2, 5, 10},
+	}, []string{"task_type"})
+
+	predictionWorkerMatch = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "prediction_worker_match_total",
+		Help: "Executed tasks by whether they ran on the predicted worker",
+	}, []string{"matched"})
+
+	predictorTimeError = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "predictor_time_error_seconds",
+		Help:    "Actual minus predicted execution time, by primary or shadow predictor",
+		Buckets: []float64{-10, -5, -2, -1, -0.5, -0.1, 0, 0.1, 0.5, 1, 2, 5, 10},
+	}, []string{"predictor"})
+
+	predictorWorkerMatch = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "predictor_worker_match_total",
+		Help: "Executed tasks by predictor and whether they ran on its recommended worker",
+	}, []string{"predictor", "matched"})
 )
 
 // Scheduler metrics
@@ -37,6 +60,78 @@ var (
 		Name: "scheduler_tasks_failed_total",
 		Help: "Total number of failed task scheduling attempts",
 	})
+
+	schedulerTasksUnschedulable = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "scheduler_tasks_unschedulable_total",
+		Help: "Tasks parked because no worker satisfies their type or labels",
+	})
+
+	schedulerTasksHeld = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "scheduler_tasks_held_total",
+		Help: "Tasks put back on the queue because no worker had spare capacity",
+	})
+
+	schedulerStaleWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_stale_writes_total",
+		Help: "Writes rejected because they carried a superseded leader fencing token, by store",
+	}, []string{"store"})
+
+	schedulerTasksThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_tasks_throttled_total",
+		Help: "Tasks held back by a TASK_LIMITS rate limit or concurrency cap, by type and reason",
+	}, []string{"type", "reason"})
+
+	schedulerThrottledScopes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scheduler_throttled_scopes",
+		Help: "Limit scopes with tasks waiting, by shard",
+	}, []string{"shard"})
+
+	schedulerTenantDequeued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_tenant_dequeued_total",
+		Help: "Tasks taken off the tenant queues by fair share, by tenant",
+	}, []string{"tenant"})
+
+	schedulerPredictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_predictions_total",
+		Help: "Total number of predictions by source (ai or fallback)",
+	}, []string{"source"})
+
+	schedulerAIBreakerOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "scheduler_ai_breaker_open",
+		Help: "1 while the AI service circuit breaker is open, 0 otherwise",
+	})
+
+	aiPredictBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ai_predict_batch_size",
+		Help:    "Number of tasks per PredictBatch call",
+		Buckets: []float64{1, 2, 4, 8, 16, 32, 64, 128},
+	})
+
+	aiPredictionCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_prediction_cache_total",
+		Help: "Prediction cache lookups by result (hit or miss)",
+	}, []string{"result"})
+
+	aiPredictionCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ai_prediction_cache_entries",
+		Help: "Number of predictions currently cached",
+	})
+
+	aiPredictorRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_predictor_requests_total",
+		Help: "Predictions requested per predictor, by role (primary or shadow) and result",
+	}, []string{"predictor", "role", "result"})
+
+	aiPredictorLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ai_predictor_latency_seconds",
+		Help:    "Latency of prediction calls per predictor",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"predictor"})
+
+	aiShadowDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_shadow_dropped_total",
+		Help: "Background shadow work dropped while AI_SHADOW_CONCURRENCY jobs were running, by predictor and stage (predict or record)",
+	}, []string{"predictor", "stage"})
 )
 
 var (
@@ -44,6 +139,26 @@ var (
 		Name: "api_requests_total",
 		Help: "Total number of API requests received",
 	})
+
+	apiAdmissionRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_admission_rejected_total",
+		Help: "Task submissions refused by tenant quotas or backpressure, by tenant and reason",
+	}, []string{"tenant", "reason"})
+
+	apiQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "api_queue_depth",
+		Help: "Tasks queued across all shards and tenants, as last seen by backpressure",
+	})
+
+	apiAuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_auth_failures_total",
+		Help: "Requests refused by authentication, by reason (missing, invalid or error)",
+	}, []string{"reason"})
+
+	apiAccessDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_access_denied_total",
+		Help: "Requests refused with 403 by the RBAC policy, by permission",
+	}, []string{"permission"})
 )
 
 // Export accessors
@@ -59,6 +174,22 @@ func WorkerTaskExecDuration() prometheus.Histogram {
 	return workerTaskExecDuration
 }
 
+func PredictionTimeError() *prometheus.HistogramVec {
+	return predictionTimeError
+}
+
+func PredictionWorkerMatch() *prometheus.CounterVec {
+	return predictionWorkerMatch
+}
+
+func PredictorTimeError() *prometheus.HistogramVec {
+	return predictorTimeError
+}
+
+func PredictorWorkerMatch() *prometheus.CounterVec {
+	return predictorWorkerMatch
+}
+
 func SchedulerTasksScheduled() prometheus.Counter {
 	return schedulerTasksScheduled
 }
@@ -67,22 +198,110 @@ func SchedulerTasksFailed() prometheus.Counter {
 	return schedulerTasksFailed
 }
 
-func InitWorkerMetrics() {
-	prometheus.MustRegister(workerTasksExecuted, workerTasksFailed, workerTaskExecDuration)
+func SchedulerTasksUnschedulable() prometheus.Counter {
+	return schedulerTasksUnschedulable
+}
+
+func SchedulerTasksHeld() prometheus.Counter {
+	return schedulerTasksHeld
+}
+
+func SchedulerStaleWrites() *prometheus.CounterVec {
+	return schedulerStaleWrites
+}
+
+func SchedulerTasksThrottled() *prometheus.CounterVec {
+	return schedulerTasksThrottled
+}
+
+func SchedulerThrottledScopes() *prometheus.GaugeVec {
+	return schedulerThrottledScopes
+}
+
+func SchedulerTenantDequeued() *prometheus.CounterVec {
+	return schedulerTenantDequeued
+}
+
+func SchedulerPredictions() *prometheus.CounterVec {
+	return schedulerPredictions
+}
+
+func SchedulerAIBreakerOpen() prometheus.Gauge {
+	return schedulerAIBreakerOpen
+}
+
+func AIPredictBatchSize() prometheus.Histogram {
+	return aiPredictBatchSize
+}
+
+func AIPredictionCache() *prometheus.CounterVec {
+	return aiPredictionCache
+}
+
+func AIPredictionCacheEntries() prometheus.Gauge {
+	return aiPredictionCacheEntries
+}
+
+func AIPredictorRequests() *prometheus.CounterVec {
+	return aiPredictorRequests
+}
+
+func AIPredictorLatency() *prometheus.HistogramVec {
+	return aiPredictorLatency
+}
+
+func AIShadowDropped() *prometheus.CounterVec {
+	return aiShadowDropped
+}
+
+var registerOnce sync.Once
+
+// RegisterAll registers every collector with the default registry without
+// starting a metrics listener. The embedded mode serves them from its own mux.
+func RegisterAll() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(
+			workerTasksExecuted, workerTasksFailed, workerTaskExecDuration,
+			predictionTimeError, predictionWorkerMatch,
+			predictorTimeError, predictorWorkerMatch,
+			schedulerTasksScheduled, schedulerTasksFailed, schedulerTasksUnschedulable, schedulerTasksHeld, schedulerStaleWrites,
+			schedulerTasksThrottled, schedulerThrottledScopes, schedulerTenantDequeued,
+			schedulerPredictions, schedulerAIBreakerOpen, aiPredictBatchSize,
+			aiPredictionCache, aiPredictionCacheEntries,
+			aiPredictorRequests, aiPredictorLatency, aiShadowDropped,
+			apiRequestsTotal, apiAdmissionRejected, apiQueueDepth, apiAuthFailures, apiAccessDenied,
+		)
+	})
+}
+
+// Handler exposes the default registry, for callers that run their own mux.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func InitWorkerMetrics(addr string) {
+	prometheus.MustRegister(workerTasksExecuted, workerTasksFailed, workerTaskExecDuration,
+		predictionTimeError, predictionWorkerMatch,
+		predictorTimeError, predictorWorkerMatch)
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 
 	go func() {
-		log.Println("📈 Prometheus metrics exposed at :8083/metrics")
-		if err := http.ListenAndServe(":8083", nil); err != nil {
-			log.Fatalf("metrics server error: %v", err)
+		log.Printf("📈 Prometheus metrics exposed at %s/metrics", addr)
+		// several workers may share a host, so a taken port is not fatal
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("⚠️ worker metrics server error: %v", err)
 		}
 	}()
 }
 
 func InitSchedulerMetrics() {
-	prometheus.MustRegister(schedulerTasksScheduled, schedulerTasksFailed)
+	prometheus.MustRegister(schedulerTasksScheduled, schedulerTasksFailed, schedulerTasksUnschedulable, schedulerTasksHeld, schedulerStaleWrites,
+		schedulerTasksThrottled, schedulerThrottledScopes, schedulerTenantDequeued,
+		schedulerPredictions, schedulerAIBreakerOpen, aiPredictBatchSize,
+		aiPredictionCache, aiPredictionCacheEntries,
+		aiPredictorRequests, aiPredictorLatency, aiShadowDropped)
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
@@ -96,7 +315,7 @@ func InitSchedulerMetrics() {
 }
 
 func InitApiMetrics() {
-	prometheus.MustRegister(apiRequestsTotal)
+	prometheus.MustRegister(apiRequestsTotal, apiAdmissionRejected, apiQueueDepth, apiAuthFailures, apiAccessDenied)
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
@@ -112,3 +331,19 @@ func InitApiMetrics() {
 func ApiRequestsTotal() prometheus.Counter {
 	return apiRequestsTotal
 }
+
+func ApiAdmissionRejected() *prometheus.CounterVec {
+	return apiAdmissionRejected
+}
+
+func ApiQueueDepth() prometheus.Gauge {
+	return apiQueueDepth
+}
+
+func ApiAuthFailures() *prometheus.CounterVec {
+	return apiAuthFailures
+}
+
+func ApiAccessDenied() *prometheus.CounterVec {
+	return apiAccessDenied
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const schedulerStatusPrefix = "scheduler/status/"
+
+// balanceShards keeps this instance campaigning for its share of the
+// shards until ctx is done. Shard n belongs to the (n mod count)-th of the
+// registered schedulers in sorted order, so the assignment is recomputed
+// whenever an instance joins or leaves. Leader election still decides who
+// runs a shard; the assignment only spreads the campaigns.
+func (s *Scheduler) balanceShards(ctx context.Context) {
+	defer s.stopShards()
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	wch := s.etcd.Watch(ctx, schedulerStatusPrefix, clientv3.WithPrefix())
+	members := ""
+	for {
+		ids, err := s.schedulerIDs(ctx)
+		if err != nil {
+			log.Printf("[shards] Failed to list schedulers: %v", err)
+		} else if joined := strings.Join(ids, ","); joined != members {
+			members = joined
+			s.assignShards(ctx, ids)
+		}
+
+		if !waitForMembers(ctx, wch, ticker.C) {
+			return
+		}
+	}
+}
+
+// waitForMembers blocks until a scheduler joins or leaves, or the periodic
+// recheck is due. It returns false once ctx is done.
+func waitForMembers(ctx context.Context, wch clientv3.WatchChan, recheck <-chan time.Time) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-recheck:
+			return true
+		case resp, ok := <-wch:
+			if !ok {
+				return false
+			}
+			// heartbeats rewrite existing keys, only joins and leaves matter
+			for _, ev := range resp.Events {
+				if ev.IsCreate() || ev.Type == mvccpb.DELETE {
+					return true
+				}
+			}
+		}
+	}
+}
+
+// schedulerIDs lists the registered schedulers, this one included, sorted.
+func (s *Scheduler) schedulerIDs(ctx context.Context) ([]string, error) {
+	resp, err := s.etcd.Get(ctx, schedulerStatusPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	self := strings.TrimPrefix(s.key, schedulerStatusPrefix)
+	ids := []string{self}
+	for _, kv := range resp.Kvs {
+		if id := strings.TrimPrefix(string(kv.Key), schedulerStatusPrefix); id != self {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// assignShards starts campaigns for the shards now assigned to this
+// instance and stops those that moved elsewhere, which releases their
+// leadership to the new owner.
+func (s *Scheduler) assignShards(ctx context.Context, ids []string) {
+	self := strings.TrimPrefix(s.key, schedulerStatusPrefix)
+
+	want := make(map[int]bool)
+	for n := 0; n < shard.Count(); n++ {
+		if ids[n%len(ids)] == self {
+			want[n] = true
+		}
+	}
+
+	for n, r := range s.shards {
+		if !want[n] {
+			log.Printf("[shards] Handing shard %d over", n)
+			r.stop()
+			delete(s.shards, n)
+		}
+	}
+
+	mine := make([]int, 0, len(want))
+	for n := range want {
+		if _, ok := s.shards[n]; !ok {
+			r := newShardRunner(s, n)
+			r.start(ctx)
+			s.shards[n] = r
+		}
+		mine = append(mine, n)
+	}
+	sort.Ints(mine)
+	log.Printf("[shards] %d scheduler(s), campaigning for shards %v of %d", len(ids), mine, shard.Count())
+}
+
+func (s *Scheduler) stopShards() {
+	for n, r := range s.shards {
+		r.stop()
+		delete(s.shards, n)
+	}
+}
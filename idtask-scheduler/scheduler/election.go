@@ -1,4 +1,4 @@
-package main
+package scheduler
 
 import (
 	"context"
@@ -6,19 +6,24 @@ import (
 	"sync"
 	"time"
 
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 type LeaderElector struct {
-	Client     *clientv3.Client
-	Session    *concurrency.Session
-	Election   *concurrency.Election
-	Key        string
-	ID         string
-	isLeader   bool
-	mu         sync.RWMutex
-	OnElected  func()
+	Client   *clientv3.Client
+	Session  *concurrency.Session
+	Election *concurrency.Election
+	Key      string
+	ID       string
+	ttl      time.Duration
+	isLeader bool
+	token    int64
+	mu       sync.RWMutex
+	// OnElected runs once leadership is won. term is cancelled the moment
+	// leadership is lost, before OnResigned runs.
+	OnElected  func(term context.Context)
 	OnResigned func()
 }
 
@@ -45,45 +50,144 @@ func NewLeaderElector(cli *clientv3.Client, electionKey string, id string, ttl t
 		Election: e,
 		Key:      electionKey,
 		ID:       id,
+		ttl:      ttl,
 	}, nil
 }
 
+// renewSession replaces an expired session, and the election bound to it,
+// with fresh ones. A dead session can never win a campaign again.
+func (le *LeaderElector) renewSession() error {
+	le.Session.Close()
+	session, err := concurrency.NewSession(le.Client, concurrency.WithTTL(int(le.ttl.Seconds())))
+	if err != nil {
+		return err
+	}
+	le.Session = session
+	le.Election = concurrency.NewElection(session, le.Key)
+	log.Println("[election] Session renewed")
+	return nil
+}
+
+// Close ends the current session, releasing the leader key if held.
+func (le *LeaderElector) Close() error {
+	return le.Session.Close()
+}
+
 func (le *LeaderElector) CampaignLoop(ctx context.Context) {
 	for {
+		if le.sessionEnded() {
+			if err := le.renewSession(); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Println("[election] Session renewal error:", err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+		}
+
 		log.Println("[election] Starting leader campaign...")
 		err := le.Election.Campaign(ctx, le.ID)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Println("[election] Campaign error:", err)
 			time.Sleep(3 * time.Second)
 			continue
 		}
 
-		le.setLeader(true)
-		log.Println("[election] I am the leader")
+		le.setLeader(true, le.Election.Rev())
+		log.Printf("[election] I am the leader, fencing token %d", le.Token())
 
+		term, lost := context.WithCancel(ctx)
 		if le.OnElected != nil {
-			le.OnElected()
+			le.OnElected(term)
+		}
+
+		le.waitLost(ctx)
+		lost()
+
+		if ctx.Err() != nil && !le.sessionEnded() {
+			// stepping down on purpose, let a follower take over right away
+			rctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := le.Resign(rctx); err != nil {
+				log.Println("[election] Resign error:", err)
+			}
+			cancel()
+		}
+
+		le.setLeader(false, 0)
+		if le.OnResigned != nil {
+			le.OnResigned()
+		}
+
+		if ctx.Err() != nil {
+			return
 		}
+	}
+}
+
+// waitLost blocks until the session ends, the leader key is deleted or
+// ctx is done.
+func (le *LeaderElector) waitLost(ctx context.Context) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wch := le.Client.Watch(wctx, le.Election.Key(), clientv3.WithRev(le.Election.Rev()))
 
-		// wait session expire
+	for {
 		select {
 		case <-le.Session.Done():
 			log.Println("[election] Leadership lost due to session end")
+			return
 		case <-ctx.Done():
 			log.Println("[election] Leadership loop context cancelled")
+			return
+		case resp, ok := <-wch:
+			if !ok {
+				// watch closed (compaction), rely on the session alone
+				wch = nil
+				continue
+			}
+			for _, ev := range resp.Events {
+				if ev.Type == mvccpb.DELETE {
+					log.Println("[election] Leadership lost, leader key deleted")
+					return
+				}
+			}
 		}
+	}
+}
 
-		le.setLeader(false)
-		if le.OnResigned != nil {
-			le.OnResigned()
-		}
+func (le *LeaderElector) sessionEnded() bool {
+	select {
+	case <-le.Session.Done():
+		return true
+	default:
+		return false
 	}
 }
 
-func (le *LeaderElector) setLeader(val bool) {
+// Resign gives up leadership by deleting the leader key, the next
+// campaigner is elected immediately instead of after the session TTL.
+func (le *LeaderElector) Resign(ctx context.Context) error {
+	return le.Election.Resign(ctx)
+}
+
+func (le *LeaderElector) setLeader(val bool, token int64) {
 	le.mu.Lock()
 	defer le.mu.Unlock()
 	le.isLeader = val
+	le.token = token
+}
+
+// Token is the fencing token of the current term: the revision at which
+// this instance's leader key was created. Later leaders always get larger
+// tokens. It is 0 while not leading.
+func (le *LeaderElector) Token() int64 {
+	le.mu.RLock()
+	defer le.mu.RUnlock()
+	return le.token
 }
 
 func (le *LeaderElector) IsLeader() bool {
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestCampaignAfterSessionLoss revokes the leader's lease and expects the
+// elector to win the next election with a fresh session.
+func TestCampaignAfterSessionLoss(t *testing.T) {
+	cli := startEtcd(t)
+	le, err := NewLeaderElector(cli, "/test/leader", "instance-1", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease := le.Session.Lease()
+
+	elected := make(chan int64, 2)
+	le.OnElected = func(context.Context) { elected <- le.Token() }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		le.CampaignLoop(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		le.Close()
+	}()
+
+	var first int64
+	select {
+	case first = <-elected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("not elected")
+	}
+	if _, err := cli.Revoke(context.Background(), lease); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case second := <-elected:
+		if second <= first {
+			t.Errorf("fencing token %d after re-election, want more than %d", second, first)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("not re-elected after the session was lost")
+	}
+}
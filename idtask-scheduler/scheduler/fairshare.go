@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// fairShare decides which tenant queues a batch is taken from, by deficit
+// round-robin. Every round each backlogged tenant earns its share of the
+// round as credit and dequeues one task per whole credit, so over time the
+// tenants get dispatches in proportion to their shares however large their
+// backlogs are.
+type fairShare struct {
+	weights   map[string]float64
+	minShares map[string]float64
+
+	deficit map[string]float64
+	// next is where the following round starts, so a batch filling up
+	// part way through a round does not favour the first tenants
+	next int
+}
+
+func newFairShare(weights, minShares map[string]float64) *fairShare {
+	return &fairShare{
+		weights:   weights,
+		minShares: minShares,
+		deficit:   make(map[string]float64),
+	}
+}
+
+// parseTenantValues reads "tenant=value" pairs, comma separated.
+func parseTenantValues(spec string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	for tenant, v := range models.ParseLabels(spec) {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid value %q for tenant %s", v, tenant)
+		}
+		out[tenant] = f
+	}
+	return out, nil
+}
+
+// shares splits a round between the backlogged tenants by weight, then
+// raises any tenant below its minimum share to that minimum and splits
+// what is left between the others by weight again.
+func (f *fairShare) shares(active []string) map[string]float64 {
+	weight := func(t string) float64 {
+		if w, ok := f.weights[t]; ok {
+			return w
+		}
+		return 1
+	}
+
+	// tenants held at their minimum share
+	pinned := make(map[string]bool)
+	shares := make(map[string]float64, len(active))
+	for {
+		rest, total, free := 1.0, 0.0, 0
+		for _, t := range active {
+			if pinned[t] {
+				rest -= f.minShares[t]
+			} else {
+				total += weight(t)
+				free++
+			}
+		}
+
+		changed := false
+		for _, t := range active {
+			if pinned[t] {
+				shares[t] = f.minShares[t]
+				continue
+			}
+			// if every remaining weight is zero they split the rest evenly
+			share := rest / float64(free)
+			if total > 0 {
+				share = rest * weight(t) / total
+			}
+			if share < f.minShares[t] {
+				pinned[t] = true
+				changed = true
+			}
+			shares[t] = share
+		}
+		if !changed {
+			break
+		}
+	}
+
+	// minimums adding up to more than everything are scaled down
+	sum := 0.0
+	for _, s := range shares {
+		sum += s
+	}
+	if sum > 0 && sum != 1 {
+		for t := range shares {
+			shares[t] /= sum
+		}
+	}
+	return shares
+}
+
+// plan returns the tenant queue to pop for each of up to n tasks, given
+// each tenant's backlog.
+func (f *fairShare) plan(backlog map[string]int64, n int) []string {
+	active := make([]string, 0, len(backlog))
+	for t, queued := range backlog {
+		if queued > 0 {
+			active = append(active, t)
+		} else {
+			delete(f.deficit, t)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	sort.Strings(active)
+
+	shares := f.shares(active)
+	remaining := make(map[string]int64, len(active))
+	for _, t := range active {
+		remaining[t] = backlog[t]
+	}
+
+	out := make([]string, 0, n)
+	for left, progressed := len(active), true; left > 0 && len(out) < n && progressed; {
+		progressed = false
+		start := f.next % len(active)
+		for i := 0; i < len(active) && len(out) < n; i++ {
+			t := active[(start+i)%len(active)]
+			if remaining[t] == 0 {
+				continue
+			}
+
+			// a round hands out one credit per tenant on average
+			f.deficit[t] += shares[t] * float64(len(active))
+			if shares[t] > 0 {
+				progressed = true
+			}
+			for f.deficit[t] >= 1 && remaining[t] > 0 && len(out) < n {
+				out = append(out, t)
+				remaining[t]--
+				f.deficit[t]--
+			}
+			if remaining[t] == 0 {
+				// an emptied queue keeps no credit, as in plain DRR
+				delete(f.deficit, t)
+				left--
+			}
+			f.next = (start + i + 1) % len(active)
+		}
+	}
+	return out
+}
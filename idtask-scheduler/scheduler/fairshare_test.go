@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+)
+
+// dequeued runs rounds of plan against backlogs that never drain and
+// counts the tasks each tenant got.
+func dequeued(f *fairShare, tenants []string, rounds, batch int) map[string]int {
+	backlog := make(map[string]int64, len(tenants))
+	for _, t := range tenants {
+		backlog[t] = 1 << 20
+	}
+	got := make(map[string]int)
+	for i := 0; i < rounds; i++ {
+		for _, t := range f.plan(backlog, batch) {
+			got[t]++
+			backlog[t]--
+		}
+	}
+	return got
+}
+
+func TestFairShareWeights(t *testing.T) {
+	tests := []struct {
+		name      string
+		weights   map[string]float64
+		minShares map[string]float64
+		want      map[string]float64
+	}{
+		{"equal", nil, nil, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}},
+		{"weighted", map[string]float64{"a": 3, "b": 1}, nil, map[string]float64{"a": 0.6, "b": 0.2, "c": 0.2}},
+		{"minimum share", map[string]float64{"a": 8}, map[string]float64{"c": 0.25}, map[string]float64{"a": 0.75 * 8 / 9, "b": 0.75 / 9, "c": 0.25}},
+		{"zero weight", map[string]float64{"a": 0, "b": 0, "c": 0}, nil, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFairShare(tt.weights, tt.minShares)
+			got := dequeued(f, []string{"a", "b", "c"}, 200, 32)
+
+			total := 0
+			for _, n := range got {
+				total += n
+			}
+			if total != 200*32 {
+				t.Fatalf("dequeued %d tasks, want %d", total, 200*32)
+			}
+			for tenant, share := range tt.want {
+				if actual := float64(got[tenant]) / float64(total); math.Abs(actual-share) > 0.01 {
+					t.Errorf("tenant %s got %.3f of the tasks, want %.3f (%v)", tenant, actual, share, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFairSharePlanBacklog(t *testing.T) {
+	f := newFairShare(map[string]float64{"big": 10}, nil)
+
+	// a tenant with little queued gets what it has, the rest fills the batch
+	plan := f.plan(map[string]int64{"big": 100, "small": 2, "idle": 0}, 10)
+	counts := make(map[string]int)
+	for _, t := range plan {
+		counts[t]++
+	}
+	if len(plan) != 10 || counts["small"] > 2 || counts["idle"] != 0 {
+		t.Errorf("plan %v, want 10 tasks with at most 2 from small and none from idle", plan)
+	}
+
+	if plan := f.plan(map[string]int64{"big": 0}, 10); len(plan) != 0 {
+		t.Errorf("plan %v for empty queues", plan)
+	}
+	if _, ok := f.deficit["big"]; ok {
+		t.Error("an empty queue kept its credit")
+	}
+}
+
+func TestParseTenantValues(t *testing.T) {
+	got, err := parseTenantValues("acme=3, globex=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["acme"] != 3 || got["globex"] != 0.5 {
+		t.Errorf("got %v", got)
+	}
+	for _, spec := range []string{"acme=x", "acme=-1"} {
+		if _, err := parseTenantValues(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/go-redis/redis/v8"
+)
+
+// raiseFenceScript stores the token in the fence key unless a newer one is
+// already there, and returns the fence value in effect afterwards.
+var raiseFenceScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[1]) or '0')
+local token = tonumber(ARGV[1])
+if fence > token then
+	return fence
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return token
+`)
+
+// fencedPushScript pushes ARGV[2] onto list KEYS[2] with command ARGV[4]
+// and removes ARGV[3] from the processing queue KEYS[3], unless the fence
+// KEYS[1] holds a token newer than ARGV[1]. It returns 0 when rejected.
+var fencedPushScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[1]) or '0')
+if fence > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call(ARGV[4], KEYS[2], ARGV[2])
+redis.call('LREM', KEYS[3], 1, ARGV[3])
+return 1
+`)
+
+// fencedDelayedScript moves ARGV[2] from the delayed set KEYS[2] to the tail
+// of the task queue KEYS[3] under token ARGV[1]. It returns -1 when the
+// fence KEYS[1] rejects the token, 0 if the task was already moved.
+var fencedDelayedScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[1]) or '0')
+if fence > tonumber(ARGV[1]) then
+	return -1
+end
+if redis.call('ZREM', KEYS[2], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('LPUSH', KEYS[3], ARGV[2])
+return 1
+`)
+
+// fencedRequeueScript moves ARGV[2], the head of the unschedulable queue
+// KEYS[2], to the tail of the task queue KEYS[3] under token ARGV[1]. It
+// returns -1 when the fence KEYS[1] rejects the token, 0 if the head
+// changed in the meantime.
+var fencedRequeueScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[1]) or '0')
+if fence > tonumber(ARGV[1]) then
+	return -1
+end
+if redis.call('LINDEX', KEYS[2], 0) ~= ARGV[2] then
+	return 0
+end
+redis.call('LPOP', KEYS[2])
+redis.call('RPUSH', KEYS[3], ARGV[2])
+return 1
+`)
+
+// raiseFence publishes the term's token so writes of earlier leaders of
+// the shard are rejected from now on. It fails with storage.ErrStaleToken
+// if a later leader already raised it.
+func (r *shardRunner) raiseFence(ctx context.Context) error {
+	fence, err := raiseFenceScript.Run(ctx, r.s.rdb, []string{shard.FenceKey(r.id)}, r.token).Int64()
+	if err != nil {
+		return err
+	}
+	if fence > r.token {
+		return storage.ErrStaleToken
+	}
+	return nil
+}
+
+// fencedPush atomically pushes payload onto dest, with RPUSH (head) or
+// LPUSH (tail), and drops res from the processing queue. A deposed leader's
+// push is rejected and ends its term.
+func (r *shardRunner) fencedPush(cmd, dest string, payload any, res string) error {
+	keys := []string{shard.FenceKey(r.id), dest, r.processingQueue}
+	ok, err := fencedPushScript.Run(r.s.ctx, r.s.rdb, keys, r.token, payload, res, cmd).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		r.stale("redis")
+		return storage.ErrStaleToken
+	}
+	return nil
+}
+
+// updateStatus sets the task status in the database under the term's token.
+func (r *shardRunner) updateStatus(taskID, status string) {
+	err := storage.UpdateTasksFenced(taskID, status, r.token)
+	if err == storage.ErrStaleToken {
+		r.stale("postgres")
+	} else if err != nil {
+		log.Printf("⚠️ Failed to update task %s: %v", taskID, err)
+	}
+}
+
+// stale records a rejected write: another leader took the shard over, so
+// the term's loops stop without waiting for the election to notice.
+func (r *shardRunner) stale(store string) {
+	log.Printf("⚠️ [shard %d] %s rejected fencing token %d, stepping down", r.id, store, r.token)
+	monitor.SchedulerStaleWrites().WithLabelValues(store).Inc()
+	r.term.Cancel()
+}
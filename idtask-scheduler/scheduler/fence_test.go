@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/internal/aiclient"
+	"github.com/JamesDante/idtask-scheduler/internal/etcdclient"
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+func startEtcd(t *testing.T) *clientv3.Client {
+	t.Helper()
+	local, _ := url.Parse("http://127.0.0.1:0")
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{*local}
+	cfg.AdvertiseClientUrls = []url.URL{*local}
+	cfg.ListenPeerUrls = []url.URL{*local}
+	cfg.AdvertisePeerUrls = []url.URL{*local}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	etcd, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(etcd.Close)
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("embedded etcd took too long to start")
+	}
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{etcd.Clients[0].Addr().String()}, DialTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+// newTestRunner returns a runner for shard 1 holding fencing token 42,
+// backed by miniredis and the in-memory store.
+func newTestRunner(t *testing.T) (*shardRunner, *miniredis.Miniredis, *storage.MemoryStore) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	store := storage.NewMemoryStore()
+	storage.Use(store)
+
+	cli := startEtcd(t)
+	etcdclient.Use(cli)
+
+	s := New(rdb, cli, aiclient.StubPredictor{})
+	s.pool = NewWorkerPool()
+	r := newShardRunner(s, 1)
+	r.token = 42
+	r.term = newLifecycle(context.Background())
+	t.Cleanup(r.term.Stop)
+	return r, mr, store
+}
+
+// TestLeadRetriesFence fails Redis while a term starts and expects the
+// shard to be scheduled once Redis is back, within the same term.
+func TestLeadRetriesFence(t *testing.T) {
+	r, mr, _ := newTestRunner(t)
+	s := r.s
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	r.term.Go("fence", r.lead)
+	time.Sleep(3 * minFenceBackoff)
+	if s.leading(1) {
+		t.Fatal("leading shard 1 without a fence")
+	}
+
+	mr.SetError("")
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.leading(1) {
+		if time.Now().After(deadline) {
+			t.Fatal("shard 1 not scheduled after Redis recovered")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if fence, _ := mr.Get(shard.FenceKey(1)); fence != "42" {
+		t.Errorf("fence %q, want 42", fence)
+	}
+}
+
+// TestRequeueUnschedulable moves parked tasks back under the fence and
+// drops entries that do not decode.
+func TestRequeueUnschedulable(t *testing.T) {
+	r, mr, store := newTestRunner(t)
+
+	task := &models.Task{ID: "t1", Type: "gpu"}
+	if _, err := storage.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	mr.RPush(r.unschedulableQueue, "not json")
+	mr.RPush(r.unschedulableQueue, `{"id":"t1","type":"gpu"}`)
+
+	r.requeueUnschedulable(context.Background())
+
+	if mr.Exists(r.unschedulableQueue) {
+		left, _ := mr.List(r.unschedulableQueue)
+		t.Fatalf("still parked: %v", left)
+	}
+	if queued, _ := mr.List(r.taskQueue); len(queued) != 1 || queued[0] != `{"id":"t1","type":"gpu"}` {
+		t.Errorf("task queue %v, want only t1", queued)
+	}
+	if got, _ := store.GetTask("t1"); got.Status != "Pending" {
+		t.Errorf("status %q, want Pending", got.Status)
+	}
+
+	// a later leader raised the fence, nothing moves any more
+	mr.Set(shard.FenceKey(1), "43")
+	mr.RPush(r.unschedulableQueue, `{"id":"t1","type":"gpu"}`)
+
+	r.requeueUnschedulable(context.Background())
+
+	if parked, _ := mr.List(r.unschedulableQueue); len(parked) != 1 {
+		t.Errorf("parked %v, want the task left in place", parked)
+	}
+	if r.term.ctx.Err() == nil {
+		t.Error("stale leader was not deposed")
+	}
+}
+
+func (s *Scheduler) leading(n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, led := range s.status.Shards {
+		if led == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import "github.com/JamesDante/idtask-scheduler/models"
+
+// eligible drops the candidates that do not handle the task type or lack a
+// required label.
+func eligible(task *models.Task, candidates []Candidate) []Candidate {
+	var matched []Candidate
+	for _, c := range candidates {
+		if c.Status.Handles(task.Type) && c.Status.Labels.Satisfies(task.RequiredLabels) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+// preferred keeps the candidates matching the most preferred labels. It
+// runs after withCapacity, so full workers never hide ones with room that
+// match fewer preferred labels.
+func preferred(task *models.Task, candidates []Candidate) []Candidate {
+	best := -1
+	var matched []Candidate
+	for _, c := range candidates {
+		score := c.Status.Labels.Score(task.PreferredLabels)
+		switch {
+		case score > best:
+			best = score
+			matched = []Candidate{c}
+		case score == best:
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+// withCapacity drops the candidates whose in-flight tasks reached the
+// capacity they publish. Workers without a capacity are never full.
+func withCapacity(candidates []Candidate) []Candidate {
+	available := candidates[:0]
+	for _, c := range candidates {
+		if c.Status.Capacity > 0 && c.QueueLen >= int64(c.Status.Capacity) {
+			continue
+		}
+		available = append(available, c)
+	}
+	return available
+}
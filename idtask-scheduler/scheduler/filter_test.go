@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+func TestChooseWorkerPreferredLabels(t *testing.T) {
+	task := &models.Task{ID: "t1", Type: "render", PreferredLabels: models.Labels{"region": "eu-west"}}
+
+	tests := []struct {
+		name    string
+		workers []models.WorkerStatus
+		want    string
+		reason  string
+	}{
+		{
+			name: "preferred worker with room",
+			workers: []models.WorkerStatus{
+				{ID: "eu", Status: "ok", Labels: models.Labels{"region": "eu-west"}, Capacity: 1},
+				{ID: "us", Status: "ok", Labels: models.Labels{"region": "us-east"}, Capacity: 1},
+			},
+			want: "eu",
+		},
+		{
+			name: "preferred worker full",
+			workers: []models.WorkerStatus{
+				{ID: "eu", Status: "ok", Labels: models.Labels{"region": "eu-west"}, Capacity: 1, InFlight: 1},
+				{ID: "us", Status: "ok", Labels: models.Labels{"region": "us-east"}, Capacity: 1},
+			},
+			want: "us",
+		},
+		{
+			name: "every worker full",
+			workers: []models.WorkerStatus{
+				{ID: "eu", Status: "ok", Labels: models.Labels{"region": "eu-west"}, Capacity: 1, InFlight: 1},
+				{ID: "us", Status: "ok", Labels: models.Labels{"region": "us-east"}, Capacity: 1, InFlight: 1},
+			},
+			reason: "at-capacity",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scheduler{pool: NewWorkerPool(), strategy: LeastLoaded{}}
+			for _, ws := range tt.workers {
+				s.pool.Update(ws)
+			}
+
+			worker, reason := s.chooseWorker(task, nil)
+			if worker != tt.want {
+				t.Errorf("picked %q (%s), want %q", worker, reason, tt.want)
+			}
+			if tt.reason != "" && reason != tt.reason {
+				t.Errorf("reason %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
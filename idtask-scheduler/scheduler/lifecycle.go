@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// lifecycle runs a set of loops under one cancellable context, e.g. the
+// loops of a leadership term. Stop cancels the context and waits for every
+// loop to return, so a new lifecycle can start a fresh set without
+// duplicating them.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newLifecycle(parent context.Context) *lifecycle {
+	ctx, cancel := context.WithCancel(parent)
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Go starts loop, which must return once its ctx is done.
+func (l *lifecycle) Go(name string, loop func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		loop(l.ctx)
+		log.Printf("[lifecycle] %s stopped", name)
+	}()
+}
+
+// Cancel asks every loop to stop without waiting, it is safe to call from
+// one of the loops.
+func (l *lifecycle) Cancel() {
+	l.cancel()
+}
+
+// Stop cancels the loops and waits until all of them have returned.
+func (l *lifecycle) Stop() {
+	l.cancel()
+	l.wg.Wait()
+}
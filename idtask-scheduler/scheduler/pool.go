@@ -1,4 +1,4 @@
-package main
+package scheduler
 
 import (
 	"context"
@@ -7,27 +7,36 @@ import (
 	"sync"
 	"time"
 
+	"github.com/JamesDante/idtask-scheduler/internal/drain"
 	"github.com/JamesDante/idtask-scheduler/models"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// WorkerPool is the scheduler's in-memory view of the workers. It is fed by
+// WorkerWatcher events and periodic refreshes, and tracks how many tasks
+// each worker has in flight, so picking a worker needs no network I/O.
 type WorkerPool struct {
-	mu      sync.RWMutex
-	workers []string
-	index   int
+	mu       sync.RWMutex
+	workers  []string
+	index    int
+	status   map[string]models.WorkerStatus
+	inFlight map[string]int
+	changed  chan struct{}
+	joined   chan struct{}
 }
 
 func NewWorkerPool() *WorkerPool {
 	return &WorkerPool{
-		workers: []string{},
-		index:   0,
+		workers:  []string{},
+		index:    0,
+		status:   make(map[string]models.WorkerStatus),
+		inFlight: make(map[string]int),
+		changed:  make(chan struct{}),
+		joined:   make(chan struct{}),
 	}
 }
 
 func (wp *WorkerPool) InitFromEtcd(cli *clientv3.Client, prefix string) error {
-	wp.mu.Lock()
-	defer wp.mu.Unlock()
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -36,28 +45,58 @@ func (wp *WorkerPool) InitFromEtcd(cli *clientv3.Client, prefix string) error {
 		return err
 	}
 
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
 	wp.workers = make([]string, 0, len(resp.Kvs))
-	seen := make(map[string]bool)
+	status := make(map[string]models.WorkerStatus, len(resp.Kvs))
 	for _, kv := range resp.Kvs {
-		var status models.WorkerStatus
-		if err := json.Unmarshal(kv.Value, &status); err != nil {
+		var ws models.WorkerStatus
+		if err := json.Unmarshal(kv.Value, &ws); err != nil {
 			continue
 		}
 
-		if !seen[status.ID] {
-			wp.workers = append(wp.workers, status.ID)
-			seen[status.ID] = true
+		if _, seen := status[ws.ID]; !seen {
+			wp.workers = append(wp.workers, ws.ID)
 		}
+		status[ws.ID] = ws
 	}
+
+	for id := range wp.inFlight {
+		if _, ok := status[id]; !ok {
+			delete(wp.inFlight, id)
+		}
+	}
+	for id, ws := range status {
+		wp.inFlight[id] = ws.InFlight
+	}
+	added := false
+	for id := range status {
+		if _, known := wp.status[id]; !known {
+			added = true
+		}
+	}
+	wp.status = status
 	wp.index = 0
+	wp.notify()
+	if added {
+		wp.notifyJoined()
+	}
 	return nil
 }
 
-func (wp *WorkerPool) StartAutoRefresh(etcd *clientv3.Client, prefix string, interval time.Duration) {
+// StartAutoRefresh reloads the pool from etcd every interval until ctx is done.
+func (wp *WorkerPool) StartAutoRefresh(ctx context.Context, etcd *clientv3.Client, prefix string, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
 		log.Println("[pool] Auto-refreshing worker pool from etcd...")
 		err := wp.InitFromEtcd(etcd, prefix)
 		if err != nil {
@@ -69,6 +108,10 @@ func (wp *WorkerPool) StartAutoRefresh(etcd *clientv3.Client, prefix string, int
 func (wp *WorkerPool) Exists(id string) bool {
 	wp.mu.RLock()
 	defer wp.mu.RUnlock()
+	return wp.contains(id)
+}
+
+func (wp *WorkerPool) contains(id string) bool {
 	for _, w := range wp.workers {
 		if w == id {
 			return true
@@ -81,12 +124,32 @@ func (wp *WorkerPool) Add(worker string) {
 	wp.mu.Lock()
 	defer wp.mu.Unlock()
 
-	for _, w := range wp.workers {
-		if w == worker {
-			return
-		}
+	if wp.contains(worker) {
+		return
 	}
 	wp.workers = append(wp.workers, worker)
+	wp.notify()
+	wp.notifyJoined()
+}
+
+// Update records a status published by a worker, on registration or
+// heartbeat, and reports whether the worker is new to the pool. The
+// worker's own in-flight count replaces the local estimate.
+func (wp *WorkerPool) Update(ws models.WorkerStatus) bool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	added := !wp.contains(ws.ID)
+	if added {
+		wp.workers = append(wp.workers, ws.ID)
+	}
+	wp.status[ws.ID] = ws
+	wp.inFlight[ws.ID] = ws.InFlight
+	wp.notify()
+	if added {
+		wp.notifyJoined()
+	}
+	return added
 }
 
 func (wp *WorkerPool) Remove(worker string) {
@@ -100,14 +163,17 @@ func (wp *WorkerPool) Remove(worker string) {
 		}
 	}
 	wp.workers = newWorkers
+	delete(wp.status, worker)
+	delete(wp.inFlight, worker)
 	if wp.index >= len(wp.workers) {
 		wp.index = 0
 	}
+	wp.notify()
 }
 
 func (wp *WorkerPool) Next() (string, error) {
-	wp.mu.RLock()
-	defer wp.mu.RUnlock()
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
 
 	if len(wp.workers) == 0 {
 		return "", errors.New("no available workers")
@@ -117,3 +183,63 @@ func (wp *WorkerPool) Next() (string, error) {
 	wp.index = (wp.index + 1) % len(wp.workers)
 	return worker, nil
 }
+
+// Candidates lists the workers neither failed nor draining, with their
+// in-flight count.
+func (wp *WorkerPool) Candidates() []Candidate {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+
+	candidates := make([]Candidate, 0, len(wp.workers))
+	for _, id := range wp.workers {
+		ws, ok := wp.status[id]
+		if !ok {
+			// added without a status yet, assume a fresh healthy worker
+			ws = models.WorkerStatus{ID: id, Status: "ok"}
+		}
+		if ws.Status == "failed" || ws.Status == drain.Status {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			ID:       id,
+			QueueLen: int64(wp.inFlight[id]),
+			Weight:   ws.Weight,
+			Status:   ws,
+		})
+	}
+	return candidates
+}
+
+// Dispatched counts a task sent to worker until its next status update.
+func (wp *WorkerPool) Dispatched(worker string) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.inFlight[worker]++
+}
+
+// Changed returns a channel closed on the next change to the pool.
+func (wp *WorkerPool) Changed() <-chan struct{} {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	return wp.changed
+}
+
+// notify wakes Changed waiters, callers hold wp.mu.
+func (wp *WorkerPool) notify() {
+	close(wp.changed)
+	wp.changed = make(chan struct{})
+}
+
+// Joined returns a channel closed when the next new worker enters the pool.
+// Unlike Changed it ignores heartbeats and departures.
+func (wp *WorkerPool) Joined() <-chan struct{} {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	return wp.joined
+}
+
+// notifyJoined wakes Joined waiters, callers hold wp.mu.
+func (wp *WorkerPool) notifyJoined() {
+	close(wp.joined)
+	wp.joined = make(chan struct{})
+}
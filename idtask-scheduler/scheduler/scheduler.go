@@ -0,0 +1,393 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/aiclient"
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/internal/etcdclient"
+	"github.com/JamesDante/idtask-scheduler/internal/limits"
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/utils"
+	"github.com/google/uuid"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/go-redis/redis/v8"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	json     = jsoniter.ConfigFastest
+	taskPool = sync.Pool{
+		New: func() any {
+			return new(models.Task)
+		},
+	}
+)
+
+const (
+	maxWorkerFailures = 3
+
+	// fairPollInterval is how often empty tenant queues are checked again
+	fairPollInterval = 200 * time.Millisecond
+)
+
+// Scheduler moves tasks from the shard task queues to worker queues. The
+// shards are spread over every running scheduler; this instance campaigns
+// for the leader key of each shard assigned to it and schedules the shards
+// it wins.
+type Scheduler struct {
+	rdb     *redis.Client
+	aic     aiclient.Predictor
+	etcd    *clientv3.Client
+	pool    *WorkerPool
+	watcher *WorkerWatcher
+	leaseID clientv3.LeaseID
+	key     string
+
+	// strategy picks workers for task types without an entry in byType.
+	strategy Strategy
+	byType   map[string]Strategy
+
+	// limits enforces TASK_LIMITS at dispatch, nil when none are set.
+	limits *limits.Limiter
+
+	// tenant fair share settings, see fairShare
+	tenantWeights   map[string]float64
+	tenantMinShares map[string]float64
+
+	ctx        context.Context
+	instanceID string
+
+	mu     sync.Mutex
+	status models.SchedulerStatus
+
+	// shards this instance campaigns for, only touched by balanceShards
+	shards map[int]*shardRunner
+
+	// resign stops the campaigns, resigned is closed once they ended
+	resign   context.CancelFunc
+	resigned chan struct{}
+}
+
+// New creates a scheduler on top of already initialized clients.
+// etcdclient must point at the same cluster as cli.
+// Strategies come from SCHEDULER_STRATEGY and SCHEDULER_STRATEGIES; invalid
+// names are logged and replaced by the default. Invalid TASK_LIMITS are
+// logged and ignored, as are invalid tenant weights and minimum shares.
+func New(rdb *redis.Client, cli *clientv3.Client, aic aiclient.Predictor) *Scheduler {
+	name := configs.Config.SchedulerStrategy
+	if name == "" {
+		name = defaultStrategy
+	}
+	strategy, err := NewStrategy(name)
+	if err != nil {
+		log.Printf("⚠️ %v, using %q", err, defaultStrategy)
+		strategy, _ = NewStrategy(defaultStrategy)
+	}
+
+	byType, err := ParseStrategies(configs.Config.SchedulerStrategies)
+	if err != nil {
+		log.Printf("⚠️ Ignoring SCHEDULER_STRATEGIES: %v", err)
+		byType = map[string]Strategy{}
+	}
+
+	limiter, err := limits.NewLimiter(rdb, configs.Config.TaskLimits, configs.Config.TaskLimitLease)
+	if err != nil {
+		log.Printf("⚠️ Ignoring TASK_LIMITS: %v", err)
+	}
+
+	weights, err := parseTenantValues(configs.Config.TenantWeights)
+	if err != nil {
+		log.Printf("⚠️ Ignoring TENANT_WEIGHTS: %v", err)
+	}
+	minShares, err := parseTenantValues(configs.Config.TenantMinShares)
+	if err != nil {
+		log.Printf("⚠️ Ignoring TENANT_MIN_SHARES: %v", err)
+	}
+
+	return &Scheduler{
+		rdb:      rdb,
+		aic:      aic,
+		etcd:     cli,
+		strategy: strategy,
+		byType:   byType,
+		limits:   limiter,
+
+		tenantWeights:   weights,
+		tenantMinShares: minShares,
+		ctx:             context.Background(),
+		instanceID:      generateInstanceID(),
+		shards:          make(map[int]*shardRunner),
+		resigned:        make(chan struct{}),
+	}
+}
+
+// Run registers the scheduler status, watches the workers and campaigns for
+// this instance's share of the shards until ctx is done or Resign is called.
+// Every loop it starts has stopped when it returns.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.ctx = ctx
+
+	s.status = models.SchedulerStatus{
+		ID:       fmt.Sprintf("scheduler-%s", s.instanceID),
+		Status:   "running",
+		IsLeader: "No",
+	}
+
+	s.key = schedulerStatusPrefix + s.status.ID
+	data, _ := json.Marshal(s.status)
+	var err error
+	s.leaseID, err = etcdclient.RegisterWithTTL(ctx, s.key, string(data), 10) // TTL 10 sec
+	if err != nil {
+		return err
+	}
+
+	s.watcher, _ = NewWorkerWatcher(s.etcd, "/workers/")
+	s.pool = NewWorkerPool()
+	s.pool.InitFromEtcd(s.etcd, "/workers/")
+
+	s.watcher.OnAdd = func(worker models.WorkerStatus) {
+		// registrations and heartbeats both land here
+		if s.pool.Update(worker) {
+			log.Println("add worker:", worker.ID)
+		}
+	}
+
+	s.watcher.OnDelete = func(worker models.WorkerStatus) {
+		log.Println("remove worker:", worker.ID)
+		s.pool.Remove(worker.ID)
+	}
+
+	loops := newLifecycle(ctx)
+	defer loops.Stop()
+	loops.Go("pool refresh", func(ctx context.Context) {
+		s.pool.StartAutoRefresh(ctx, s.etcd, "/workers/", 10*time.Second)
+	})
+	loops.Go("heartbeat", s.heartbeat)
+
+	s.watcher.Start()
+	defer func() {
+		s.watcher.Stop()
+		log.Println("Worker watcher stopped.")
+	}()
+
+	campaigns, stop := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.resign = stop
+	s.mu.Unlock()
+
+	s.balanceShards(campaigns)
+	if ctx.Err() == nil {
+		// resigned: drop the status key so the others rebalance without
+		// waiting for its TTL
+		loops.Stop()
+		if _, err := s.etcd.Revoke(ctx, s.leaseID); err != nil {
+			log.Printf("Failed to revoke scheduler lease: %v", err)
+		}
+	}
+	close(s.resigned)
+	return nil
+}
+
+// Resign hands every shard this instance leads over to the other
+// schedulers and leaves the member list, e.g. before a rolling restart.
+// It returns once the shards are released, as does Run.
+func (s *Scheduler) Resign(ctx context.Context) error {
+	s.mu.Lock()
+	stop := s.resign
+	s.mu.Unlock()
+	if stop == nil {
+		return errors.New("scheduler is not running")
+	}
+
+	log.Println("👋 Resigning, handing shards over")
+	stop()
+	select {
+	case <-s.resigned:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// heartbeat refreshes the scheduler status in etcd until ctx is done.
+func (s *Scheduler) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.publishStatus()
+		}
+	}
+}
+
+// setLeading records whether this instance leads shard n.
+func (s *Scheduler) setLeading(n int, leading bool) {
+	s.mu.Lock()
+	shards := make([]int, 0, len(s.status.Shards)+1)
+	for _, led := range s.status.Shards {
+		if led != n {
+			shards = append(shards, led)
+		}
+	}
+	if leading {
+		shards = append(shards, n)
+		sort.Ints(shards)
+	}
+	s.status.Shards = shards
+	s.status.IsLeader = "No"
+	if len(shards) > 0 {
+		s.status.IsLeader = "Yes"
+	}
+	s.mu.Unlock()
+
+	s.publishStatus()
+}
+
+func (s *Scheduler) publishStatus() {
+	s.mu.Lock()
+	s.status.HeartBeat = time.Now()
+	data, _ := json.Marshal(s.status)
+	s.mu.Unlock()
+
+	err := etcdclient.Update(s.ctx, s.key, string(data), s.leaseID)
+	if err != nil && s.ctx.Err() == nil {
+		log.Printf("Failed to update scheduler heartbeat: %v", err)
+	}
+}
+
+// predict returns one prediction per task. When the predictor fails the
+// tasks are scheduled without a recommendation instead of being dropped.
+func (s *Scheduler) predict(tasks []*models.Task) []*pb.PredictResponse {
+	reqs := make([]*pb.PredictRequest, len(tasks))
+	for i, task := range tasks {
+		reqs[i] = &pb.PredictRequest{
+			TaskId: task.ID,
+			Metadata: map[string]string{
+				"TaskId":   task.ID,
+				"TaskType": task.Type,
+				"Priority": utils.FormatNullInt(task.Priority),
+			},
+		}
+	}
+
+	predictions, err := aiclient.PredictAll(s.aic, reqs)
+	if err != nil || len(predictions) != len(tasks) {
+		log.Println("Error AI Predict task, scheduling without prediction:", err)
+		predictions = make([]*pb.PredictResponse, len(tasks))
+		for i := range predictions {
+			predictions[i] = &pb.PredictResponse{}
+		}
+	}
+	return predictions
+}
+
+func generateInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8])
+}
+
+// chooseWorker hands the healthy workers that satisfy the task's labels and
+// have spare capacity, the best preferred-label matches among them, to the
+// strategy configured for the task type, and returns its pick along with
+// the strategy's reason. It returns no worker with "unschedulable" if no
+// worker qualifies, or "at-capacity" if the ones that do are all full. It
+// works on the pool's in-memory view only.
+func (s *Scheduler) chooseWorker(task *models.Task, prediction *pb.PredictResponse) (string, string) {
+	strategy, ok := s.byType[task.Type]
+	if !ok {
+		strategy = s.strategy
+	}
+
+	candidates := s.pool.Candidates()
+	if len(candidates) == 0 {
+		log.Println("No available worker")
+		return "", "none"
+	}
+
+	candidates = eligible(task, candidates)
+	if len(candidates) == 0 {
+		log.Printf("No worker satisfies task %s (type=%s, required=%v)", task.ID, task.Type, task.RequiredLabels)
+		return "", "unschedulable"
+	}
+
+	candidates = withCapacity(candidates)
+	if len(candidates) == 0 {
+		return "", "at-capacity"
+	}
+	candidates = preferred(task, candidates)
+
+	worker, reason := strategy.Pick(task, prediction, candidates)
+	log.Printf("Selected worker %s for task %s (%s)", worker, task.ID, reason)
+	return worker, reason
+}
+
+func parseTask(taskstr string) *models.Task {
+	t := taskPool.Get().(*models.Task)
+	*t = models.Task{}
+	err := json.Unmarshal([]byte(taskstr), t)
+	if err != nil {
+		log.Printf("Invalid task JSON: %v", err)
+		taskPool.Put(t)
+		return nil
+	}
+	return t
+}
+
+// pollDelayedTasks moves due delayed tasks to their shard's task queue. The
+// leader of shard 0 runs it until ctx is done, fenced by its own token.
+func (r *shardRunner) pollDelayedTasks(ctx context.Context) {
+	s := r.s
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().Unix()
+		tasks, _ := s.rdb.ZRangeByScore(ctx, "delayed-tasks", &redis.ZRangeBy{
+			Min: "-inf",
+			Max: fmt.Sprintf("%d", now),
+		}).Result()
+
+		for _, t := range tasks {
+			var task models.Task
+			json.Unmarshal([]byte(t), &task)
+			keys := []string{shard.FenceKey(r.id), "delayed-tasks", shard.QueueOf(&task)}
+			moved, err := fencedDelayedScript.Run(ctx, s.rdb, keys, r.token, t).Int()
+			if err != nil {
+				log.Printf("[delayed] Failed to move task: %v", err)
+				continue
+			}
+			if moved < 0 {
+				r.stale("redis")
+				return
+			}
+			if moved == 1 {
+				log.Printf("[delayed] moved task to queue: %v", t)
+			}
+		}
+	}
+}
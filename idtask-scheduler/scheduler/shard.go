@@ -0,0 +1,527 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/internal/audit"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/cancel"
+	"github.com/JamesDante/idtask-scheduler/internal/limits"
+	"github.com/JamesDante/idtask-scheduler/internal/pending"
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/go-redis/redis/v8"
+)
+
+// shardRunner campaigns for one shard's leader key and, while it holds it,
+// moves the shard's tasks to workers.
+type shardRunner struct {
+	s                  *Scheduler
+	id                 int
+	taskQueue          string
+	processingQueue    string
+	unschedulableQueue string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// set when a term starts, before its loops run
+	token int64
+	term  *lifecycle
+	fair  *fairShare
+
+	// touched only by the scheduling loop of the current term
+	workerFailures map[string]int
+}
+
+func newShardRunner(s *Scheduler, id int) *shardRunner {
+	return &shardRunner{
+		s:                  s,
+		id:                 id,
+		taskQueue:          shard.TaskQueue(id),
+		processingQueue:    shard.ProcessingQueue(id),
+		unschedulableQueue: shard.UnschedulableQueue(id),
+	}
+}
+
+// start campaigns for the shard until stop is called or ctx is done.
+func (r *shardRunner) start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		le, err := NewLeaderElector(r.s.etcd, shard.LeaderKey(r.id), r.s.instanceID, configs.LockTTL)
+		if err != nil {
+			log.Printf("[shard %d] Failed to create election: %v", r.id, err)
+			return
+		}
+		// closing the session releases the leader key for the next owner
+		defer le.Close()
+
+		le.OnElected = func(ctx context.Context) {
+			r.token = le.Token()
+			r.term = newLifecycle(ctx)
+			r.term.Go(fmt.Sprintf("shard %d fence", r.id), r.lead)
+		}
+
+		// the term's context is already cancelled when this runs
+		le.OnResigned = func() {
+			r.term.Stop()
+			r.s.setLeading(r.id, false)
+			log.Printf("Resigned from leadership of shard %d, stopping scheduler", r.id)
+		}
+
+		le.CampaignLoop(ctx)
+	}()
+}
+
+// Backoff between attempts to raise the fence at the start of a term.
+const (
+	minFenceBackoff = 100 * time.Millisecond
+	maxFenceBackoff = 5 * time.Second
+)
+
+// lead raises the fence for the new term, retrying with backoff while Redis
+// fails, then starts the term's loops. It gives up on the term only when a
+// later leader already raised the fence or leadership ends.
+func (r *shardRunner) lead(ctx context.Context) {
+	backoff := minFenceBackoff
+	for {
+		err := r.raiseFence(ctx)
+		if err == nil {
+			break
+		}
+		if err == storage.ErrStaleToken {
+			log.Printf("[shard %d] Not scheduling with fencing token %d: %v", r.id, r.token, err)
+			return
+		}
+		log.Printf("[shard %d] Failed to raise fencing token %d, retrying in %s: %v", r.id, r.token, backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxFenceBackoff)
+	}
+	log.Printf("Elected leader of shard %d (fencing token %d), starting scheduler", r.id, r.token)
+
+	r.workerFailures = make(map[string]int)
+	r.fair = newFairShare(r.s.tenantWeights, r.s.tenantMinShares)
+	r.s.setLeading(r.id, true)
+
+	r.term.Go(fmt.Sprintf("shard %d scheduling", r.id), r.schedulingWork)
+	r.term.Go(fmt.Sprintf("shard %d recovery", r.id), r.startProcessingQueueWatcher)
+	r.term.Go(fmt.Sprintf("shard %d unschedulable", r.id), r.retryUnschedulable)
+	if r.id == 0 {
+		r.term.Go("delayed tasks", r.pollDelayedTasks)
+	}
+}
+
+// stop ends the campaign, handing leadership over if this instance holds
+// it, and returns once the term's loops have stopped.
+func (r *shardRunner) stop() {
+	r.cancel()
+	<-r.done
+}
+
+// schedulingWork runs until ctx, the leadership term, is done.
+func (r *shardRunner) schedulingWork(ctx context.Context) {
+	s := r.s
+	for ctx.Err() == nil {
+		r.releaseThrottled(ctx)
+		raws, err := r.popTasks(ctx)
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				log.Println("Error fetching task:", err)
+			}
+			continue
+		}
+
+		tasks := make([]*models.Task, 0, len(raws))
+		taskRaws := make([]string, 0, len(raws))
+		for _, res := range raws {
+			log.Printf("[Scheduler] Task popped: shard=%d raw=%v", r.id, res)
+
+			if len(res) < 2 {
+				continue
+			}
+
+			task := parseTask(res)
+
+			if task == nil {
+				s.rdb.LRem(s.ctx, r.processingQueue, 1, res)
+				continue
+			}
+
+			if task.ExpireAt != nil && time.Now().After(*task.ExpireAt) {
+				log.Printf("Task %s is expired, skipping\n", task.ID)
+				s.rdb.LRem(s.ctx, r.processingQueue, 1, res)
+				r.updateStatus(task.ID, "Expired")
+				blob.Release(s.ctx, task)
+				pending.Release(s.ctx, s.rdb, task)
+				continue
+			}
+
+			if cancel.Cancelled(s.ctx, s.rdb, task.ID) {
+				log.Printf("Task %s was cancelled, skipping\n", task.ID)
+				s.rdb.LRem(s.ctx, r.processingQueue, 1, res)
+				blob.Release(s.ctx, task)
+				pending.Release(s.ctx, s.rdb, task)
+				continue
+			}
+
+			tasks = append(tasks, task)
+			taskRaws = append(taskRaws, res)
+		}
+
+		predictions := s.predict(tasks)
+		var held []*models.Task
+		var heldRaws []string
+		for i, task := range tasks {
+			if ctx.Err() != nil {
+				// deposed mid-batch, the next leader recovers the rest
+				break
+			}
+			if !r.dispatch(task, taskRaws[i], predictions[i]) {
+				held = append(held, task)
+				heldRaws = append(heldRaws, taskRaws[i])
+			}
+		}
+		if len(held) > 0 {
+			r.holdTasks(ctx, held, heldRaws)
+		}
+	}
+}
+
+// holdTasks returns tasks no worker had room for to the head of their
+// tenant's queue, in their original order, and waits for the pool to
+// change before the loop pops them again.
+func (r *shardRunner) holdTasks(ctx context.Context, tasks []*models.Task, raws []string) {
+	s := r.s
+	log.Printf("No worker capacity, holding %d task(s)", len(raws))
+	monitor.SchedulerTasksHeld().Add(float64(len(raws)))
+
+	changed := s.pool.Changed()
+	for i := len(raws) - 1; i >= 0; i-- {
+		if err := r.fencedPush("RPUSH", r.queueOf(tasks[i]), raws[i], raws[i]); err != nil {
+			return
+		}
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+	}
+}
+
+// popTasks takes the next batch of tasks, up to SchedulerBatchSize, off
+// the shard's queues. Until a tenant other than the default one submits to
+// the shard there is a single queue to pop; after that the tenant queues
+// are shared by fair share.
+func (r *shardRunner) popTasks(ctx context.Context) ([]string, error) {
+	tenants, err := r.s.rdb.SMembers(ctx, shard.Tenants(r.id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return r.popQueue(ctx)
+	}
+	return r.popFair(ctx, tenants)
+}
+
+// popQueue waits up to a second for the first task, then takes whatever
+// else is already queued so it can be predicted in the same round trip.
+// The short wait lets the loop notice a lost term; it is shorter still
+// when throttled tasks are due sooner.
+func (r *shardRunner) popQueue(ctx context.Context) ([]string, error) {
+	var res string
+	var err error
+	if wait := r.untilThrottledDue(ctx); wait < time.Second {
+		// blocking pops only take whole seconds
+		res, err = r.s.rdb.RPopLPush(ctx, r.taskQueue, r.processingQueue).Result()
+		if err == redis.Nil {
+			sleep(ctx, wait)
+		}
+	} else {
+		res, err = r.s.rdb.BRPopLPush(ctx, r.taskQueue, r.processingQueue, time.Second).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raws := []string{res}
+	for len(raws) < configs.Config.SchedulerBatchSize {
+		res, err := r.s.rdb.RPopLPush(ctx, r.taskQueue, r.processingQueue).Result()
+		if err != nil {
+			if err != redis.Nil {
+				log.Println("Error fetching task:", err)
+			}
+			break
+		}
+		raws = append(raws, res)
+	}
+	return raws, nil
+}
+
+// popFair fills the batch from the tenant queues in the order fair share
+// plans. Several lists cannot be waited on at once, so with every queue
+// empty it polls.
+func (r *shardRunner) popFair(ctx context.Context, tenants []string) ([]string, error) {
+	s := r.s
+	tenants = append(tenants, models.DefaultTenant)
+
+	pipe := s.rdb.Pipeline()
+	lens := make([]*redis.IntCmd, len(tenants))
+	for i, t := range tenants {
+		lens[i] = pipe.LLen(ctx, shard.TenantQueue(r.id, t))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	backlog := make(map[string]int64, len(tenants))
+	for i, t := range tenants {
+		backlog[t] = lens[i].Val()
+	}
+
+	plan := r.fair.plan(backlog, configs.Config.SchedulerBatchSize)
+	raws := make([]string, 0, len(plan))
+	for _, t := range plan {
+		res, err := s.rdb.RPopLPush(ctx, shard.TenantQueue(r.id, t), r.processingQueue).Result()
+		if err != nil {
+			if err != redis.Nil {
+				log.Println("Error fetching task:", err)
+			}
+			continue
+		}
+		raws = append(raws, res)
+		monitor.SchedulerTenantDequeued().WithLabelValues(t).Inc()
+	}
+
+	if len(raws) == 0 {
+		wait := r.untilThrottledDue(ctx)
+		if wait > fairPollInterval {
+			wait = fairPollInterval
+		}
+		sleep(ctx, wait)
+		return nil, redis.Nil
+	}
+	return raws, nil
+}
+
+// queueOf is the queue the task goes back to when it is requeued.
+func (r *shardRunner) queueOf(t *models.Task) string {
+	return shard.TenantQueue(r.id, t.Tenant)
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// dispatch sends the task to a worker and reports whether it was handled.
+// It returns false when no worker has room for the task right now; the
+// caller puts such tasks back at the head of the task queue.
+func (r *shardRunner) dispatch(task *models.Task, res string, aiPrediction *pb.PredictResponse) bool {
+	s := r.s
+	workerNode, reason := s.chooseWorker(task, aiPrediction)
+	if workerNode == "" && reason != "unschedulable" {
+		return false
+	}
+	if workerNode != "" && r.throttle(task, res) {
+		return true
+	}
+
+	task.Prediction = &models.TaskPrediction{
+		Priority:          aiPrediction.Priority,
+		EstimatedTime:     aiPrediction.EstimatedTime,
+		RecommendedWorker: aiPrediction.RecommendedWorker,
+		ModelVersion:      aiPrediction.ModelVersion,
+		WorkerConfidence:  aiPrediction.WorkerConfidence,
+	}
+
+	decision := models.SchedulingDecision{
+		TaskID:            task.ID,
+		Worker:            workerNode,
+		Reason:            reason,
+		ModelVersion:      aiPrediction.ModelVersion,
+		RecommendedWorker: aiPrediction.RecommendedWorker,
+		WorkerConfidence:  float64(aiPrediction.WorkerConfidence),
+		FencingToken:      r.token,
+	}
+	if err := storage.CreateSchedulingDecision(&decision); err != nil {
+		log.Printf("⚠️ Failed to record scheduling decision for task %s: %v", task.ID, err)
+	}
+
+	task.FencingToken = r.token
+	taskBytes, err := json.Marshal(task)
+	if err != nil {
+		log.Printf("Failed to marshal task %s: %v", task.ID, err)
+		return true
+	}
+
+	if reason == "unschedulable" {
+		r.parkUnschedulable(task, res, taskBytes)
+		return true
+	}
+
+	err = r.fencedPush("RPUSH", workerNode, taskBytes, res)
+	if err != nil {
+		// the task never reached the worker, give its slot back
+		limits.Release(s.ctx, s.rdb, task)
+	}
+	if err == storage.ErrStaleToken {
+		return true
+	} else if err != nil {
+		log.Printf("Failed to push task to worker %s: %v", workerNode, err)
+		r.workerFailures[workerNode]++
+		if r.workerFailures[workerNode] >= maxWorkerFailures {
+			log.Printf("Worker %s marked as unhealthy after %d failures, removing from pool", workerNode, maxWorkerFailures)
+			s.pool.Remove(workerNode)
+			delete(r.workerFailures, workerNode)
+			r.audit("worker.remove", workerNode, audit.Success, map[string]any{"failures": maxWorkerFailures, "error": err.Error()})
+		}
+		r.fencedPush("RPUSH", r.queueOf(task), taskBytes, res)
+
+	} else {
+		// the worker queue holds the task now, with its prediction attached
+		s.pool.Dispatched(workerNode)
+		monitor.SchedulerTasksScheduled().Inc()
+		log.Printf("Task %s scheduled to worker %s\n", task.ID, workerNode)
+		r.workerFailures[workerNode] = 0
+	}
+	return true
+}
+
+func (r *shardRunner) startProcessingQueueWatcher(ctx context.Context) {
+	s := r.s
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		log.Printf("[recovery] Checking stuck tasks in %s...", r.processingQueue)
+
+		tasks, err := s.rdb.LRange(ctx, r.processingQueue, 0, -1).Result()
+		if err != nil {
+			log.Printf("[recovery] Failed to read processing-queue: %v", err)
+			continue
+		}
+
+		for _, taskStr := range tasks {
+			var task models.Task
+			if err := json.Unmarshal([]byte(taskStr), &task); err != nil {
+				log.Printf("[recovery] Invalid task JSON, removing: %v", err)
+				s.rdb.LRem(ctx, r.processingQueue, 1, taskStr)
+				continue
+			}
+
+			if task.CreatedAt != nil && time.Since(*task.CreatedAt) > 30*time.Second {
+				log.Printf("[recovery] Task %s expired in processing queue, requeueing", task.ID)
+
+				queue := r.queueOf(&task)
+				if err := r.fencedPush("LPUSH", queue, taskStr, taskStr); err != nil {
+					r.audit("task.requeue", task.ID, audit.Failure, map[string]any{"queue": queue, "error": err.Error()})
+					return
+				}
+				r.audit("task.requeue", task.ID, audit.Success, map[string]any{"queue": queue})
+			}
+		}
+	}
+}
+
+// parkUnschedulable takes a task that no worker can run off the processing
+// queue and keeps it aside until the pool changes.
+func (r *shardRunner) parkUnschedulable(task *models.Task, res string, taskBytes []byte) {
+	if err := r.fencedPush("RPUSH", r.unschedulableQueue, taskBytes, res); err != nil {
+		return
+	}
+	r.updateStatus(task.ID, "Unschedulable")
+	monitor.SchedulerTasksUnschedulable().Inc()
+}
+
+// retryUnschedulable requeues the shard's parked tasks whenever a worker
+// joins the pool, it may satisfy their constraints. It runs until ctx, the
+// leadership term, is done.
+func (r *shardRunner) retryUnschedulable(ctx context.Context) {
+	for {
+		joined := r.s.pool.Joined()
+		select {
+		case <-ctx.Done():
+			return
+		case <-joined:
+		}
+		r.requeueUnschedulable(ctx)
+	}
+}
+
+// requeueUnschedulable moves every parked task back to its tenant's queue.
+// Each task is moved by a fenced script, so it is never in both lists or in
+// neither and a deposed leader moves nothing. Entries that do not decode
+// are dropped.
+func (r *shardRunner) requeueUnschedulable(ctx context.Context) {
+	s := r.s
+	for ctx.Err() == nil {
+		raw, err := s.rdb.LIndex(ctx, r.unschedulableQueue, 0).Result()
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				log.Printf("Failed to requeue unschedulable tasks: %v", err)
+			}
+			return
+		}
+
+		var task models.Task
+		if err := json.Unmarshal([]byte(raw), &task); err != nil {
+			log.Printf("⚠️ Dropping undecodable unschedulable entry: %v", err)
+			s.rdb.LRem(ctx, r.unschedulableQueue, 1, raw)
+			continue
+		}
+
+		// Pending before the move, the task may be scheduled right after it
+		r.updateStatus(task.ID, "Pending")
+		keys := []string{shard.FenceKey(r.id), r.unschedulableQueue, shard.TenantQueue(r.id, task.Tenant)}
+		moved, err := fencedRequeueScript.Run(ctx, s.rdb, keys, r.token, raw).Int()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to requeue unschedulable task %s: %v", task.ID, err)
+			}
+			return
+		}
+		if moved < 0 {
+			r.stale("redis")
+			return
+		}
+		if moved == 1 {
+			log.Printf("[unschedulable] requeued task %s", task.ID)
+		}
+	}
+}
+
+// audit records an action the shard's leader took on its own.
+func (r *shardRunner) audit(action, target, outcome string, params map[string]any) {
+	params["shard"] = r.id
+	params["fencing_token"] = r.token
+	audit.Record(&models.AuditEvent{
+		Principal: "scheduler:" + r.s.instanceID,
+		Action:    action,
+		Target:    target,
+		Params:    audit.Params(params),
+		Outcome:   outcome,
+	})
+}
@@ -0,0 +1,270 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// Candidate is a healthy worker a task may be sent to.
+type Candidate struct {
+	ID       string
+	QueueLen int64
+	Weight   int
+	// Status is the worker's last published status, with its labels.
+	Status models.WorkerStatus
+}
+
+// Strategy picks the worker for a task among the healthy candidates.
+// It returns the worker ID and a short reason recorded with the scheduling
+// decision, or an empty ID if none of the candidates fits.
+type Strategy interface {
+	Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (worker, reason string)
+}
+
+const defaultStrategy = "ai"
+
+var strategies = map[string]func() Strategy{
+	"round-robin":     func() Strategy { return &RoundRobin{} },
+	"least-loaded":    func() Strategy { return LeastLoaded{} },
+	"two-choices":     func() Strategy { return TwoChoices{} },
+	"consistent-hash": func() Strategy { return &ConsistentHash{Replicas: 100} },
+	"weighted":        func() Strategy { return &Weighted{} },
+	"ai":              func() Strategy { return &AIAssisted{Fallback: LeastLoaded{}} },
+}
+
+// NewStrategy returns a fresh instance of a built-in strategy by name.
+func NewStrategy(name string) (Strategy, error) {
+	newFn, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduling strategy %q", name)
+	}
+	return newFn(), nil
+}
+
+// ParseStrategies reads "type:strategy" pairs, comma separated, e.g.
+// "report:consistent-hash,video:least-loaded".
+func ParseStrategies(spec string) (map[string]Strategy, error) {
+	byType := make(map[string]Strategy)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		taskType, name, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid strategy entry %q, want type:strategy", entry)
+		}
+		s, err := NewStrategy(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		byType[strings.TrimSpace(taskType)] = s
+	}
+	return byType, nil
+}
+
+// RoundRobin cycles through the candidates in order.
+type RoundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (r *RoundRobin) Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (string, string) {
+	if len(candidates) == 0 {
+		return "", "none"
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := candidates[r.next%len(candidates)]
+	r.next = (r.next + 1) % len(candidates)
+	return c.ID, "round-robin"
+}
+
+// LeastLoaded picks the candidate with the shortest queue.
+type LeastLoaded struct{}
+
+func (LeastLoaded) Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (string, string) {
+	if len(candidates) == 0 {
+		return "", "none"
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.QueueLen < best.QueueLen {
+			best = c
+		}
+	}
+	return best.ID, "least-loaded"
+}
+
+// TwoChoices samples two random candidates and keeps the less loaded one,
+// which spreads load almost as well as LeastLoaded without herding every
+// task onto the same idle worker.
+type TwoChoices struct{}
+
+func (TwoChoices) Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (string, string) {
+	if len(candidates) == 0 {
+		return "", "none"
+	}
+	a := candidates[rand.Intn(len(candidates))]
+	b := candidates[rand.Intn(len(candidates))]
+	if b.QueueLen < a.QueueLen {
+		a = b
+	}
+	return a.ID, "two-choices"
+}
+
+// ConsistentHash sends tasks with the same key to the same worker for as
+// long as it stays in the pool, and moves only a fraction of keys when
+// workers join or leave. The key is Task.Key, or the task ID if unset.
+type ConsistentHash struct {
+	Replicas int
+
+	mu      sync.Mutex
+	members string
+	ring    []uint32
+	owners  map[uint32]string
+}
+
+func (h *ConsistentHash) Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (string, string) {
+	if len(candidates) == 0 {
+		return "", "none"
+	}
+	key := task.Key
+	if key == "" {
+		key = task.ID
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.rebuild(candidates)
+	sum := hashKey(key)
+	i := sort.Search(len(h.ring), func(i int) bool { return h.ring[i] >= sum })
+	if i == len(h.ring) {
+		i = 0
+	}
+	return h.owners[h.ring[i]], "consistent-hash"
+}
+
+// rebuild recomputes the ring when the candidate set changed.
+func (h *ConsistentHash) rebuild(candidates []Candidate) {
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	sort.Strings(ids)
+	members := strings.Join(ids, ",")
+	if members == h.members {
+		return
+	}
+
+	replicas := h.Replicas
+	if replicas <= 0 {
+		replicas = 1
+	}
+	h.members = members
+	h.ring = make([]uint32, 0, len(ids)*replicas)
+	h.owners = make(map[uint32]string, len(ids)*replicas)
+	for _, id := range ids {
+		for r := 0; r < replicas; r++ {
+			sum := hashKey(id + "#" + strconv.Itoa(r))
+			h.ring = append(h.ring, sum)
+			h.owners[sum] = id
+		}
+	}
+	sort.Slice(h.ring, func(i, j int) bool { return h.ring[i] < h.ring[j] })
+}
+
+func hashKey(key string) uint32 {
+	f := fnv.New32a()
+	f.Write([]byte(key))
+	return f.Sum32()
+}
+
+// Weighted spreads tasks in proportion to the weight each worker publishes,
+// using smooth weighted round-robin. Workers without a weight count as 1.
+type Weighted struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func (w *Weighted) Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (string, string) {
+	if len(candidates) == 0 {
+		return "", "none"
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.current == nil {
+		w.current = make(map[string]int)
+	}
+
+	total := 0
+	best := ""
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		weight := c.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		w.current[c.ID] += weight
+		seen[c.ID] = true
+		if best == "" || w.current[c.ID] > w.current[best] {
+			best = c.ID
+		}
+	}
+	w.current[best] -= total
+
+	for id := range w.current {
+		if !seen[id] {
+			delete(w.current, id)
+		}
+	}
+	return best, "weighted"
+}
+
+// AIAssisted follows the AI service's recommended worker when it is one of
+// the candidates and the recommendation is confident enough, and defers to
+// Fallback otherwise.
+type AIAssisted struct {
+	// MinConfidence defaults to configs.Config.AIMinConfidence when zero.
+	MinConfidence float64
+	Fallback      Strategy
+}
+
+func (a *AIAssisted) Pick(task *models.Task, prediction *pb.PredictResponse, candidates []Candidate) (string, string) {
+	rec := prediction.RecommendedWorker
+	if rec != "" && hasCandidate(candidates, rec) {
+		if float64(prediction.WorkerConfidence) >= a.minConfidence() {
+			return rec, "ai"
+		}
+	}
+	return a.Fallback.Pick(task, prediction, candidates)
+}
+
+func (a *AIAssisted) minConfidence() float64 {
+	if a.MinConfidence > 0 {
+		return a.MinConfidence
+	}
+	return configs.Config.AIMinConfidence
+}
+
+func hasCandidate(candidates []Candidate, id string) bool {
+	for _, c := range candidates {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
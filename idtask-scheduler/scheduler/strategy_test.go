@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"testing"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+var candidates = []Candidate{
+	{ID: "w1", QueueLen: 5, Weight: 1},
+	{ID: "w2", QueueLen: 1, Weight: 3},
+	{ID: "w3", QueueLen: 9},
+}
+
+func TestStrategiesWithoutCandidates(t *testing.T) {
+	for name := range strategies {
+		s, err := NewStrategy(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if worker, _ := s.Pick(&models.Task{ID: "t1"}, &pb.PredictResponse{}, nil); worker != "" {
+			t.Errorf("%s picked %q from no candidates", name, worker)
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	s := &RoundRobin{}
+	var got []string
+	for i := 0; i < 4; i++ {
+		worker, _ := s.Pick(&models.Task{}, &pb.PredictResponse{}, candidates)
+		got = append(got, worker)
+	}
+	want := []string{"w1", "w2", "w3", "w1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	worker, reason := LeastLoaded{}.Pick(&models.Task{}, &pb.PredictResponse{}, candidates)
+	if worker != "w2" || reason != "least-loaded" {
+		t.Errorf("got %s (%s), want w2", worker, reason)
+	}
+}
+
+func TestTwoChoices(t *testing.T) {
+	// the busy worker only wins when it is sampled twice, a quarter of the time
+	pair := []Candidate{{ID: "idle", QueueLen: 0}, {ID: "busy", QueueLen: 10}}
+	idle := 0
+	for i := 0; i < 1000; i++ {
+		if worker, _ := (TwoChoices{}).Pick(&models.Task{}, &pb.PredictResponse{}, pair); worker == "idle" {
+			idle++
+		}
+	}
+	if idle < 650 {
+		t.Errorf("idle worker picked %d times out of 1000, want about 750", idle)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	h := &ConsistentHash{Replicas: 100}
+	task := &models.Task{ID: "t1", Key: "customer-42"}
+
+	first, _ := h.Pick(task, &pb.PredictResponse{}, candidates)
+	for i := 0; i < 10; i++ {
+		if worker, _ := h.Pick(task, &pb.PredictResponse{}, candidates); worker != first {
+			t.Fatalf("same key went to %s, then %s", first, worker)
+		}
+	}
+
+	// removing a worker other than the owner keeps the key in place
+	var rest []Candidate
+	dropped := false
+	for _, c := range candidates {
+		if c.ID != first && !dropped {
+			dropped = true
+			continue
+		}
+		rest = append(rest, c)
+	}
+	if worker, _ := h.Pick(task, &pb.PredictResponse{}, rest); worker != first {
+		t.Errorf("key moved from %s to %s after another worker left", first, worker)
+	}
+
+	// without a key the task ID is hashed
+	byID, _ := h.Pick(&models.Task{ID: "customer-42"}, &pb.PredictResponse{}, candidates)
+	if byID != first {
+		t.Errorf("ID fallback picked %s, key picked %s", byID, first)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	w := &Weighted{}
+	counts := make(map[string]int)
+	// total weight is 5, so every 5 picks follow the weights exactly
+	for i := 0; i < 50; i++ {
+		worker, _ := w.Pick(&models.Task{}, &pb.PredictResponse{}, candidates)
+		counts[worker]++
+	}
+	want := map[string]int{"w1": 10, "w2": 30, "w3": 10}
+	for id, n := range want {
+		if counts[id] != n {
+			t.Errorf("%s got %d tasks, want %d (%v)", id, counts[id], n, counts)
+		}
+	}
+}
+
+func TestAIAssisted(t *testing.T) {
+	a := &AIAssisted{MinConfidence: 0.5, Fallback: LeastLoaded{}}
+	tests := []struct {
+		name       string
+		prediction *pb.PredictResponse
+		worker     string
+		reason     string
+	}{
+		{"confident", &pb.PredictResponse{RecommendedWorker: "w3", WorkerConfidence: 0.9}, "w3", "ai"},
+		{"not confident", &pb.PredictResponse{RecommendedWorker: "w3", WorkerConfidence: 0.2}, "w2", "least-loaded"},
+		{"not a candidate", &pb.PredictResponse{RecommendedWorker: "gone", WorkerConfidence: 0.9}, "w2", "least-loaded"},
+		{"no recommendation", &pb.PredictResponse{}, "w2", "least-loaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker, reason := a.Pick(&models.Task{}, tt.prediction, candidates)
+			if worker != tt.worker || reason != tt.reason {
+				t.Errorf("got %s (%s), want %s (%s)", worker, reason, tt.worker, tt.reason)
+			}
+		})
+	}
+}
+
+func TestParseStrategies(t *testing.T) {
+	byType, err := ParseStrategies(" report:consistent-hash, video:least-loaded ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := byType["report"].(*ConsistentHash); !ok {
+		t.Errorf("report: got %T", byType["report"])
+	}
+	if _, ok := byType["video"].(LeastLoaded); !ok {
+		t.Errorf("video: got %T", byType["video"])
+	}
+
+	for _, spec := range []string{"report", "report:fastest"} {
+		if _, err := ParseStrategies(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/internal/limits"
+	"github.com/JamesDante/idtask-scheduler/internal/shard"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/go-redis/redis/v8"
+)
+
+// concurrencyRetry is how often a scope at its concurrency cap is retried,
+// slots free up when workers finish and nothing tells the scheduler.
+const concurrencyRetry = 500 * time.Millisecond
+
+// releaseThrottledScript moves every task of a throttled scope back to the
+// head of its tenant's queue, oldest first, unless the fence rejects the
+// token. KEYS: fence, throttled queue, tenant queue, scopes set. ARGV:
+// token, member. Returns the number of tasks moved, -1 when fenced off.
+var releaseThrottledScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[1]) or '0')
+if fence > tonumber(ARGV[1]) then
+	return -1
+end
+local items = redis.call('LRANGE', KEYS[2], 0, -1)
+for i = 1, #items do
+	redis.call('RPUSH', KEYS[3], items[i])
+end
+redis.call('DEL', KEYS[2])
+redis.call('ZREM', KEYS[4], ARGV[2])
+return #items
+`)
+
+// holdThrottledScript moves a task from the processing queue to the back
+// of its scope's throttled queue and, with a due time above zero, schedules
+// the scope for release, unless the fence rejects the token. KEYS: fence,
+// throttled queue, processing queue, scopes set. ARGV: token, task, due
+// (ms), throttled member. Returns 0 when fenced off.
+var holdThrottledScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[1]) or '0')
+if fence > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('LPUSH', KEYS[2], ARGV[2])
+redis.call('LREM', KEYS[3], 1, ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call('ZADD', KEYS[4], ARGV[3], ARGV[4])
+end
+return 1
+`)
+
+// throttle holds the task back when its type is over a TASK_LIMITS limit
+// and reports whether it did. Tasks of a scope that already has tasks
+// waiting line up behind them, so every scope keeps its order. Allowed
+// tasks with a concurrency cap carry the slot to release in LimitKey.
+func (r *shardRunner) throttle(task *models.Task, res string) bool {
+	s := r.s
+	lim, ok := s.limits.For(task)
+	if !ok {
+		return false
+	}
+	scope := limits.Scope(lim, task)
+	queue := shard.ThrottledQueue(r.id, throttledMember(task, scope))
+
+	if n, err := s.rdb.Exists(s.ctx, queue).Result(); err == nil && n > 0 {
+		r.holdThrottled(task, res, scope, "queued", 0)
+		return true
+	}
+
+	d, err := s.limits.Acquire(s.ctx, lim, scope, task.ID)
+	if err != nil {
+		// limits are best effort, a Redis hiccup should not stall the queue
+		log.Printf("⚠️ Failed to check limits for task %s, dispatching: %v", task.ID, err)
+		return false
+	}
+	if !d.Allowed {
+		retry := d.RetryAfter
+		if d.Reason == "concurrency" {
+			retry = concurrencyRetry
+		}
+		r.holdThrottled(task, res, scope, d.Reason, retry)
+		return true
+	}
+
+	if lim.Concurrency > 0 {
+		task.LimitKey = limits.RunningKey(scope)
+	}
+	return false
+}
+
+// throttledMember names the throttled queue of a scope. Each tenant has
+// its own, so released tasks go back to their tenant's queue.
+func throttledMember(task *models.Task, scope string) string {
+	return models.TenantOf(task) + "/" + scope
+}
+
+// holdThrottled parks the task at the back of its scope's throttled queue.
+// A retry above zero (re)schedules when the scope is released, in the same
+// fenced step so a parked task is never left without a release.
+func (r *shardRunner) holdThrottled(task *models.Task, res, scope, reason string, retry time.Duration) {
+	s := r.s
+	member := throttledMember(task, scope)
+	var at int64
+	if retry > 0 {
+		at = time.Now().Add(retry).UnixMilli()
+	}
+	keys := []string{shard.FenceKey(r.id), shard.ThrottledQueue(r.id, member), r.processingQueue, shard.ThrottledScopes(r.id)}
+	ok, err := holdThrottledScript.Run(s.ctx, s.rdb, keys, r.token, res, at, member).Int()
+	if err != nil {
+		log.Printf("[limits] Failed to hold task %s: %v", task.ID, err)
+		return
+	}
+	if ok == 0 {
+		r.stale("redis")
+		return
+	}
+	monitor.SchedulerTasksThrottled().WithLabelValues(task.Type, reason).Inc()
+	log.Printf("[limits] Task %s throttled (%s, scope %s)", task.ID, reason, scope)
+}
+
+// releaseThrottled returns the tasks of every scope due for a retry to the
+// head of their tenant's queue, where they are checked against their
+// limits again before anything queued after them.
+func (r *shardRunner) releaseThrottled(ctx context.Context) {
+	s := r.s
+	scopesKey := shard.ThrottledScopes(r.id)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	due, err := s.rdb.ZRangeByScore(ctx, scopesKey, &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil {
+		return
+	}
+
+	for _, member := range due {
+		tenant, _, _ := strings.Cut(member, "/")
+		keys := []string{shard.FenceKey(r.id), shard.ThrottledQueue(r.id, member), shard.TenantQueue(r.id, tenant), scopesKey}
+		moved, err := releaseThrottledScript.Run(ctx, s.rdb, keys, r.token, member).Int()
+		if err != nil {
+			log.Printf("[limits] Failed to release scope %s: %v", member, err)
+			continue
+		}
+		if moved < 0 {
+			r.stale("redis")
+			return
+		}
+	}
+
+	if n, err := s.rdb.ZCard(ctx, scopesKey).Result(); err == nil {
+		monitor.SchedulerThrottledScopes().WithLabelValues(fmt.Sprint(r.id)).Set(float64(n))
+	}
+}
+
+// untilThrottledDue is how long until the next throttled scope is due, or
+// a second if none is.
+func (r *shardRunner) untilThrottledDue(ctx context.Context) time.Duration {
+	next, err := r.s.rdb.ZRangeWithScores(ctx, shard.ThrottledScopes(r.id), 0, 0).Result()
+	if err != nil || len(next) == 0 {
+		return time.Second
+	}
+	wait := time.Until(time.UnixMilli(int64(next[0].Score)))
+	if wait < 0 {
+		return 0
+	}
+	if wait > time.Second {
+		return time.Second
+	}
+	return wait
+}
@@ -1,4 +1,4 @@
-package main
+package scheduler
 
 import (
 	"context"
@@ -16,6 +16,7 @@ type WorkerWatcher struct {
 	OnAdd     func(worker models.WorkerStatus)
 	OnDelete  func(worker models.WorkerStatus)
 	CancelCtx context.CancelFunc
+	done      chan struct{}
 }
 
 func NewWorkerWatcher(cli *clientv3.Client, prefix string) (*WorkerWatcher, error) {
@@ -37,8 +38,10 @@ func NewWorkerWatcher(cli *clientv3.Client, prefix string) (*WorkerWatcher, erro
 func (w *WorkerWatcher) Start() {
 	ctx, cancel := context.WithCancel(context.Background())
 	w.CancelCtx = cancel
+	w.done = make(chan struct{})
 
 	go func() {
+		defer close(w.done)
 		log.Println("[watcher] start watching workers...")
 		rch := w.Client.Watch(ctx, w.Prefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
 		for resp := range rch {
@@ -68,9 +71,11 @@ func (w *WorkerWatcher) Start() {
 	}()
 }
 
+// Stop ends the watch and waits for the last event to be handled. The
+// client is shared with the rest of the scheduler and stays open.
 func (w *WorkerWatcher) Stop() {
 	if w.CancelCtx != nil {
 		w.CancelCtx()
+		<-w.done
 	}
-	w.Client.Close()
 }
@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/jsonpath"
 	"github.com/JamesDante/idtask-scheduler/models"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -12,6 +17,11 @@ import (
 
 var db *sqlx.DB
 
+// PostgresStore keeps tasks and task logs in PostgreSQL.
+type PostgresStore struct {
+	db *sqlx.DB
+}
+
 func Init() {
 	var err error
 	db, err = sqlx.Connect("postgres", configs.Config.PostgresConnectString)
@@ -24,6 +34,8 @@ func Init() {
 	db.SetConnMaxLifetime(time.Minute * 5)
 
 	createTables()
+
+	Use(&PostgresStore{db: db})
 }
 
 func GetDB() *sqlx.DB {
@@ -35,7 +47,7 @@ func createTables() {
 	CREATE TABLE IF NOT EXISTS tasks (
 		id TEXT PRIMARY KEY,
 		type TEXT,
-		payload TEXT,
+		payload JSONB,
 		status TEXT,
 		retries  INT,
 		max_retry INT,
@@ -52,53 +64,224 @@ func createTables() {
 		executed_by TEXT NOT NULL,
 		executed_at TIMESTAMP DEFAULT now()
 	);
+
+	CREATE TABLE IF NOT EXISTS prediction_outcomes (
+		id SERIAL PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		task_type TEXT,
+		predicted_priority INT,
+		predicted_time DOUBLE PRECISION,
+		predicted_worker TEXT,
+		actual_time DOUBLE PRECISION,
+		actual_worker TEXT,
+		success BOOLEAN,
+		created_at TIMESTAMP DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS scheduling_decisions (
+		id SERIAL PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		worker TEXT,
+		reason TEXT,
+		model_version TEXT,
+		recommended_worker TEXT,
+		worker_confidence DOUBLE PRECISION,
+		fencing_token BIGINT,
+		decided_at TIMESTAMP DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS predictor_predictions (
+		id SERIAL PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		predictor TEXT NOT NULL,
+		role TEXT NOT NULL,
+		priority INT,
+		estimated_time DOUBLE PRECISION,
+		recommended_worker TEXT,
+		model_version TEXT,
+		created_at TIMESTAMP DEFAULT now()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_predictor_predictions_task_id ON predictor_predictions(task_id);
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		roles JSONB,
+		created_by TEXT,
+		created_at TIMESTAMP DEFAULT now(),
+		revoked_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		principal TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT,
+		params JSONB,
+		outcome TEXT NOT NULL,
+		status INT,
+		at TIMESTAMP DEFAULT now()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log(at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target);
+
+	CREATE TABLE IF NOT EXISTS task_schemas (
+		type TEXT NOT NULL,
+		version INT NOT NULL,
+		schema JSONB NOT NULL,
+		created_by TEXT,
+		created_at TIMESTAMP DEFAULT now(),
+		PRIMARY KEY (type, version)
+	);
 	`
 
 	db.MustExec(schema)
 
-	_, err := db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP;`)
+	// the audit log is append-only, even for clients bypassing the API
+	_, err := db.Exec(`
+	CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_log_append_only') THEN
+			CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+			FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+		END IF;
+	END $$;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to make audit_log append-only: %v", err)
+	}
+
+	_, err = db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP;`)
 	if err != nil {
 		log.Printf("⚠️ Failed to ensure 'scheduled_at' column: %v", err)
 	}
-}
 
-func CreateTask(t *models.Task) (time.Time, error) {
-	var createdAt time.Time
-	err := db.QueryRowx(
-		"INSERT INTO tasks(id, type, payload, status, expire_at) VALUES($1, $2, $3, $4, $5) RETURNING created_at",
-		t.ID, t.Type, t.Payload, t.Status, t.ExpireAt,
-	).Scan(&createdAt)
-	return createdAt, err
-}
+	_, err = db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS task_key TEXT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure 'task_key' column: %v", err)
+	}
 
-func GetTasksCount() int {
-	var total int
-	_ = db.Get(&total, "SELECT COUNT(*) FROM tasks")
+	_, err = db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS required_labels JSONB, ADD COLUMN IF NOT EXISTS preferred_labels JSONB;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure label columns: %v", err)
+	}
 
-	return total
-}
+	_, err = db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS tenant TEXT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure 'tenant' column: %v", err)
+	}
 
-func GetTasks(req *models.APIListRequest) ([]models.Task, error) {
-	if req.Page <= 0 {
-		req.Page = 1
+	_, err = db.Exec(`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS roles JSONB;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure api_keys 'roles' column: %v", err)
 	}
-	if req.PageSize <= 0 {
-		req.PageSize = 10
+
+	_, err = db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS created_by TEXT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure 'created_by' column: %v", err)
 	}
-	offset := (req.Page - 1) * req.PageSize
 
-	tasks := []models.Task{}
-	err := db.Select(&tasks, `
+	_, err = db.Exec(`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS fencing_token BIGINT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure 'fencing_token' column: %v", err)
+	}
+
+	_, err = db.Exec(`ALTER TABLE scheduling_decisions ADD COLUMN IF NOT EXISTS fencing_token BIGINT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure scheduling_decisions 'fencing_token' column: %v", err)
+	}
+
+	_, err = db.Exec(`ALTER TABLE task_logs ADD COLUMN IF NOT EXISTS duration_ms BIGINT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure 'duration_ms' column: %v", err)
+	}
+
+	_, err = db.Exec(`ALTER TABLE prediction_outcomes ADD COLUMN IF NOT EXISTS model_version TEXT;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to ensure 'model_version' column: %v", err)
+	}
+
+	// payloads used to be TEXT holding JSON, or plain text before that was
+	// enforced, which becomes a JSON string. Clients that encoded their JSON
+	// twice stored a JSON string holding the document, which is unwrapped.
+	_, err = db.Exec(`
+	CREATE OR REPLACE FUNCTION payload_to_jsonb(p TEXT) RETURNS JSONB AS $$
+	DECLARE
+		j JSONB;
+	BEGIN
+		BEGIN
+			j := p::jsonb;
+		EXCEPTION WHEN others THEN
+			RETURN to_jsonb(p);
+		END;
+		IF jsonb_typeof(j) = 'string' THEN
+			BEGIN
+				RETURN (j #>> '{}')::jsonb;
+			EXCEPTION WHEN others THEN
+				RETURN j;
+			END;
+		END IF;
+		RETURN j;
+	END;
+	$$ LANGUAGE plpgsql IMMUTABLE;
+
+	DO $$
+	BEGIN
+		IF (SELECT data_type FROM information_schema.columns
+		    WHERE table_name = 'tasks' AND column_name = 'payload') = 'text' THEN
+			ALTER TABLE tasks ALTER COLUMN payload TYPE JSONB USING payload_to_jsonb(payload);
+		END IF;
+	END $$;`)
+	if err != nil {
+		log.Printf("⚠️ Failed to convert 'payload' column to JSONB: %v", err)
+	}
+}
+
+// jsonbArg passes raw JSON to a JSONB parameter, as text since lib/pq
+// sends []byte as bytea. Empty is NULL.
+func jsonbArg(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func (s *PostgresStore) CreateTask(t *models.Task) (time.Time, error) {
+	var createdAt time.Time
+	err := s.db.QueryRowx(
+		`INSERT INTO tasks(id, type, payload, status, expire_at, task_key, required_labels, preferred_labels, tenant, created_by)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING created_at`,
+		t.ID, t.Type, jsonbArg(t.Payload), t.Status, t.ExpireAt, t.Key, t.RequiredLabels, t.PreferredLabels, t.Tenant, t.CreatedBy,
+	).Scan(&createdAt)
+	return createdAt, err
+}
+
+// taskSelect reads tasks with their latest execution, from tasks t.
+const taskSelect = `
 		SELECT 
 		  t.id,
 		  t.type,
-		  t.payload,
+		  COALESCE(t.payload, 'null') AS payload,
 		  t.status,
 		  t.retries,
 		  t.max_retry,
 		  t.priority,
 		  t.expire_at,
 		  t.created_at,
+		  COALESCE(t.task_key, '') AS task_key,
+		  t.required_labels,
+		  t.preferred_labels,
+		  COALESCE(t.tenant, '') AS tenant,
+		  COALESCE(t.created_by, '') AS created_by,
 		  l.executed_by,
 		  l.executed_at
 		FROM tasks t
@@ -107,8 +290,89 @@ func GetTasks(req *models.APIListRequest) ([]models.Task, error) {
 		  WHERE l.task_id = t.id
 		  ORDER BY l.executed_at DESC
 		  LIMIT 1
-		) l ON true
-		ORDER BY t.created_at DESC LIMIT $1 OFFSET $2;`, req.PageSize, offset)
+		) l ON true`
+
+func (s *PostgresStore) GetTask(id string) (*models.Task, error) {
+	var t models.Task
+	err := s.db.Get(&t, taskSelect+` WHERE t.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *PostgresStore) CancelTask(id string) (*models.Task, error) {
+	res, err := s.db.Exec(`
+		UPDATE tasks SET status = 'Cancelled'
+		WHERE id = $1 AND COALESCE(status, '') NOT IN ('Completed', 'Failed', 'Expired', 'Cancelled')`, id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return t, ErrTaskFinished
+	}
+	return t, nil
+}
+
+func (s *PostgresStore) RequeueTask(id string) (*models.Task, error) {
+	res, err := s.db.Exec(`
+		UPDATE tasks SET status = 'Pending', retries = COALESCE(retries, 0) + 1,
+		  expire_at = GREATEST(expire_at, NOW() + INTERVAL '1 day')
+		WHERE id = $1 AND status = 'Failed'`, id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return t, ErrTaskNotFailed
+	}
+	return t, nil
+}
+
+func (s *PostgresStore) GetTasksCount(req *models.APIListRequest) int {
+	where, args := taskFilter(req)
+	var total int
+	_ = s.db.Get(&total, "SELECT COUNT(*) FROM tasks t WHERE "+where, args...)
+
+	return total
+}
+
+// taskFilter is the WHERE clause selecting req's tasks from tasks t. A
+// payload filter that is a path must select something (@?), one that is
+// a predicate must be true (@@).
+func taskFilter(req *models.APIListRequest) (string, []interface{}) {
+	where := []string{`($1 = '' OR COALESCE(NULLIF(t.tenant, ''), 'default') = $1)`}
+	args := []interface{}{req.Tenant}
+	for _, f := range req.PayloadFilters {
+		op := "@?"
+		if e, err := jsonpath.Compile(f); err == nil && e.Predicate() {
+			op = "@@"
+		}
+		args = append(args, f)
+		where = append(where, fmt.Sprintf("t.payload %s $%d::jsonpath", op, len(args)))
+	}
+	return strings.Join(where, " AND "), args
+}
+
+func (s *PostgresStore) GetTasks(req *models.APIListRequest) ([]models.Task, error) {
+	offset := (req.Page - 1) * req.PageSize
+
+	where, args := taskFilter(req)
+	args = append(args, req.PageSize, offset)
+	tasks := []models.Task{}
+	err := s.db.Select(&tasks, taskSelect+fmt.Sprintf(`
+		WHERE %s
+		ORDER BY t.created_at DESC LIMIT $%d OFFSET $%d;`, where, len(args)-1, len(args)), args...)
 
 	if err != nil {
 		log.Printf("Failed to query tasks: %v", err)
@@ -118,24 +382,284 @@ func GetTasks(req *models.APIListRequest) ([]models.Task, error) {
 	return tasks, nil
 }
 
-func UpdateTasks(taskID, status string) {
-	if db == nil {
+func (s *PostgresStore) UpdateTasks(taskID, status string) {
+	if s.db == nil {
 		log.Println("⚠️ Database connection is not initialized")
 		return
 	}
 
-	_, err := db.Exec(`UPDATE tasks SET status = $1 WHERE id = $2;`, status, taskID)
+	_, err := s.db.Exec(`UPDATE tasks SET status = $1 WHERE id = $2;`, status, taskID)
 	if err != nil {
 		log.Printf("⚠️ Failed to update task execution: %v\n", err)
 	}
 }
 
-func CreateTaskLogs(taskID, executedBy, result string) {
-	_, err := db.Exec(`
-        INSERT INTO task_logs (task_id, executed_by, result)
-        VALUES ($1, $2, $3)
-    `, taskID, executedBy, result)
+func (s *PostgresStore) UpdateTasksFenced(taskID, status string, token int64) error {
+	res, err := s.db.Exec(`
+		UPDATE tasks SET status = $1, fencing_token = $3
+		WHERE id = $2 AND COALESCE(fencing_token, 0) <= $3;`, status, taskID, token)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		if err := s.db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM tasks WHERE id = $1)`, taskID); err == nil && exists {
+			return ErrStaleToken
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStore) CreateTaskLogs(taskID, executedBy, result string, duration time.Duration) {
+	_, err := s.db.Exec(`
+        INSERT INTO task_logs (task_id, executed_by, result, duration_ms)
+        VALUES ($1, $2, $3, $4)
+    `, taskID, executedBy, result, duration.Milliseconds())
 	if err != nil {
 		log.Printf("⚠️ Failed to log task execution: %v\n", err)
 	}
 }
+
+func (s *PostgresStore) GetTaskTypeStats() (map[string]models.TaskTypeStats, error) {
+	rows := []models.TaskTypeStats{}
+	err := s.db.Select(&rows, `
+		SELECT
+		  t.type,
+		  AVG(l.duration_ms) / 1000.0 AS avg_duration_seconds,
+		  COALESCE(AVG(t.priority), 0) AS avg_priority,
+		  COUNT(*) AS samples
+		FROM task_logs l
+		JOIN tasks t ON t.id = l.task_id
+		WHERE l.duration_ms IS NOT NULL
+		GROUP BY t.type;`)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]models.TaskTypeStats, len(rows))
+	for _, r := range rows {
+		stats[r.Type] = r
+	}
+	return stats, nil
+}
+
+func (s *PostgresStore) CreatePredictionOutcome(o *models.PredictionOutcome) error {
+	_, err := s.db.Exec(`
+		INSERT INTO prediction_outcomes
+		  (task_id, task_type, predicted_priority, predicted_time, predicted_worker, model_version, actual_time, actual_worker, success)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, o.TaskID, o.TaskType, o.PredictedPriority, o.PredictedTime, o.PredictedWorker, o.ModelVersion, o.ActualTime, o.ActualWorker, o.Success)
+	return err
+}
+
+func (s *PostgresStore) CreateSchedulingDecision(d *models.SchedulingDecision) error {
+	_, err := s.db.Exec(`
+		INSERT INTO scheduling_decisions
+		  (task_id, worker, reason, model_version, recommended_worker, worker_confidence, fencing_token)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, d.TaskID, d.Worker, d.Reason, d.ModelVersion, d.RecommendedWorker, d.WorkerConfidence, d.FencingToken)
+	return err
+}
+
+func (s *PostgresStore) CreatePredictorPrediction(p *models.PredictorPrediction) error {
+	_, err := s.db.Exec(`
+		INSERT INTO predictor_predictions
+		  (task_id, predictor, role, priority, estimated_time, recommended_worker, model_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, p.TaskID, p.Predictor, p.Role, p.Priority, p.EstimatedTime, p.RecommendedWorker, p.ModelVersion)
+	return err
+}
+
+func (s *PostgresStore) GetPredictorPredictions(taskID string) ([]models.PredictorPrediction, error) {
+	var preds []models.PredictorPrediction
+	err := s.db.Select(&preds, `
+		SELECT id, task_id, predictor, role, priority, estimated_time,
+		       COALESCE(recommended_worker, '') AS recommended_worker,
+		       COALESCE(model_version, '') AS model_version, created_at
+		FROM predictor_predictions
+		WHERE task_id = $1
+		ORDER BY id
+	`, taskID)
+	return preds, err
+}
+
+func (s *PostgresStore) CreateAPIKey(k *models.APIKey) error {
+	return s.db.QueryRowx(`
+		INSERT INTO api_keys (id, name, subject, key_hash, roles, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at
+	`, k.ID, k.Name, k.Subject, k.Hash, k.Roles, k.CreatedBy).Scan(&k.CreatedAt)
+}
+
+const apiKeyColumns = `id, name, subject, key_hash, roles, COALESCE(created_by, '') AS created_by, created_at, revoked_at`
+
+func (s *PostgresStore) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	var k models.APIKey
+	err := s.db.Get(&k, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (s *PostgresStore) ListAPIKeys() ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := s.db.Select(&keys, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	return keys, err
+}
+
+func (s *PostgresStore) RotateAPIKey(id string, next *models.APIKey) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old models.APIKey
+	err = tx.Get(&old, `
+		UPDATE api_keys SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	next.Name, next.Subject, next.Roles = old.Name, old.Subject, old.Roles
+	err = tx.QueryRowx(`
+		INSERT INTO api_keys (id, name, subject, key_hash, roles, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at
+	`, next.ID, next.Name, next.Subject, next.Hash, next.Roles, next.CreatedBy).Scan(&next.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) RevokeAPIKey(id string) error {
+	res, err := s.db.Exec(`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) CreateAuditEvent(e *models.AuditEvent) error {
+	var params interface{}
+	if len(e.Params) > 0 {
+		params = string(e.Params)
+	}
+	return s.db.QueryRowx(`
+		INSERT INTO audit_log (principal, action, target, params, outcome, status)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, at
+	`, e.Principal, e.Action, e.Target, params, e.Outcome, e.Status).Scan(&e.ID, &e.At)
+}
+
+func (s *PostgresStore) GetAuditEventsCount(q *models.AuditQuery) int {
+	where, args := auditFilter(q)
+	var total int
+	_ = s.db.Get(&total, "SELECT COUNT(*) FROM audit_log WHERE "+where, args...)
+	return total
+}
+
+func (s *PostgresStore) GetAuditEvents(q *models.AuditQuery) ([]models.AuditEvent, error) {
+	where, args := auditFilter(q)
+	args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+
+	events := []models.AuditEvent{}
+	err := s.db.Select(&events, fmt.Sprintf(`
+		SELECT id, principal, action, COALESCE(target, '') AS target, COALESCE(params, '{}') AS params, outcome,
+		       COALESCE(status, 0) AS status, at
+		FROM audit_log
+		WHERE %s
+		ORDER BY id DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	return events, err
+}
+
+// auditFilter returns the WHERE condition matching q and its arguments.
+func auditFilter(q *models.AuditQuery) (string, []interface{}) {
+	where := []string{"TRUE"}
+	args := []interface{}{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if q.Principal != "" {
+		add("principal = $%d", q.Principal)
+	}
+	if q.Action != "" {
+		add("action = $%d", q.Action)
+	}
+	if q.Target != "" {
+		add("target = $%d", q.Target)
+	}
+	if q.Outcome != "" {
+		add("outcome = $%d", q.Outcome)
+	}
+	if q.Since != nil {
+		add("at >= $%d", *q.Since)
+	}
+	if q.Until != nil {
+		add("at < $%d", *q.Until)
+	}
+	return strings.Join(where, " AND "), args
+}
+
+const taskSchemaColumns = `type, version, schema, COALESCE(created_by, '') AS created_by, created_at`
+
+func (s *PostgresStore) CreateTaskSchema(ts *models.TaskSchema) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// registrations of one type take turns until commit, so each one reads
+	// the version the previous one wrote
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('task_schemas'), hashtext($1))`, ts.Type); err != nil {
+		return err
+	}
+	err = tx.QueryRowx(`
+		INSERT INTO task_schemas (type, version, schema, created_by)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3 FROM task_schemas WHERE type = $1
+		RETURNING version, created_at
+	`, ts.Type, string(ts.Schema), ts.CreatedBy).Scan(&ts.Version, &ts.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) GetTaskSchema(taskType string, version int) (*models.TaskSchema, error) {
+	var ts models.TaskSchema
+	err := s.db.Get(&ts, `SELECT `+taskSchemaColumns+` FROM task_schemas
+		WHERE type = $1 AND ($2 = 0 OR version = $2)
+		ORDER BY version DESC LIMIT 1`, taskType, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+func (s *PostgresStore) ListTaskSchemas(taskType string) ([]models.TaskSchema, error) {
+	schemas := []models.TaskSchema{}
+	var err error
+	if taskType == "" {
+		err = s.db.Select(&schemas, `SELECT DISTINCT ON (type) `+taskSchemaColumns+` FROM task_schemas
+			ORDER BY type, version DESC`)
+	} else {
+		err = s.db.Select(&schemas, `SELECT `+taskSchemaColumns+` FROM task_schemas
+			WHERE type = $1 ORDER BY version DESC`, taskType)
+	}
+	return schemas, err
+}
@@ -0,0 +1,496 @@
+package storage
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/internal/jsonpath"
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// MemoryStore is an in-process Store used by the embedded mode and tests.
+// It mirrors the Postgres behaviour closely enough for full task flows.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	tasks     map[string]*models.Task
+	logs      []models.TaskLogs
+	outcomes  []models.PredictionOutcome
+	decisions []models.SchedulingDecision
+	preds     []models.PredictorPrediction
+	tokens    map[string]int64
+	keys      []*models.APIKey
+	audit     []models.AuditEvent
+	schemas   map[string][]models.TaskSchema
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks:   make(map[string]*models.Task),
+		tokens:  make(map[string]int64),
+		schemas: make(map[string][]models.TaskSchema),
+	}
+}
+
+func (s *MemoryStore) CreateTask(t *models.Task) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt := time.Now()
+	stored := *t
+	stored.CreatedAt = &createdAt
+	if !stored.Priority.Valid {
+		stored.Priority = sql.NullInt64{Int64: 0, Valid: true}
+	}
+	s.tasks[t.ID] = &stored
+	return createdAt, nil
+}
+
+func (s *MemoryStore) GetTasksCount(req *models.APIListRequest) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	match := listMatcher(req)
+	n := 0
+	for _, t := range s.tasks {
+		if match(t) {
+			n++
+		}
+	}
+	return n
+}
+
+// listMatcher reports whether a task is one of req's, as the Postgres
+// store's taskFilter selects them.
+func listMatcher(req *models.APIListRequest) func(t *models.Task) bool {
+	filters := make([]*jsonpath.Expr, 0, len(req.PayloadFilters))
+	for _, f := range req.PayloadFilters {
+		e, err := jsonpath.Compile(f)
+		if err != nil {
+			return func(*models.Task) bool { return false }
+		}
+		filters = append(filters, e)
+	}
+	return func(t *models.Task) bool {
+		if req.Tenant != "" && models.TenantOf(t) != req.Tenant {
+			return false
+		}
+		// no payload is a NULL column in Postgres, which no filter matches
+		if len(filters) > 0 && len(t.Payload) == 0 {
+			return false
+		}
+		for _, e := range filters {
+			if !e.Match(t.Payload) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (s *MemoryStore) GetTasks(req *models.APIListRequest) ([]models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	match := listMatcher(req)
+	tasks := make([]models.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		if !match(t) {
+			continue
+		}
+		task := *t
+		if l := s.latestLog(t.ID); l != nil {
+			task.ExecutedBy = sql.NullString{String: l.ExecutedBy, Valid: true}
+			task.ExecutedAt = l.ExecutedAt
+		}
+		tasks = append(tasks, task)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.After(*tasks[j].CreatedAt)
+	})
+
+	offset := (req.Page - 1) * req.PageSize
+	if offset >= len(tasks) {
+		return []models.Task{}, nil
+	}
+	end := offset + req.PageSize
+	if end > len(tasks) {
+		end = len(tasks)
+	}
+	return tasks[offset:end], nil
+}
+
+func (s *MemoryStore) UpdateTasks(taskID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tasks[taskID]; ok {
+		t.Status = status
+	}
+}
+
+func (s *MemoryStore) UpdateTasksFenced(taskID, status string, token int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[taskID]
+	if !ok {
+		return nil
+	}
+	if s.tokens[taskID] > token {
+		return ErrStaleToken
+	}
+	s.tokens[taskID] = token
+	t.Status = status
+	return nil
+}
+
+func (s *MemoryStore) CreateTaskLogs(taskID, executedBy, result string, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	executedAt := time.Now()
+	s.logs = append(s.logs, models.TaskLogs{
+		ID:         sql.NullInt64{Int64: int64(len(s.logs) + 1), Valid: true},
+		TaskID:     taskID,
+		ExecutedBy: executedBy,
+		Result:     result,
+		DurationMs: sql.NullInt64{Int64: duration.Milliseconds(), Valid: true},
+		ExecutedAt: &executedAt,
+	})
+}
+
+func (s *MemoryStore) GetTaskTypeStats() (map[string]models.TaskTypeStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type sums struct {
+		duration, priority float64
+		samples            int
+	}
+	byType := make(map[string]*sums)
+	for _, l := range s.logs {
+		t, ok := s.tasks[l.TaskID]
+		if !ok || !l.DurationMs.Valid {
+			continue
+		}
+		agg := byType[t.Type]
+		if agg == nil {
+			agg = &sums{}
+			byType[t.Type] = agg
+		}
+		agg.duration += float64(l.DurationMs.Int64) / 1000
+		agg.priority += float64(t.Priority.Int64)
+		agg.samples++
+	}
+
+	stats := make(map[string]models.TaskTypeStats, len(byType))
+	for taskType, agg := range byType {
+		stats[taskType] = models.TaskTypeStats{
+			Type:               taskType,
+			AvgDurationSeconds: agg.duration / float64(agg.samples),
+			AvgPriority:        agg.priority / float64(agg.samples),
+			Samples:            agg.samples,
+		}
+	}
+	return stats, nil
+}
+
+func (s *MemoryStore) CreatePredictionOutcome(o *models.PredictionOutcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt := time.Now()
+	stored := *o
+	stored.ID = sql.NullInt64{Int64: int64(len(s.outcomes) + 1), Valid: true}
+	stored.CreatedAt = &createdAt
+	s.outcomes = append(s.outcomes, stored)
+	return nil
+}
+
+func (s *MemoryStore) CreateSchedulingDecision(d *models.SchedulingDecision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decidedAt := time.Now()
+	stored := *d
+	stored.ID = sql.NullInt64{Int64: int64(len(s.decisions) + 1), Valid: true}
+	stored.DecidedAt = &decidedAt
+	s.decisions = append(s.decisions, stored)
+	return nil
+}
+
+func (s *MemoryStore) CreatePredictorPrediction(p *models.PredictorPrediction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt := time.Now()
+	stored := *p
+	stored.ID = sql.NullInt64{Int64: int64(len(s.preds) + 1), Valid: true}
+	stored.CreatedAt = &createdAt
+	s.preds = append(s.preds, stored)
+	return nil
+}
+
+func (s *MemoryStore) GetPredictorPredictions(taskID string) ([]models.PredictorPrediction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var preds []models.PredictorPrediction
+	for _, p := range s.preds {
+		if p.TaskID == taskID {
+			preds = append(preds, p)
+		}
+	}
+	return preds, nil
+}
+
+func (s *MemoryStore) CreateAPIKey(k *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.storeKey(k)
+	return nil
+}
+
+func (s *MemoryStore) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Hash == hash && k.RevokedAt == nil {
+			found := *k
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) ListAPIKeys() ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(s.keys))
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		keys = append(keys, *s.keys[i])
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) RotateAPIKey(id string, next *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.activeKey(id)
+	if old == nil {
+		return ErrNotFound
+	}
+	revokedAt := time.Now()
+	old.RevokedAt = &revokedAt
+	next.Name, next.Subject, next.Roles = old.Name, old.Subject, old.Roles
+	s.storeKey(next)
+	return nil
+}
+
+func (s *MemoryStore) RevokeAPIKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := s.activeKey(id)
+	if k == nil {
+		return ErrNotFound
+	}
+	revokedAt := time.Now()
+	k.RevokedAt = &revokedAt
+	return nil
+}
+
+func (s *MemoryStore) storeKey(k *models.APIKey) {
+	createdAt := time.Now()
+	k.CreatedAt = &createdAt
+	stored := *k
+	stored.Key = ""
+	s.keys = append(s.keys, &stored)
+}
+
+func (s *MemoryStore) activeKey(id string) *models.APIKey {
+	for _, k := range s.keys {
+		if k.ID == id && k.RevokedAt == nil {
+			return k
+		}
+	}
+	return nil
+}
+
+// PredictionOutcomes returns the recorded outcomes, oldest first, mainly
+// for test assertions.
+func (s *MemoryStore) PredictionOutcomes() []models.PredictionOutcome {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.PredictionOutcome(nil), s.outcomes...)
+}
+
+func (s *MemoryStore) CreateAuditEvent(e *models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := time.Now()
+	e.ID = int64(len(s.audit) + 1)
+	e.At = &at
+	s.audit = append(s.audit, *e)
+	return nil
+}
+
+func (s *MemoryStore) GetAuditEvents(q *models.AuditQuery) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := s.matchAudit(q)
+
+	offset := (q.Page - 1) * q.PageSize
+	if offset >= len(events) {
+		return []models.AuditEvent{}, nil
+	}
+	end := offset + q.PageSize
+	if end > len(events) {
+		end = len(events)
+	}
+	return events[offset:end], nil
+}
+
+func (s *MemoryStore) GetAuditEventsCount(q *models.AuditQuery) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.matchAudit(q))
+}
+
+// matchAudit returns the events matching q, newest first.
+func (s *MemoryStore) matchAudit(q *models.AuditQuery) []models.AuditEvent {
+	events := []models.AuditEvent{}
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		e := s.audit[i]
+		if (q.Principal != "" && e.Principal != q.Principal) ||
+			(q.Action != "" && e.Action != q.Action) ||
+			(q.Target != "" && e.Target != q.Target) ||
+			(q.Outcome != "" && e.Outcome != q.Outcome) ||
+			(q.Since != nil && e.At.Before(*q.Since)) ||
+			(q.Until != nil && !e.At.Before(*q.Until)) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func (s *MemoryStore) GetTask(id string) (*models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.taskCopy(id)
+}
+
+func (s *MemoryStore) CancelTask(id string) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if models.TaskFinished(t.Status) {
+		task, _ := s.taskCopy(id)
+		return task, ErrTaskFinished
+	}
+	t.Status = "Cancelled"
+	return s.taskCopy(id)
+}
+
+func (s *MemoryStore) RequeueTask(id string) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if t.Status != "Failed" {
+		task, _ := s.taskCopy(id)
+		return task, ErrTaskNotFailed
+	}
+	t.Status = "Pending"
+	t.Retries = sql.NullInt64{Int64: t.Retries.Int64 + 1, Valid: true}
+	if expireAt := time.Now().AddDate(0, 0, 1); t.ExpireAt == nil || t.ExpireAt.Before(expireAt) {
+		t.ExpireAt = &expireAt
+	}
+	return s.taskCopy(id)
+}
+
+// taskCopy returns a copy of the task with its latest execution.
+func (s *MemoryStore) taskCopy(id string) (*models.Task, error) {
+	t, ok := s.tasks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	task := *t
+	if l := s.latestLog(id); l != nil {
+		task.ExecutedBy = sql.NullString{String: l.ExecutedBy, Valid: true}
+		task.ExecutedAt = l.ExecutedAt
+	}
+	return &task, nil
+}
+
+func (s *MemoryStore) latestLog(taskID string) *models.TaskLogs {
+	for i := len(s.logs) - 1; i >= 0; i-- {
+		if s.logs[i].TaskID == taskID {
+			return &s.logs[i]
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateTaskSchema(ts *models.TaskSchema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt := time.Now()
+	ts.Version = len(s.schemas[ts.Type]) + 1
+	ts.CreatedAt = &createdAt
+	s.schemas[ts.Type] = append(s.schemas[ts.Type], *ts)
+	return nil
+}
+
+func (s *MemoryStore) GetTaskSchema(taskType string, version int) (*models.TaskSchema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.schemas[taskType]
+	if version == 0 {
+		version = len(versions)
+	}
+	if version < 1 || version > len(versions) {
+		return nil, ErrNotFound
+	}
+	ts := versions[version-1]
+	return &ts, nil
+}
+
+func (s *MemoryStore) ListTaskSchemas(taskType string) ([]models.TaskSchema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schemas := []models.TaskSchema{}
+	if taskType != "" {
+		versions := s.schemas[taskType]
+		for i := len(versions) - 1; i >= 0; i-- {
+			schemas = append(schemas, versions[i])
+		}
+		return schemas, nil
+	}
+	for _, versions := range s.schemas {
+		schemas = append(schemas, versions[len(versions)-1])
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Type < schemas[j].Type })
+	return schemas, nil
+}
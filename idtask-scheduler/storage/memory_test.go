@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// TestMemoryStorePayloadFilters lists tasks by payload filters, which must
+// select tasks as Postgres' @? and @@ do, and count what they list.
+func TestMemoryStorePayloadFilters(t *testing.T) {
+	s := NewMemoryStore()
+	tasks := []struct {
+		id, tenant, payload string
+	}{
+		{"small-png", "acme", `{"format":"png","width":320,"tags":["thumb"]}`},
+		{"large-png", "acme", `{"format":"png","width":1280,"tags":["urgent","batch"]}`},
+		{"large-jpg", "other", `{"format":"jpg","width":1920}`},
+		{"string", "acme", `"png"`},
+		{"null", "acme", `null`},
+		{"no-payload", "acme", ``},
+	}
+	for _, tt := range tasks {
+		task := &models.Task{ID: tt.id, Type: "resize", Tenant: tt.tenant}
+		if tt.payload != "" {
+			task.Payload = json.RawMessage(tt.payload)
+		}
+		if _, err := s.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		tenant  string
+		filters []string
+		want    []string
+	}{
+		{"no filter", "", nil, []string{"large-jpg", "large-png", "no-payload", "null", "small-png", "string"}},
+		{"tenant", "other", nil, []string{"large-jpg"}},
+		{"path", "", []string{`$.tags`}, []string{"large-png", "small-png"}},
+		{"path with a filter", "", []string{`$.tags[*] ? (@ == "urgent")`}, []string{"large-png"}},
+		{"predicate", "", []string{`$.width > 1000`}, []string{"large-jpg", "large-png"}},
+		{"predicate on the root", "", []string{`$ == "png"`}, []string{"string"}},
+		{"filters combine", "", []string{`$.format == "png"`, `$.width > 1000`}, []string{"large-png"}},
+		{"filter and tenant", "acme", []string{`$.width > 1000`}, []string{"large-png"}},
+		// lax mode: $.format selects nothing from "png" or null, so the
+		// comparison is false rather than an error
+		{"negated predicate", "", []string{`!($.format == "png")`}, []string{"large-jpg", "null", "string"}},
+		{"null payload", "", []string{`$ == null`}, []string{"null"}},
+		{"any payload", "", []string{`$`}, []string{"large-jpg", "large-png", "null", "small-png", "string"}},
+		{"no match", "", []string{`$.width > 5000`}, nil},
+		{"malformed filter", "", []string{`$.width >`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.APIListRequest{Page: 1, PageSize: 10, Tenant: tt.tenant, PayloadFilters: tt.filters}
+			listed, err := s.GetTasks(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, task := range listed {
+				got = append(got, task.ID)
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetTasks = %v, want %v", got, tt.want)
+			}
+			if n := s.GetTasksCount(req); n != len(tt.want) {
+				t.Errorf("GetTasksCount = %d, want %d", n, len(tt.want))
+			}
+		})
+	}
+}
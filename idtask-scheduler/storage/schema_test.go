@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// testStores returns the stores to run a test against: the in-memory one,
+// and PostgreSQL if TEST_PG_CONN_STRING names a database to use.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := map[string]Store{"memory": NewMemoryStore()}
+	if dsn := os.Getenv("TEST_PG_CONN_STRING"); dsn != "" {
+		conn, err := sqlx.Connect("postgres", dsn)
+		if err != nil {
+			t.Fatalf("postgres: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		db = conn
+		createTables()
+		stores["postgres"] = &PostgresStore{db: conn}
+	}
+	return stores
+}
+
+// TestCreateTaskSchemaConcurrent registers versions of one type at once,
+// each must get its own version and together they must count up from 1.
+func TestCreateTaskSchemaConcurrent(t *testing.T) {
+	const n = 20
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			taskType := fmt.Sprintf("concurrent-%d", time.Now().UnixNano())
+
+			var wg sync.WaitGroup
+			versions := make([]int, n)
+			errs := make([]error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					ts := &models.TaskSchema{Type: taskType, Schema: json.RawMessage(`{"type":"object"}`)}
+					errs[i] = s.CreateTaskSchema(ts)
+					versions[i] = ts.Version
+				}(i)
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				if err != nil {
+					t.Fatalf("CreateTaskSchema: %v", err)
+				}
+			}
+			sort.Ints(versions)
+			for i, v := range versions {
+				if v != i+1 {
+					t.Fatalf("versions %v, want 1 to %d", versions, n)
+				}
+			}
+
+			latest, err := s.GetTaskSchema(taskType, 0)
+			if err != nil || latest.Version != n {
+				t.Errorf("latest version %+v, %v", latest, err)
+			}
+			listed, err := s.ListTaskSchemas(taskType)
+			if err != nil || len(listed) != n {
+				t.Errorf("%d versions listed: %v", len(listed), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/models"
+)
+
+// Store is the persistence backend behind the package level helpers.
+// Postgres is used by the standalone services, MemoryStore by the embedded mode.
+type Store interface {
+	CreateTask(t *models.Task) (time.Time, error)
+	GetTasksCount(req *models.APIListRequest) int
+	GetTasks(req *models.APIListRequest) ([]models.Task, error)
+	GetTask(id string) (*models.Task, error)
+	CancelTask(id string) (*models.Task, error)
+	RequeueTask(id string) (*models.Task, error)
+	UpdateTasks(taskID, status string)
+	UpdateTasksFenced(taskID, status string, token int64) error
+	CreateTaskLogs(taskID, executedBy, result string, duration time.Duration)
+	GetTaskTypeStats() (map[string]models.TaskTypeStats, error)
+	CreatePredictionOutcome(o *models.PredictionOutcome) error
+	CreateSchedulingDecision(d *models.SchedulingDecision) error
+	CreatePredictorPrediction(p *models.PredictorPrediction) error
+	GetPredictorPredictions(taskID string) ([]models.PredictorPrediction, error)
+	CreateAPIKey(k *models.APIKey) error
+	GetAPIKeyByHash(hash string) (*models.APIKey, error)
+	ListAPIKeys() ([]models.APIKey, error)
+	RotateAPIKey(id string, next *models.APIKey) error
+	RevokeAPIKey(id string) error
+	CreateAuditEvent(e *models.AuditEvent) error
+	GetAuditEvents(q *models.AuditQuery) ([]models.AuditEvent, error)
+	GetAuditEventsCount(q *models.AuditQuery) int
+	CreateTaskSchema(ts *models.TaskSchema) error
+	GetTaskSchema(taskType string, version int) (*models.TaskSchema, error)
+	ListTaskSchemas(taskType string) ([]models.TaskSchema, error)
+}
+
+var store Store
+
+// ErrStaleToken is returned for a write made under a fencing token older
+// than one the task has already seen, i.e. by a deposed scheduler leader.
+var ErrStaleToken = errors.New("stale fencing token")
+
+// ErrNotFound is returned when a lookup or update matches nothing.
+var ErrNotFound = errors.New("not found")
+
+// ErrTaskFinished is returned for cancelling a task that already
+// completed, failed, expired or was cancelled.
+var ErrTaskFinished = errors.New("task already finished")
+
+// ErrTaskNotFailed is returned for requeueing a task that did not fail.
+var ErrTaskNotFailed = errors.New("task has not failed")
+
+// Use replaces the active store. It must be called before any service starts.
+func Use(s Store) {
+	store = s
+}
+
+func current() Store {
+	if store == nil {
+		log.Fatal("storage not initialized. Call storage.Init() or storage.Use() first.")
+	}
+	return store
+}
+
+func CreateTask(t *models.Task) (time.Time, error) {
+	return current().CreateTask(t)
+}
+
+// GetTasksCount counts the tasks GetTasks pages through: the tenant's,
+// every tenant's if it is empty, matching the payload filters.
+func GetTasksCount(req *models.APIListRequest) int {
+	return current().GetTasksCount(req)
+}
+
+func GetTasks(req *models.APIListRequest) ([]models.Task, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+	return current().GetTasks(req)
+}
+
+// GetTask returns one task with its latest execution, ErrNotFound if
+// there is no such task.
+func GetTask(id string) (*models.Task, error) {
+	return current().GetTask(id)
+}
+
+// CancelTask marks a task that has not finished as Cancelled and returns
+// it. A finished task is returned as is with ErrTaskFinished.
+func CancelTask(id string) (*models.Task, error) {
+	return current().CancelTask(id)
+}
+
+// RequeueTask sets a Failed task back to Pending, counting the retry and
+// giving it at least a day before it expires, and returns it. Any other
+// task is returned as is with ErrTaskNotFailed.
+func RequeueTask(id string) (*models.Task, error) {
+	return current().RequeueTask(id)
+}
+
+func UpdateTasks(taskID, status string) {
+	current().UpdateTasks(taskID, status)
+}
+
+// UpdateTasksFenced sets the task status on behalf of the scheduler leader
+// holding token, unless a newer leader already updated the task.
+func UpdateTasksFenced(taskID, status string, token int64) error {
+	return current().UpdateTasksFenced(taskID, status, token)
+}
+
+func CreateTaskLogs(taskID, executedBy, result string, duration time.Duration) {
+	current().CreateTaskLogs(taskID, executedBy, result, duration)
+}
+
+// GetTaskTypeStats returns execution averages keyed by task type.
+func GetTaskTypeStats() (map[string]models.TaskTypeStats, error) {
+	return current().GetTaskTypeStats()
+}
+
+// CreatePredictionOutcome records predicted-versus-actual for one execution.
+func CreatePredictionOutcome(o *models.PredictionOutcome) error {
+	return current().CreatePredictionOutcome(o)
+}
+
+// CreateSchedulingDecision records which worker a task went to and why.
+func CreateSchedulingDecision(d *models.SchedulingDecision) error {
+	return current().CreateSchedulingDecision(d)
+}
+
+// CreatePredictorPrediction records one primary or shadow prediction.
+func CreatePredictorPrediction(p *models.PredictorPrediction) error {
+	return current().CreatePredictorPrediction(p)
+}
+
+// GetPredictorPredictions returns every recorded prediction for a task.
+func GetPredictorPredictions(taskID string) ([]models.PredictorPrediction, error) {
+	return current().GetPredictorPredictions(taskID)
+}
+
+// CreateAPIKey stores a new API key, k.Hash must be set.
+func CreateAPIKey(k *models.APIKey) error {
+	return current().CreateAPIKey(k)
+}
+
+// GetAPIKeyByHash returns the active key with the given hash, or
+// ErrNotFound if there is none or it was revoked.
+func GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	return current().GetAPIKeyByHash(hash)
+}
+
+// ListAPIKeys returns every key, revoked ones included, newest first.
+func ListAPIKeys() ([]models.APIKey, error) {
+	return current().ListAPIKeys()
+}
+
+// RotateAPIKey revokes the active key id and stores next in its place,
+// with the same name, subject and roles, in one step.
+func RotateAPIKey(id string, next *models.APIKey) error {
+	return current().RotateAPIKey(id, next)
+}
+
+// RevokeAPIKey revokes the active key id, ErrNotFound if there is none.
+func RevokeAPIKey(id string) error {
+	return current().RevokeAPIKey(id)
+}
+
+// CreateAuditEvent appends an event to the audit log. Events are never
+// updated or deleted.
+func CreateAuditEvent(e *models.AuditEvent) error {
+	return current().CreateAuditEvent(e)
+}
+
+// GetAuditEvents returns the events matching q, newest first.
+func GetAuditEvents(q *models.AuditQuery) ([]models.AuditEvent, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = 50
+	}
+	return current().GetAuditEvents(q)
+}
+
+// GetAuditEventsCount returns how many events match q, ignoring its page.
+func GetAuditEventsCount(q *models.AuditQuery) int {
+	return current().GetAuditEventsCount(q)
+}
+
+// CreateTaskSchema stores ts as the next version of its type's schema and
+// sets its version.
+func CreateTaskSchema(ts *models.TaskSchema) error {
+	return current().CreateTaskSchema(ts)
+}
+
+// GetTaskSchema returns a version of a task type's schema, the latest for
+// version 0, or ErrNotFound.
+func GetTaskSchema(taskType string, version int) (*models.TaskSchema, error) {
+	return current().GetTaskSchema(taskType, version)
+}
+
+// ListTaskSchemas returns every version of a type's schema, newest first,
+// or the latest version of every type's, by type, if taskType is empty.
+func ListTaskSchemas(taskType string) ([]models.TaskSchema, error) {
+	return current().ListTaskSchemas(taskType)
+}
@@ -1,4 +1,4 @@
-package main
+package worker
 
 import (
 	"context"
@@ -28,26 +28,20 @@ func NewWorkerRegistry(endpoints []string) (*WorkerRegistry, error) {
 	return &WorkerRegistry{Client: cli, StopChan: make(chan struct{})}, nil
 }
 
-func (r *WorkerRegistry) Register(address string, ttl time.Duration) error {
+func (r *WorkerRegistry) Register(status models.WorkerStatus, ttl time.Duration) error {
 	leaseResp, err := r.Client.Grant(context.Background(), int64(ttl.Seconds()))
 	if err != nil {
 		return fmt.Errorf("grant lease failed: %w", err)
 	}
 	r.LeaseID = leaseResp.ID
 
-	status := models.WorkerStatus{
-		ID:        address,
-		Status:    "ok",
-		HeartBeat: time.Now(),
-	}
-
 	jsonBytes, err := json.Marshal(status)
 	if err != nil {
 		log.Printf("Failed to marshal worker status: %v", err)
 		return err
 	}
 
-	key := fmt.Sprintf("/workers/%s", address)
+	key := fmt.Sprintf("/workers/%s", status.ID)
 	_, err = r.Client.Put(context.Background(), key, string(jsonBytes), clientv3.WithLease(r.LeaseID))
 	if err != nil {
 		return fmt.Errorf("put with lease failed: %w", err)
@@ -0,0 +1,387 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JamesDante/idtask-scheduler/configs"
+	"github.com/JamesDante/idtask-scheduler/internal/aiclient"
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/internal/blob"
+	"github.com/JamesDante/idtask-scheduler/internal/cancel"
+	"github.com/JamesDante/idtask-scheduler/internal/drain"
+	"github.com/JamesDante/idtask-scheduler/internal/limits"
+	"github.com/JamesDante/idtask-scheduler/internal/pending"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/JamesDante/idtask-scheduler/utils"
+	"github.com/google/uuid"
+
+	"github.com/go-redis/redis/v8"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	json     = jsoniter.ConfigFastest
+	taskPool = sync.Pool{
+		New: func() any {
+			return new(models.Task)
+		},
+	}
+)
+
+const maxFailures = 3
+
+// Worker consumes the Redis list named after its ID and executes the tasks
+// the scheduler pushes there. Several workers may share one process.
+type Worker struct {
+	ID string
+
+	// Reporter, if set, receives the actual outcome of every predicted task.
+	Reporter aiclient.OutcomeReporter
+
+	// Weight is this worker's share of tasks under the weighted strategy.
+	Weight int
+	// Labels and TaskTypes are published so the scheduler can match tasks'
+	// label constraints. No task types means any type.
+	Labels    models.Labels
+	TaskTypes []string
+	// Capacity caps the tasks queued for or running on this worker.
+	Capacity int
+
+	rdb          *redis.Client
+	ctx          context.Context
+	registry     *WorkerRegistry
+	mu           sync.Mutex
+	failureCount int
+	unHealth     bool
+	running      bool
+	lastPublish  time.Time
+}
+
+func New(rdb *redis.Client) *Worker {
+	return &Worker{
+		ID:        generateWorkerID(),
+		Weight:    configs.Config.WorkerWeight,
+		Labels:    models.ParseLabels(configs.Config.WorkerLabels),
+		TaskTypes: splitList(configs.Config.WorkerTaskTypes),
+		Capacity:  configs.Config.WorkerCapacity,
+		rdb:       rdb,
+		ctx:       context.Background(),
+	}
+}
+
+// Run registers the worker in etcd and processes tasks until ctx is done.
+func (w *Worker) Run(ctx context.Context) error {
+	w.ctx = ctx
+
+	registry, err := NewWorkerRegistry([]string{configs.Config.EtcdAddress})
+	if err != nil {
+		return err
+	}
+	if err := registry.Register(w.status(), configs.LockTTL); err != nil {
+		return err
+	}
+	defer registry.Unregister()
+	w.registry = registry
+
+	go w.startWorkerHeartbeat()
+
+	w.consumeTasks()
+	return nil
+}
+
+func (w *Worker) consumeTasks() {
+	log.Println("Worker started. Waiting for tasks...")
+	for {
+		res, err := w.rdb.BLPop(w.ctx, 0*time.Second, w.ID).Result()
+		if err != nil {
+			if w.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Redis error: %v", err)
+			continue
+		}
+
+		if len(res) < 2 {
+			continue
+		}
+
+		log.Printf("Raw task from Redis: %s\n", res[1])
+
+		t := taskPool.Get().(*models.Task)
+		*t = models.Task{}
+
+		err = json.Unmarshal([]byte(res[1]), t)
+		if err != nil {
+			log.Printf("Invalid task JSON: %v", err)
+			taskPool.Put(t)
+			continue
+		}
+
+		w.setRunning(true)
+		w.processTask(*t)
+		w.setRunning(false)
+		taskPool.Put(t)
+		w.publishLoad()
+	}
+}
+
+func generateWorkerID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("worker-%s-%s", host, uuid.New().String()[:6])
+}
+
+func (w *Worker) processTask(task models.Task) error {
+	if cancel.Cancelled(w.ctx, w.rdb, task.ID) {
+		log.Printf("🛑 Task %s was cancelled, skipping\n", task.ID)
+		if err := limits.Release(w.ctx, w.rdb, &task); err != nil {
+			log.Printf("⚠️ Failed to release limit slot of task %s: %v", task.ID, err)
+		}
+		blob.Release(w.ctx, &task)
+		pending.Release(w.ctx, w.rdb, &task)
+		return nil
+	}
+
+	// key：task-executed:<task-id>
+	key := fmt.Sprintf("task-executed:%s", task.ID)
+
+	// SetNX: set when key not exists
+	success, err := w.rdb.SetNX(w.ctx, key, 1, 24*time.Hour).Result()
+	if err != nil {
+		return fmt.Errorf("Redis error: %w", err)
+	}
+
+	if !success {
+		log.Printf("⚠️ Task already executed: %s, skipping\n", task.ID)
+		pending.Release(w.ctx, w.rdb, &task)
+		return nil
+	}
+
+	log.Printf("✅ Executing task %s\n", task.ID)
+	start := time.Now()
+	// an offloaded payload is fetched only now, the queues carry its reference
+	err = blob.Load(w.ctx, &task)
+	if err == nil {
+		err = w.executeTask(task, start)
+	}
+	w.recordOutcome(task, err == nil, time.Since(start))
+	if err := limits.Release(w.ctx, w.rdb, &task); err != nil {
+		log.Printf("⚠️ Failed to release limit slot of task %s: %v", task.ID, err)
+	}
+	blob.Release(w.ctx, &task)
+	pending.Release(w.ctx, w.rdb, &task)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err != nil {
+		w.rdb.Del(w.ctx, key)
+		storage.UpdateTasks(task.ID, "Failed")
+		storage.CreateTaskLogs(task.ID, w.ID, "Task Failed", time.Since(start))
+
+		w.failureCount++
+		if w.failureCount >= maxFailures {
+			log.Printf("❌ Worker %s marked as failed after %d consecutive failures", w.ID, w.failureCount)
+
+			w.unHealth = true
+		}
+
+		monitor.WorkerTasksFailed().Inc()
+		return fmt.Errorf("task failed: %w", err)
+	}
+
+	w.unHealth = false
+	w.failureCount = 0
+
+	log.Printf("🎉 Task %s executed successfully\n", task.ID)
+	return nil
+}
+
+func (w *Worker) executeTask(t models.Task, start time.Time) error {
+	log.Printf("[Worker] Executing Task #%s: Type=%s, Payload=%s", t.ID, t.Type, t.Payload)
+	// TODO
+	time.Sleep(1 * time.Second)
+	log.Printf("[Worker] Task #%s completed", t.ID)
+
+	storage.UpdateTasks(t.ID, "Completed")
+	storage.CreateTaskLogs(t.ID, w.ID, "Task completed", time.Since(start))
+
+	return nil
+}
+
+// recordOutcome stores predicted-versus-actual for tasks the scheduler
+// attached a prediction to, and reports it to the AI service in the background.
+func (w *Worker) recordOutcome(task models.Task, success bool, duration time.Duration) {
+	if task.Prediction == nil {
+		return
+	}
+
+	actual := duration.Seconds()
+	outcome := models.PredictionOutcome{
+		TaskID:            task.ID,
+		TaskType:          task.Type,
+		PredictedPriority: task.Prediction.Priority,
+		PredictedTime:     float64(task.Prediction.EstimatedTime),
+		PredictedWorker:   task.Prediction.RecommendedWorker,
+		ModelVersion:      task.Prediction.ModelVersion,
+		ActualTime:        actual,
+		ActualWorker:      w.ID,
+		Success:           success,
+	}
+	if err := storage.CreatePredictionOutcome(&outcome); err != nil {
+		log.Printf("⚠️ Failed to record prediction outcome for task %s: %v", task.ID, err)
+	}
+
+	monitor.PredictionTimeError().WithLabelValues(task.Type).Observe(actual - outcome.PredictedTime)
+	matched := "false"
+	if outcome.PredictedWorker == w.ID {
+		matched = "true"
+	}
+	monitor.PredictionWorkerMatch().WithLabelValues(matched).Inc()
+
+	if configs.Config.AIShadowURLs != "" {
+		w.scorePredictors(task.ID, actual)
+	}
+
+	if w.Reporter == nil {
+		return
+	}
+
+	report := &pb.OutcomeReport{
+		TaskId: task.ID,
+		Metadata: map[string]string{
+			"TaskType": task.Type,
+			"Priority": utils.FormatNullInt(task.Priority),
+		},
+		Worker:            w.ID,
+		Success:           success,
+		ActualTime:        float32(actual),
+		PredictedPriority: task.Prediction.Priority,
+		PredictedTime:     task.Prediction.EstimatedTime,
+		PredictedWorker:   task.Prediction.RecommendedWorker,
+	}
+	go func() {
+		if err := w.Reporter.ReportOutcome(report); err != nil {
+			log.Printf("⚠️ Failed to report outcome for task %s: %v", task.ID, err)
+		}
+	}()
+}
+
+// scorePredictors compares every predictor recorded for the task, primary
+// and shadows alike, against what actually happened.
+func (w *Worker) scorePredictors(taskID string, actual float64) {
+	preds, err := storage.GetPredictorPredictions(taskID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load predictor predictions for task %s: %v", taskID, err)
+		return
+	}
+
+	for _, p := range preds {
+		monitor.PredictorTimeError().WithLabelValues(p.Predictor).Observe(actual - p.EstimatedTime)
+		matched := "false"
+		if p.RecommendedWorker == w.ID {
+			matched = "true"
+		}
+		monitor.PredictorWorkerMatch().WithLabelValues(p.Predictor, matched).Inc()
+	}
+}
+
+func (w *Worker) startWorkerHeartbeat() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, _ := json.Marshal(w.status())
+		if err := w.registry.Update(w.ID, string(data)); err != nil {
+			log.Printf("Failed to refresh heartbeat for worker %s: %v", w.ID, err)
+		}
+	}
+}
+
+// status is what the worker publishes in etcd on register and heartbeat.
+func (w *Worker) status() models.WorkerStatus {
+	w.mu.Lock()
+	statusStr := "ok"
+	if w.unHealth {
+		statusStr = "failed"
+	}
+	running := w.running
+	w.mu.Unlock()
+
+	// the API marks a drained worker, the mark outranks its health
+	if w.registry != nil && drain.Draining(w.ctx, w.registry.Client, w.ID) {
+		statusStr = drain.Status
+	}
+
+	inFlight, err := w.rdb.LLen(w.ctx, w.ID).Result()
+	if err != nil {
+		log.Printf("Failed to get queue length for worker %s: %v", w.ID, err)
+	}
+	if running {
+		inFlight++
+	}
+
+	return models.WorkerStatus{
+		ID:        w.ID,
+		Status:    statusStr,
+		HeartBeat: time.Now(),
+		Weight:    w.Weight,
+		Labels:    w.Labels,
+		TaskTypes: w.TaskTypes,
+		InFlight:  int(inFlight),
+		Capacity:  w.Capacity,
+	}
+}
+
+func (w *Worker) setRunning(running bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running = running
+}
+
+// minPublishInterval limits the status updates sent between heartbeats.
+const minPublishInterval = 500 * time.Millisecond
+
+// publishLoad tells the scheduler about freed capacity after a task. Only
+// workers with a capacity publish, at most every minPublishInterval unless
+// the queue ran empty.
+func (w *Worker) publishLoad() {
+	if w.Capacity <= 0 || w.registry == nil {
+		return
+	}
+
+	status := w.status()
+	if status.InFlight > 0 && time.Since(w.lastPublish) < minPublishInterval {
+		return
+	}
+	w.lastPublish = time.Now()
+
+	data, _ := json.Marshal(status)
+	if err := w.registry.Update(w.ID, string(data)); err != nil {
+		log.Printf("Failed to publish load for worker %s: %v", w.ID, err)
+	}
+}
+
+func splitList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	pb "github.com/JamesDante/idtask-scheduler/internal/aiclient/predict"
+	"github.com/JamesDante/idtask-scheduler/models"
+	"github.com/JamesDante/idtask-scheduler/monitor"
+	"github.com/JamesDante/idtask-scheduler/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// reports collects the outcomes reported to the AI service.
+type reports chan *pb.OutcomeReport
+
+func (r reports) ReportOutcome(report *pb.OutcomeReport) error {
+	r <- report
+	return nil
+}
+
+// TestRecordOutcome runs a predicted task's outcome through the store, the
+// metrics and the reporter.
+func TestRecordOutcome(t *testing.T) {
+	store := storage.NewMemoryStore()
+	storage.Use(store)
+	reported := make(reports, 1)
+	w := &Worker{ID: "worker-1", Reporter: reported}
+
+	series := testutil.CollectAndCount(monitor.PredictionTimeError())
+	matches := testutil.ToFloat64(monitor.PredictionWorkerMatch().WithLabelValues("true"))
+
+	task := models.Task{
+		ID:         "t1",
+		Type:       "outcome-test",
+		Priority:   sql.NullInt64{Int64: 5, Valid: true},
+		Prediction: &models.TaskPrediction{Priority: 5, EstimatedTime: 1.5, RecommendedWorker: "worker-1"},
+	}
+	w.recordOutcome(task, true, 2*time.Second)
+
+	outcomes := store.PredictionOutcomes()
+	if len(outcomes) != 1 {
+		t.Fatalf("%d outcomes recorded, want 1", len(outcomes))
+	}
+	o := outcomes[0]
+	if o.TaskID != "t1" || o.PredictedTime != 1.5 || o.ActualTime != 2 || o.ActualWorker != "worker-1" || !o.Success {
+		t.Errorf("recorded %+v", o)
+	}
+
+	if n := testutil.CollectAndCount(monitor.PredictionTimeError()); n != series+1 {
+		t.Errorf("%d time error series, want one for the task type", n)
+	}
+	if got := testutil.ToFloat64(monitor.PredictionWorkerMatch().WithLabelValues("true")); got != matches+1 {
+		t.Errorf("worker matches %v, want %v", got, matches+1)
+	}
+
+	select {
+	case r := <-reported:
+		if r.TaskId != "t1" || r.Worker != "worker-1" || r.ActualTime != 2 || r.Metadata["Priority"] != "5" {
+			t.Errorf("reported %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("outcome not reported")
+	}
+}
+
+// TestRecordOutcomeWithoutPrediction expects nothing recorded for a task
+// scheduled without a prediction.
+func TestRecordOutcomeWithoutPrediction(t *testing.T) {
+	store := storage.NewMemoryStore()
+	storage.Use(store)
+	w := &Worker{ID: "worker-1", Reporter: make(reports, 1)}
+
+	w.recordOutcome(models.Task{ID: "t1", Type: "outcome-test"}, true, time.Second)
+	if n := len(store.PredictionOutcomes()); n != 0 {
+		t.Errorf("%d outcomes recorded, want none", n)
+	}
+}